@@ -1,6 +1,13 @@
 package lilypad
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
 	"github.com/bacalhau-project/lilypad/pkg/executor/bacalhau"
 	optionsfactory "github.com/bacalhau-project/lilypad/pkg/options"
 	"github.com/bacalhau-project/lilypad/pkg/resourceprovider"
@@ -27,13 +34,96 @@ func newResourceProviderCmd() *cobra.Command {
 	}
 
 	optionsfactory.AddResourceProviderCliFlags(resourceProviderCmd, &options)
+	resourceProviderCmd.AddCommand(newResourceProviderValidateCmd())
+	resourceProviderCmd.AddCommand(newResourceProviderOffersCmd(&options))
 
 	return resourceProviderCmd
 }
 
+func newResourceProviderValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "validate <config-file>",
+		Short:   "Check a resource-provider config file without starting the service.",
+		Long:    "Load a json or yaml resource-provider config file over the defaults from the environment, check it and print what would be offered. ${VAR} and ${VAR:-default} in the file are filled in from the environment.",
+		Example: "lilypad resource-provider validate rp.yaml",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// failing validation is reported in the output so don't repeat the usage
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return resourceprovider.CheckConfigFile(args[0], optionsfactory.NewResourceProviderOptions(), cmd.OutOrStdout())
+		},
+	}
+}
+
+// for cleaning up the offers a crashed resource provider left on its solvers
+// these use the resource-provider flags but never start the solve loop
+func newResourceProviderOffersCmd(options *resourceprovider.ResourceProviderOptions) *cobra.Command {
+	offersCmd := &cobra.Command{
+		Use:   "offers",
+		Short: "List or remove our resource offers on the solvers.",
+		Long:  "List or remove our resource offers on the solvers without starting the resource-provider service.",
+	}
+	offersCmd.AddCommand(&cobra.Command{
+		Use:     "list",
+		Short:   "List our active resource offers on every solver.",
+		Example: "lilypad resource-provider offers list",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			resourceProviderService, err := newOfflineResourceProvider(*options)
+			if err != nil {
+				return err
+			}
+			offers, err := resourceProviderService.ListActiveOffers(cmd.Context())
+			for _, offer := range offers {
+				spec := offer.ResourceOffer.Spec
+				fmt.Fprintf(
+					cmd.OutOrStdout(), "%s index=%d cpu=%d gpu=%d ram=%d state=%s deal=%s\n",
+					offer.ID, offer.ResourceOffer.Index, spec.CPU, spec.GPU, spec.RAM,
+					data.GetAgreementStateString(offer.State), offer.DealID,
+				)
+			}
+			return err
+		},
+	})
+	offersCmd.AddCommand(&cobra.Command{
+		Use:     "remove",
+		Short:   "Remove all of our unmatched resource offers from every solver.",
+		Long:    "Remove all of our unmatched resource offers from every solver, including ones for specs we no longer offer. Offers matched to a deal are left alone.",
+		Example: "lilypad resource-provider offers remove",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			resourceProviderService, err := newOfflineResourceProvider(*options)
+			if err != nil {
+				return err
+			}
+			return resourceProviderService.RemoveAllOffers(cmd.Context())
+		},
+	})
+	return offersCmd
+}
+
+// a resource provider that can talk to the solvers but won't run any jobs
+func newOfflineResourceProvider(options resourceprovider.ResourceProviderOptions) (*resourceprovider.ResourceProvider, error) {
+	options, err := optionsfactory.ProcessResourceProviderOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	web3SDK, err := web3.NewContractSDK(options.Web3)
+	if err != nil {
+		return nil, err
+	}
+	return resourceprovider.NewResourceProvider(options, web3SDK, nil)
+}
+
 func runResourceProvider(cmd *cobra.Command, options resourceprovider.ResourceProviderOptions) error {
-	commandCtx := system.NewCommandContext(cmd)
+	// SIGTERM drains the resource provider before we stop (see below)
+	// an interrupt still stops us straight away, including part way through draining
+	commandCtx := system.NewCommandContextWithSignals(cmd, os.Interrupt)
 	defer commandCtx.Cleanup()
+	drainSignals := make(chan os.Signal, 1)
+	signal.Notify(drainSignals, syscall.SIGTERM)
+	defer signal.Stop(drainSignals)
 
 	web3SDK, err := web3.NewContractSDK(options.Web3)
 	if err != nil {
@@ -56,6 +146,11 @@ func runResourceProvider(cmd *cobra.Command, options resourceprovider.ResourcePr
 		case err := <-resourecProviderErrors:
 			commandCtx.Cleanup()
 			return err
+		case <-drainSignals:
+			drainCtx, cancel := context.WithTimeout(commandCtx.Ctx, options.DrainTimeout)
+			err := resourceProviderService.Drain(drainCtx)
+			cancel()
+			return err
 		case <-commandCtx.Ctx.Done():
 			return nil
 		}
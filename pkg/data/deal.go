@@ -0,0 +1,43 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Module is the module the job creator wants the deal to run
+// it is either a shortcut name or a repo pinned to a hash and path -
+// anything else can't be run so is an error rather than an empty module
+func (deal Deal) Module() (ModuleConfig, error) {
+	module := deal.JobOffer.Module
+	if module.Name != "" {
+		return module, nil
+	}
+	missing := []string{}
+	if module.Repo == "" {
+		missing = append(missing, "repo")
+	}
+	if module.Hash == "" {
+		missing = append(missing, "hash")
+	}
+	if module.Path == "" {
+		missing = append(missing, "path")
+	}
+	if len(missing) > 0 {
+		return ModuleConfig{}, fmt.Errorf("deal %s module has no name and no %s", deal.ID, strings.Join(missing, ", "))
+	}
+	return module, nil
+}
+
+// Inputs are the values the module template is run with
+// the map is a copy so changing it leaves the deal alone
+func (deal Deal) Inputs() (map[string]string, error) {
+	inputs := map[string]string{}
+	for key, value := range deal.JobOffer.Inputs {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("deal %s has an input with no name", deal.ID)
+		}
+		inputs[key] = value
+	}
+	return inputs, nil
+}
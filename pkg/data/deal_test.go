@@ -0,0 +1,41 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDealModule(t *testing.T) {
+	deal := Deal{ID: "deal1"}
+	deal.JobOffer.Module = ModuleConfig{Name: "cowsay:v0.0.1"}
+	module, err := deal.Module()
+	assert.NoError(t, err)
+	assert.Equal(t, "cowsay:v0.0.1", module.Name)
+
+	deal.JobOffer.Module = ModuleConfig{Repo: "https://github.com/lilypad-tech/lilypad-module-cowsay", Hash: "v0.0.1", Path: "lilypad_module.json.tmpl"}
+	module, err = deal.Module()
+	assert.NoError(t, err)
+	assert.Equal(t, "v0.0.1", module.Hash)
+
+	deal.JobOffer.Module = ModuleConfig{Repo: "https://github.com/lilypad-tech/lilypad-module-cowsay"}
+	_, err = deal.Module()
+	assert.EqualError(t, err, "deal deal1 module has no name and no hash, path")
+}
+
+func TestDealInputs(t *testing.T) {
+	deal := Deal{ID: "deal1"}
+	inputs, err := deal.Inputs()
+	assert.NoError(t, err)
+	assert.Empty(t, inputs)
+
+	deal.JobOffer.Inputs = map[string]string{"Message": "moo"}
+	inputs, err = deal.Inputs()
+	assert.NoError(t, err)
+	inputs["Message"] = "baa"
+	assert.Equal(t, "moo", deal.JobOffer.Inputs["Message"], "Changing the inputs should leave the deal alone")
+
+	deal.JobOffer.Inputs = map[string]string{" ": "moo"}
+	_, err = deal.Inputs()
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DetectMachineSpec works out what resources the host we are running on has
+// GPU detection is best effort - if nvidia-smi is not installed we report no GPUs
+func DetectMachineSpec() (MachineSpec, error) {
+	ram, err := detectRAM()
+	if err != nil {
+		return MachineSpec{}, err
+	}
+	gpuCount, gpuModel, vram := detectGPUs()
+	return MachineSpec{
+		CPU:      runtime.NumCPU() * 1000, //nolint:gomnd
+		GPU:      gpuCount * 1000,         //nolint:gomnd
+		GPUModel: gpuModel,
+		VRAM:     vram,
+		RAM:      ram,
+	}, nil
+}
+
+// read the total memory in megabytes from /proc/meminfo
+func detectRAM() (int, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("error reading host memory: %s", err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// MemTotal:       16316412 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, fmt.Errorf("error parsing MemTotal: %s", err.Error())
+		}
+		return kb / 1024, nil //nolint:gomnd
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+}
+
+// nvidia-smi prints one "name, memory" line per GPU
+// if the GPUs differ we report the first model and the smallest memory
+// so we never advertise more than every GPU can do
+func detectGPUs() (int, string, uint64) {
+	output, err := exec.Command("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0, "", 0
+	}
+	return parseGPUs(string(output))
+}
+
+func parseGPUs(output string) (int, string, uint64) {
+	count := 0
+	model := ""
+	var vram uint64
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			continue
+		}
+		memory, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		if count == 0 {
+			model = strings.TrimSpace(fields[0])
+			vram = memory
+		} else if memory < vram {
+			vram = memory
+		}
+		count++
+	}
+	return count, model, vram
+}
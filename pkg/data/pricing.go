@@ -0,0 +1,103 @@
+package data
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// the token deals are priced in
+const TOKEN_SYMBOL = "LP"
+
+// how many decimal places the token has on-chain
+const TOKEN_DECIMALS = 18
+
+// how many decimal places the amounts in DealPricing have - they are millionths
+// of a token so prices like "1.5 LP" can be set and a uint64 still holds
+// more tokens than there are, ConvertPricingAmount scales them up to TOKEN_DECIMALS
+const PRICING_DECIMALS = 6
+
+// one whole token in the units of the DealPricing amounts
+const PRICING_TOKEN = 1_000_000
+
+// ParseTokenAmount turns a decimal string like "1.5" or "1.5 LP" into an integer
+// number of the token's smallest unit given how many decimals it has
+// anything that could be read more than one way (signs, exponents, separators)
+// or that has more decimal places than the unit can hold is rejected rather than rounded
+func ParseTokenAmount(amount string, decimals int) (*big.Int, error) {
+	value := strings.TrimSpace(amount)
+	value = strings.TrimSpace(strings.TrimSuffix(value, TOKEN_SYMBOL))
+	if value == "" {
+		return nil, fmt.Errorf("no amount given")
+	}
+	whole, fraction, hasPoint := strings.Cut(value, ".")
+	if hasPoint && fraction == "" {
+		return nil, fmt.Errorf("amount %q has nothing after the decimal point", amount)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(fraction) {
+		return nil, fmt.Errorf("amount %q must be digits with an optional decimal point and %s", amount, TOKEN_SYMBOL)
+	}
+	// trailing zeros don't change the amount so "1.50" is fine with one decimal
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("amount %q has more than %d decimal places and would lose precision", amount, decimals)
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+	ret, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	return ret, nil
+}
+
+// FormatTokenAmount is the reverse of ParseTokenAmount e.g. "1.5 LP"
+func FormatTokenAmount(amount *big.Int, decimals int) string {
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole := digits[:len(digits)-decimals]
+	fraction := strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if amount.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if fraction == "" {
+		return fmt.Sprintf("%s %s", whole, TOKEN_SYMBOL)
+	}
+	return fmt.Sprintf("%s.%s %s", whole, fraction, TOKEN_SYMBOL)
+}
+
+// ParsePricingAmount parses an amount for one of the DealPricing fields
+func ParsePricingAmount(amount string) (uint64, error) {
+	value, err := ParseTokenAmount(amount, PRICING_DECIMALS)
+	if err != nil {
+		return 0, err
+	}
+	if !value.IsUint64() {
+		return 0, fmt.Errorf("amount %q is too large", amount)
+	}
+	return value.Uint64(), nil
+}
+
+func FormatPricingAmount(amount uint64) string {
+	return FormatTokenAmount(new(big.Int).SetUint64(amount), PRICING_DECIMALS)
+}
+
+// ConvertPricingAmount turns a DealPricing amount into the token's smallest unit
+// for the chain - this is all integer maths so no amount loses precision
+func ConvertPricingAmount(amount uint64) *big.Int {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(TOKEN_DECIMALS-PRICING_DECIMALS), nil)
+	return new(big.Int).Mul(new(big.Int).SetUint64(amount), scale)
+}
+
+func isDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokenAmount(t *testing.T) {
+	for amount, expected := range map[string]string{
+		"1":          "1000000000000000000",
+		"1.5 LP":     "1500000000000000000",
+		" 0.25LP ":   "250000000000000000",
+		".5":         "500000000000000000",
+		"2.50000":    "2500000000000000000",
+		"0.00000001": "10000000000",
+	} {
+		value, err := ParseTokenAmount(amount, TOKEN_DECIMALS)
+		assert.NoError(t, err, amount)
+		assert.Equal(t, expected, value.String(), amount)
+	}
+	for _, amount := range []string{"", "LP", "-1", "+1", "1e18", "1,5", "1_000", "1.", "1.2.3", "0x10", "1 ETH", "0.0000000000000000001"} {
+		_, err := ParseTokenAmount(amount, TOKEN_DECIMALS)
+		assert.Error(t, err, amount)
+	}
+
+	value, _ := new(big.Int).SetString("1500000000000000000", 10)
+	assert.Equal(t, "1.5 LP", FormatTokenAmount(value, TOKEN_DECIMALS))
+	assert.Equal(t, "0.000000000000000001 LP", FormatTokenAmount(big.NewInt(1), TOKEN_DECIMALS))
+	assert.Equal(t, "0 LP", FormatTokenAmount(big.NewInt(0), TOKEN_DECIMALS))
+}
+
+func TestParsePricingAmount(t *testing.T) {
+	amount, err := ParsePricingAmount("2.0 LP")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2*PRICING_TOKEN), amount)
+	assert.Equal(t, "2 LP", FormatPricingAmount(amount))
+
+	amount, err = ParsePricingAmount("1.5 LP")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5 LP", FormatPricingAmount(amount))
+	assert.Equal(t, "1500000000000000000", ConvertPricingAmount(amount).String())
+
+	_, err = ParsePricingAmount("0.0000001 LP")
+	assert.Error(t, err, "Pricing is in millionths of a token so a smaller fraction would be lost")
+	_, err = ParsePricingAmount("18446744073709551616")
+	assert.Error(t, err, "Amounts that don't fit in a uint64 should be rejected")
+}
@@ -14,11 +14,22 @@ type MachineSpec struct {
 	// let's not use a float and fix the precision to 1/1000
 	GPU int `json:"gpu"`
 
+	// e.g. "NVIDIA A100-SXM4-40GB"
+	// when used by job offers an empty model matches any GPU
+	GPUModel string `json:"gpu_model,omitempty"`
+
+	// Megabytes of memory on each GPU
+	VRAM uint64 `json:"vram,omitempty"`
+
 	// Milli-CPU
 	CPU int `json:"cpu"`
 
 	// Megabytes
 	RAM int `json:"ram"`
+
+	// anything else the machine can do e.g. "cuda": "12" or "region": "eu"
+	// when used by job offers every label must be on the resource offer
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // this is what is loaded from the template file in the git repo
@@ -188,6 +199,7 @@ type DealTimeouts struct {
 	MediateResults DealTimeout `json:"mediate_results"`
 }
 
+// the amounts are in millionths of a token (see PRICING_DECIMALS)
 type DealPricing struct {
 	InstructionPrice          uint64 `json:"instruction_price"`
 	PaymentCollateral         uint64 `json:"payment_collateral"`
@@ -227,9 +227,9 @@ func ConvertDealPricing(
 	pricing DealPricing,
 ) controller.SharedStructsDealPricing {
 	return controller.SharedStructsDealPricing{
-		InstructionPrice:          EtherToWei(float64(pricing.InstructionPrice)),
-		PaymentCollateral:         EtherToWei(float64(pricing.PaymentCollateral)),
-		ResultsCollateralMultiple: big.NewInt(int64(pricing.ResultsCollateralMultiple)),
-		MediationFee:              EtherToWei(float64(pricing.MediationFee)),
+		InstructionPrice:          ConvertPricingAmount(pricing.InstructionPrice),
+		PaymentCollateral:         ConvertPricingAmount(pricing.PaymentCollateral),
+		ResultsCollateralMultiple: new(big.Int).SetUint64(pricing.ResultsCollateralMultiple),
+		MediationFee:              ConvertPricingAmount(pricing.MediationFee),
 	}
 }
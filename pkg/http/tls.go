@@ -0,0 +1,45 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// build the tls config for talking to a server that wants mutual tls
+// returns nil if none of the tls options are set so the default transport is used
+func GetTLSConfig(options ClientOptions) (*tls.Config, error) {
+	if options.TLSClientCert == "" && options.TLSClientKey == "" && options.TLSCACert == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if options.TLSClientCert != "" || options.TLSClientKey != "" {
+		if options.TLSClientCert == "" || options.TLSClientKey == "" {
+			return nil, fmt.Errorf("both a tls client cert and key are needed")
+		}
+		cert, err := tls.LoadX509KeyPair(options.TLSClientCert, options.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tls client cert: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if options.TLSCACert != "" {
+		caCert, err := os.ReadFile(options.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls ca cert: %w", err)
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in tls ca cert %s", options.TLSCACert)
+		}
+		tlsConfig.RootCAs = caPool
+	}
+
+	return tlsConfig, nil
+}
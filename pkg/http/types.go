@@ -1,5 +1,24 @@
 package http
 
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/web3"
+)
+
+// how long a single request can take if ClientOptions.Timeout is not set
+const DEFAULT_CLIENT_TIMEOUT = 30 * time.Second
+
+// how long opening a connection can take and how often tcp keep-alive probes
+// are sent if ClientOptions.KeepAlive is not set, both as in http.DefaultTransport
+const DEFAULT_DIAL_TIMEOUT = 30 * time.Second
+const DEFAULT_KEEP_ALIVE = 30 * time.Second
+
+// how many times a failed request is retried if nothing else is set
+const DEFAULT_RETRY_MAX = 10
+
 type ServerOptions struct {
 	URL  string
 	Host string
@@ -9,4 +28,36 @@ type ServerOptions struct {
 type ClientOptions struct {
 	URL        string
 	PrivateKey string
+	// signs requests instead of PrivateKey if set
+	Signer web3.Signer
+	// paths to PEM files for servers that want mutual tls
+	// if none are set we use the default transport
+	TLSClientCert string
+	TLSClientKey  string
+	TLSCACert     string
+	// how long a single request attempt can take, zero means DEFAULT_CLIENT_TIMEOUT
+	Timeout time.Duration
+	// connection pool limits, zero means the net/http defaults
+	MaxIdleConns    int
+	MaxConnsPerHost int
+	// how long an idle connection is kept for reuse and how often tcp
+	// keep-alive probes are sent on it, zero means the net/http defaults
+	// (DEFAULT_KEEP_ALIVE for the probes) and a negative KeepAlive turns them off
+	IdleConnTimeout time.Duration
+	KeepAlive       time.Duration
+	// HTTP/2 is used whenever the server offers it, this makes it the only option:
+	// https servers that can't speak it are rejected and plain http uses h2c
+	ForceHTTP2 bool
+	// shared between requests so connections are reused, see NewTransport
+	// if nil each request gets its own transport
+	Transport *http.Transport
+	// requests are cancelled with this and carry its trace context in their headers
+	// if nil requests run until Timeout
+	Context context.Context
+	// how GET requests are retried on network errors and 5xx responses
+	// they don't change anything so can be tuned separately from other requests
+	// zero means DEFAULT_RETRY_MAX retries and retryablehttp's default waits
+	GetRetryMax     int
+	GetRetryWaitMin time.Duration
+	GetRetryWaitMax time.Duration
 }
@@ -2,12 +2,14 @@ package http
 
 import (
 	"bytes"
-	"crypto/ecdsa"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	stdlog "log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,6 +17,9 @@ import (
 	"github.com/bacalhau-project/lilypad/pkg/web3"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/http2"
 )
 
 // write some string constants for x-lilypad headers
@@ -73,7 +78,7 @@ func decodeUserAddress(userPayload string, signature string) (string, error) {
 
 // returns userPayload and signature as strings ready to be written into request headers
 // we encode these both as base64 so they can be included in http headers
-func encodeUserAddress(privateKey *ecdsa.PrivateKey, address string) (string, string, error) {
+func encodeUserAddress(signer web3.Signer, address string) (string, string, error) {
 	user := AuthUser{
 		Address: address,
 	}
@@ -81,7 +86,7 @@ func encodeUserAddress(privateKey *ecdsa.PrivateKey, address string) (string, st
 	if err != nil {
 		return "", "", err
 	}
-	userSignature, err := web3.SignMessage(privateKey, userBytes)
+	userSignature, err := signer.SignMessage(userBytes)
 	if err != nil {
 		return "", "", err
 	}
@@ -89,12 +94,39 @@ func encodeUserAddress(privateKey *ecdsa.PrivateKey, address string) (string, st
 	return base64.StdEncoding.EncodeToString(userBytes), base64.StdEncoding.EncodeToString(userSignature), nil
 }
 
+// SignRequest returns the headers that prove a request was sent by the holder of signer
+// the signature covers a json payload naming our address - body is not signed for now
+// so the same headers are valid for any request, it is taken so callers won't have to
+// change when the scheme starts covering it (see VerifyRequest)
+func SignRequest(signer web3.Signer, body []byte) (http.Header, error) {
+	userPayload, userSignature, err := encodeUserAddress(signer, signer.GetAddress().String())
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set(X_LILYPAD_USER_HEADER, userPayload)
+	header.Set(X_LILYPAD_SIGNATURE_HEADER, userSignature)
+	return header, nil
+}
+
+// the signer to use for a client - either the one we were given or one for the private key
+func getSigner(options ClientOptions) (web3.Signer, error) {
+	if options.Signer != nil {
+		return options.Signer, nil
+	}
+	privateKey, err := web3.ParsePrivateKey(options.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return web3.NewPrivateKeySigner(privateKey), nil
+}
+
 func AddHeaders(
 	req *retryablehttp.Request,
-	privateKey *ecdsa.PrivateKey,
+	signer web3.Signer,
 	address string,
 ) error {
-	userPayload, userSignature, err := encodeUserAddress(privateKey, address)
+	userPayload, userSignature, err := encodeUserAddress(signer, address)
 	if err != nil {
 		return err
 	}
@@ -105,18 +137,23 @@ func AddHeaders(
 
 // this will use the client headers to ensure that a message was signed
 // by the holder of a private key for a specific address
-// there is a "X-Lilypad-User" header that will contain the address
-// there is a "X-Lilypad-Signature" header that will contain the signature
-// we use the signature to verify that the message was signed by the private key
 func GetAddressFromHeaders(req *http.Request) (string, error) {
-	userHeader := req.Header.Get(X_LILYPAD_USER_HEADER)
+	return VerifyRequest(req.Header)
+}
+
+// VerifyRequest checks headers made by SignRequest and returns the address that signed them
+// there is a "X-Lilypad-User" header that will contain base64 json {"address": "0x..."}
+// there is a "X-Lilypad-Signature" header that will contain the base64 signature
+// of the keccak256 hash of that json - we check the signer is the address it names
+func VerifyRequest(header http.Header) (string, error) {
+	userHeader := header.Get(X_LILYPAD_USER_HEADER)
 	if userHeader == "" {
 		return "", HTTPError{
 			Message:    "missing user header",
 			StatusCode: http.StatusUnauthorized,
 		}
 	}
-	userSignature := req.Header.Get(X_LILYPAD_SIGNATURE_HEADER)
+	userSignature := header.Get(X_LILYPAD_SIGNATURE_HEADER)
 	if userSignature == "" {
 		return "", HTTPError{
 			Message:    "missing signature header",
@@ -222,6 +259,37 @@ func GetHandler[T any](handler httpGetWrapper[T]) func(res http.ResponseWriter,
 	return ret
 }
 
+// the same as GetHandler but logged at debug because a delete mutates
+func DeleteHandler[T any](handler httpGetWrapper[T]) func(res http.ResponseWriter, req *http.Request) {
+	ret := func(res http.ResponseWriter, req *http.Request) {
+		data, err := handler(res, req)
+		if err != nil {
+			log.Error().
+				Str("method DELETE", req.URL.String()).
+				Err(err).
+				Msgf("")
+			httpError, ok := err.(HTTPError)
+			if ok {
+				http.Error(res, httpError.Error(), httpError.StatusCode)
+			} else {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		log.Debug().
+			Str("method DELETE", req.URL.String()).
+			Str("res", fmt.Sprintf("%+v", data)).
+			Msgf("")
+		err = json.NewEncoder(res).Encode(data)
+		if err != nil {
+			log.Ctx(req.Context()).Error().Msgf("error for json encoding: %s", err.Error())
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	return ret
+}
+
 func PostHandler[RequestType any, ResultType any](handler httpPostWrapper[RequestType, ResultType]) func(res http.ResponseWriter, req *http.Request) {
 	ret := func(res http.ResponseWriter, req *http.Request) {
 		requestBody, err := ReadBody[RequestType](req)
@@ -264,12 +332,21 @@ func GetRequest[ResultType any](
 	options ClientOptions,
 	path string,
 	queryParams map[string]string,
+) (ResultType, error) {
+	return GetRequestWithValues[ResultType](options, path, ToQueryValues(queryParams))
+}
+
+// the same as GetRequest for queries that need to repeat a param
+func GetRequestWithValues[ResultType any](
+	options ClientOptions,
+	path string,
+	queryValues url.Values,
 ) (ResultType, error) {
 	var result ResultType
-	buf, err := GetRequestBuffer(
+	buf, err := GetRequestBufferWithValues(
 		options,
 		path,
-		queryParams,
+		queryValues,
 	)
 	if err != nil {
 		return result, err
@@ -288,20 +365,26 @@ func GetRequestBuffer(
 	path string,
 	queryParams map[string]string,
 ) (*bytes.Buffer, error) {
-	client := newRetryClient()
+	return GetRequestBufferWithValues(options, path, ToQueryValues(queryParams))
+}
 
-	parsedURL, err := url.Parse(URL(options, path))
+func GetRequestBufferWithValues(
+	options ClientOptions,
+	path string,
+	queryValues url.Values,
+) (*bytes.Buffer, error) {
+	client, err := newGetRetryClient(options)
 	if err != nil {
 		return nil, err
 	}
 
-	urlValues := url.Values{}
-	for key, value := range queryParams {
-		urlValues.Add(key, value)
+	parsedURL, err := url.Parse(URL(options, path))
+	if err != nil {
+		return nil, err
 	}
-	parsedURL.RawQuery = urlValues.Encode()
+	parsedURL.RawQuery = queryValues.Encode()
 
-	req, err := retryablehttp.NewRequest("GET", parsedURL.String(), nil)
+	req, err := newRequest(options, "GET", parsedURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -317,10 +400,22 @@ func GetRequestBuffer(
 	if err != nil {
 		return nil, err
 	}
+	err = checkResponseStatus(resp, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
 
 	return &buf, nil
 }
 
+func ToQueryValues(queryParams map[string]string) url.Values {
+	queryValues := url.Values{}
+	for key, value := range queryParams {
+		queryValues.Add(key, value)
+	}
+	return queryValues
+}
+
 func PostRequest[RequestType any, ResultType any](
 	options ClientOptions,
 	path string,
@@ -344,16 +439,19 @@ func PostRequestBuffer[ResultType any](
 	data *bytes.Buffer,
 ) (ResultType, error) {
 	var result ResultType
-	client := newRetryClient()
-	privateKey, err := web3.ParsePrivateKey(options.PrivateKey)
+	client, err := newRetryClient(options)
 	if err != nil {
 		return result, err
 	}
-	req, err := retryablehttp.NewRequest("POST", URL(options, path), data)
+	signer, err := getSigner(options)
 	if err != nil {
 		return result, err
 	}
-	AddHeaders(req, privateKey, web3.GetAddress(privateKey).String())
+	req, err := newRequest(options, "POST", URL(options, path), data)
+	if err != nil {
+		return result, err
+	}
+	AddHeaders(req, signer, signer.GetAddress().String())
 	resp, err := client.Do(req)
 	if err != nil {
 		return result, err
@@ -363,6 +461,10 @@ func PostRequestBuffer[ResultType any](
 	if err != nil {
 		return result, err
 	}
+	err = checkResponseStatus(resp, body)
+	if err != nil {
+		return result, err
+	}
 
 	// parse body as json into result
 	err = json.Unmarshal(body, &result)
@@ -373,9 +475,156 @@ func PostRequestBuffer[ResultType any](
 	return result, nil
 }
 
-func newRetryClient() *retryablehttp.Client {
+func DeleteRequest[ResultType any](
+	options ClientOptions,
+	path string,
+) (ResultType, error) {
+	var result ResultType
+	client, err := newRetryClient(options)
+	if err != nil {
+		return result, err
+	}
+	signer, err := getSigner(options)
+	if err != nil {
+		return result, err
+	}
+	req, err := newRequest(options, "DELETE", URL(options, path), nil)
+	if err != nil {
+		return result, err
+	}
+	AddHeaders(req, signer, signer.GetAddress().String())
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	err = checkResponseStatus(resp, body)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// turn an error response from the server into an HTTPError
+// so callers can tell what kind of failure it was from the status code
+func checkResponseStatus(resp *http.Response, body []byte) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	return HTTPError{
+		Message:    strings.TrimSpace(string(body)),
+		StatusCode: resp.StatusCode,
+	}
+}
+
+// NewTransport builds a transport with the tls config and pool limits from options
+// keep hold of it in ClientOptions.Transport so requests share its connections
+func NewTransport(options ClientOptions) (*http.Transport, error) {
+	tlsConfig, err := GetTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	if options.MaxIdleConns > 0 {
+		transport.MaxIdleConns = options.MaxIdleConns
+		if transport.MaxIdleConnsPerHost < options.MaxIdleConns {
+			transport.MaxIdleConnsPerHost = options.MaxIdleConns
+		}
+	}
+	if options.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = options.MaxConnsPerHost
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	// the same as the default transport's dialer apart from the keep-alive
+	dialer := &net.Dialer{
+		Timeout:   DEFAULT_DIAL_TIMEOUT,
+		KeepAlive: options.KeepAlive,
+	}
+	if dialer.KeepAlive == 0 {
+		dialer.KeepAlive = DEFAULT_KEEP_ALIVE
+	}
+	transport.DialContext = dialer.DialContext
+	// connections are kept alive between requests and upgraded to HTTP/2
+	// if the server offers it even though we may have set our own tls config
+	transport.DisableKeepAlives = false
+	transport.ForceAttemptHTTP2 = true
+	if options.ForceHTTP2 {
+		forceHTTP2(transport, dialer)
+	}
+	return transport, nil
+}
+
+// refuse https connections that didn't agree on HTTP/2 and send plain http
+// requests over h2c, which the server has to support without an upgrade
+func forceHTTP2(transport *http.Transport, dialer *net.Dialer) {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{} //nolint:gosec
+	}
+	transport.TLSClientConfig.NextProtos = []string{http2.NextProtoTLS}
+	transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if state.NegotiatedProtocol != http2.NextProtoTLS {
+			return fmt.Errorf("server does not support HTTP/2")
+		}
+		return nil
+	}
+	transport.RegisterProtocol("http", &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	})
+}
+
+// build a request with the context from options
+// so the server can join our trace if we are part of one
+func newRequest(options ClientOptions, method string, url string, body interface{}) (*retryablehttp.Request, error) {
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, nil
+}
+
+func newRetryClient(options ClientOptions) (*retryablehttp.Client, error) {
 	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 10
+	transport := options.Transport
+	if transport == nil {
+		var err error
+		transport, err = NewTransport(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	retryClient.HTTPClient.Transport = transport
+	// without a timeout a hung request would block the caller forever
+	retryClient.HTTPClient.Timeout = options.Timeout
+	if retryClient.HTTPClient.Timeout <= 0 {
+		retryClient.HTTPClient.Timeout = DEFAULT_CLIENT_TIMEOUT
+	}
+	retryClient.RetryMax = DEFAULT_RETRY_MAX
+	// once the retries run out hand back the last response rather than a bare error
+	// so checkResponseStatus can tell callers the server was e.g. overloaded
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
 	retryClient.Logger = stdlog.New(io.Discard, "", stdlog.LstdFlags)
 	retryClient.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
 		switch {
@@ -392,5 +641,23 @@ func newRetryClient() *retryablehttp.Client {
 				Msgf("")
 		}
 	}
-	return retryClient
+	return retryClient, nil
+}
+
+// the retry client for GET requests with the GET retry options applied
+func newGetRetryClient(options ClientOptions) (*retryablehttp.Client, error) {
+	retryClient, err := newRetryClient(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.GetRetryMax > 0 {
+		retryClient.RetryMax = options.GetRetryMax
+	}
+	if options.GetRetryWaitMin > 0 {
+		retryClient.RetryWaitMin = options.GetRetryWaitMin
+	}
+	if options.GetRetryWaitMax > 0 {
+		retryClient.RetryWaitMax = options.GetRetryWaitMax
+	}
+	return retryClient, nil
 }
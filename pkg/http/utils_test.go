@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/web3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the first hardhat development account - never use it for anything real
+const testVectorPrivateKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+const testVectorAddress = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+
+// base64 of {"address":"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"}
+const testVectorUserHeader = "eyJhZGRyZXNzIjoiMHhmMzlGZDZlNTFhYWQ4OEY2RjRjZTZhQjg4MjcyNzljZmZGYjkyMjY2In0="
+const testVectorSignatureHeader = "sGCeRZJtSE6K8af8lhqEXzpEC79LJELIZEggsn+EV1NKdQkSmssbp201Ki0IpVr7Hhl5ATHNEAuPTXQuj7uaEAE="
+
+func TestSignRequestVector(t *testing.T) {
+	privateKey, err := web3.ParsePrivateKey(testVectorPrivateKey)
+	assert.NoError(t, err)
+
+	header, err := SignRequest(web3.NewPrivateKeySigner(privateKey), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, testVectorUserHeader, header.Get(X_LILYPAD_USER_HEADER))
+	assert.Equal(t, testVectorSignatureHeader, header.Get(X_LILYPAD_SIGNATURE_HEADER))
+
+	address, err := VerifyRequest(header)
+	assert.NoError(t, err)
+	assert.Equal(t, testVectorAddress, address)
+
+	// the body is not part of the signature yet
+	bodyHeader, err := SignRequest(web3.NewPrivateKeySigner(privateKey), []byte(`{"id":"1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, header, bodyHeader)
+}
+
+func TestVerifyRequestRejectsTampering(t *testing.T) {
+	header := http.Header{}
+	_, err := VerifyRequest(header)
+	assert.Error(t, err, "Missing headers should be rejected")
+
+	otherKey, err := web3.ParsePrivateKey("59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d")
+	assert.NoError(t, err)
+	otherHeader, err := SignRequest(web3.NewPrivateKeySigner(otherKey), nil)
+	assert.NoError(t, err)
+
+	// someone else's signature over our payload
+	header.Set(X_LILYPAD_USER_HEADER, testVectorUserHeader)
+	header.Set(X_LILYPAD_SIGNATURE_HEADER, otherHeader.Get(X_LILYPAD_SIGNATURE_HEADER))
+	_, err = VerifyRequest(header)
+	assert.Error(t, err, "A signature from a different key should be rejected")
+}
+
+func TestNewRetryClientLimits(t *testing.T) {
+	client, err := newRetryClient(ClientOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, DEFAULT_CLIENT_TIMEOUT, client.HTTPClient.Timeout, "Requests should not be allowed to hang forever")
+
+	transport, err := NewTransport(ClientOptions{MaxIdleConns: 200, MaxConnsPerHost: 8})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, transport.MaxIdleConns)
+	assert.Equal(t, 8, transport.MaxConnsPerHost)
+
+	client, err = newRetryClient(ClientOptions{Timeout: time.Second, Transport: transport})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, client.HTTPClient.Timeout)
+	assert.Same(t, transport, client.HTTPClient.Transport, "A shared transport should be reused")
+}
+
+func TestNewTransportHTTP2(t *testing.T) {
+	transport, err := NewTransport(ClientOptions{IdleConnTimeout: time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	get := func(server *httptest.Server, options ClientOptions) (*http.Response, error) {
+		transport, err := NewTransport(options)
+		assert.NoError(t, err)
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{} //nolint:gosec
+		}
+		transport.TLSClientConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+		defer transport.CloseIdleConnections()
+		return (&http.Client{Transport: transport}).Get(server.URL)
+	}
+	handler := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {})
+
+	http2Server := httptest.NewUnstartedServer(handler)
+	http2Server.EnableHTTP2 = true
+	http2Server.StartTLS()
+	defer http2Server.Close()
+	resp, err := get(http2Server, ClientOptions{})
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor, "HTTP/2 should be used when the server offers it")
+
+	http1Server := httptest.NewTLSServer(handler)
+	defer http1Server.Close()
+	resp, err = get(http1Server, ClientOptions{})
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 1, resp.ProtoMajor)
+	_, err = get(http1Server, ClientOptions{ForceHTTP2: true})
+	assert.Error(t, err, "A server without HTTP/2 should be rejected when it is forced")
+}
+
+func TestGetRequestRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls <= 2 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = res.Write([]byte(`"ok"`))
+	}))
+	defer server.Close()
+
+	options := ClientOptions{
+		URL:             server.URL,
+		GetRetryMax:     2,
+		GetRetryWaitMin: time.Millisecond,
+		GetRetryWaitMax: time.Millisecond,
+	}
+	result, err := GetRequest[string](options, "/thing", map[string]string{})
+	assert.NoError(t, err, "A GET should get through a brief outage")
+	assert.Equal(t, "ok", result)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	options.GetRetryMax = 1
+	_, err = GetRequest[string](options, "/thing", map[string]string{})
+	assert.Error(t, err, "We should give up after GetRetryMax retries")
+	var httpError HTTPError
+	assert.ErrorAs(t, err, &httpError, "The last status should be kept once we give up")
+	assert.Equal(t, http.StatusServiceUnavailable, httpError.StatusCode)
+	assert.Equal(t, 2, calls)
+}
+
+func TestNewRequestPropagatesTrace(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	assert.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	req, err := newRequest(ClientOptions{Context: ctx}, "GET", "http://solver/api/v1/deals", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", req.Header.Get("traceparent"))
+
+	req, err = newRequest(ClientOptions{}, "GET", "http://solver/api/v1/deals", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, req.Header.Get("traceparent"), "There is no trace to propagate without a context")
+}
@@ -2,78 +2,146 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 )
 
+const WEBSOCKET_RECONNECT_BASE_DELAY = 1 * time.Second
+const WEBSOCKET_RECONNECT_MAX_DELAY = 30 * time.Second
+
 // ConnectWebSocket establishes a new WebSocket connection
+// if the connection drops we keep reconnecting with backoff and carry on
+// writing messages to the same channel - onDisconnect (if given) is called
+// each time the connection drops and onReconnect (if given) each time we
+// manage to get it back
+// getURL is called for every attempt so the address can change between them
+// tlsConfig can be nil to use the default dialer settings
+// the returned channel is closed once ctx has been cancelled and we have
+// closed the connection and stopped reading from it
 func ConnectWebSocket(
-	url string,
+	getURL func() string,
+	tlsConfig *tls.Config,
 	messageChan chan []byte,
 	ctx context.Context,
-) *websocket.Conn {
-	closed := false
-
+	onReconnect func(),
+	onDisconnect func(),
+) <-chan struct{} {
+	var connMutex sync.Mutex
 	var conn *websocket.Conn
+	var wg sync.WaitGroup
+	stopped := make(chan struct{})
 
 	// if we ever get a cancellation from the context, try to close the connection
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		<-ctx.Done()
-		closed = true
+		connMutex.Lock()
+		defer connMutex.Unlock()
 		if conn != nil {
 			conn.Close()
 		}
 	}()
 
-	// retry connecting until we get a connection
-	for {
-		var err error
-		log.Debug().Msgf("WebSocket connection connecting: %s", url)
-		conn, _, err = websocket.DefaultDialer.Dial(url, nil)
-		if err != nil {
-			log.Error().Msgf("WebSocket connection failed: %s\nReconnecting in 2 seconds...", err)
-			if closed {
-				break
-			}
-			time.Sleep(2 * time.Second)
-			continue
+	setConn := func(newConn *websocket.Conn) {
+		connMutex.Lock()
+		defer connMutex.Unlock()
+		conn = newConn
+		// the context might have been cancelled while we were dialing
+		if ctx.Err() != nil {
+			conn.Close()
 		}
-		break
 	}
 
-	// now that we have a connection, if we haven't been closed yet, forever
-	// read from the connection and send messages down the channel, unless we
-	// fail a read in which case we try to reconnect
-	if !closed {
+	firstConn := dialWebSocket(getURL, tlsConfig, ctx)
+	if firstConn == nil {
 		go func() {
-			for {
-				messageType, p, err := conn.ReadMessage()
-				if err != nil {
-					if closed {
-						return
-					}
-					log.Error().Msgf("Read error: %s\nReconnecting in 2 seconds...", err)
-					time.Sleep(2 * time.Second)
-					conn = ConnectWebSocket(url, messageChan, ctx)
-					// exit this goroutine now, another one will be spawned if
-					// the recursive call to ConnectWebSocket succeeds. Not
-					// exiting this goroutine here will cause goroutines to pile
-					// up forever concurrently calling conn.ReadMessage(), which
-					// is not thread-safe.
+			wg.Wait()
+			close(stopped)
+		}()
+		return stopped
+	}
+	setConn(firstConn)
+
+	// now that we have a connection, forever read from the connection and send
+	// messages down the channel, unless we fail a read in which case we
+	// reconnect and carry on reading from the new connection
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		currentConn := firstConn
+		for {
+			messageType, p, err := currentConn.ReadMessage()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Error().Msgf("WebSocket read error: %s - reconnecting", err)
+				currentConn.Close()
+				if onDisconnect != nil {
+					onDisconnect()
+				}
+				currentConn = dialWebSocket(getURL, tlsConfig, ctx)
+				if currentConn == nil {
 					return
 				}
-				if messageType == websocket.TextMessage {
-					log.Debug().
-						Str("action", "ws READ").
-						Str("payload", string(p)).
-						Msgf("")
-					messageChan <- p
+				setConn(currentConn)
+				log.Info().Msgf("WebSocket reconnected: %s", getURL())
+				if onReconnect != nil {
+					onReconnect()
 				}
+				continue
 			}
-		}()
-	}
+			if messageType == websocket.TextMessage {
+				log.Debug().
+					Str("action", "ws READ").
+					Str("payload", string(p)).
+					Msgf("")
+				select {
+				case messageChan <- p:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	return stopped
+}
 
-	return conn
+// keep dialing with exponential backoff until we get a connection
+// returns nil if the context is cancelled before we do
+func dialWebSocket(getURL func() string, tlsConfig *tls.Config, ctx context.Context) *websocket.Conn {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
+	delay := WEBSOCKET_RECONNECT_BASE_DELAY
+	for {
+		url := getURL()
+		log.Debug().Msgf("WebSocket connection connecting: %s", url)
+		conn, _, err := dialer.DialContext(ctx, url, nil)
+		if err == nil {
+			return conn
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Error().Msgf("WebSocket connection failed: %s\nReconnecting in %s...", err, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		delay *= 2
+		if delay > WEBSOCKET_RECONNECT_MAX_DELAY {
+			delay = WEBSOCKET_RECONNECT_MAX_DELAY
+		}
+	}
 }
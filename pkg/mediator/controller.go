@@ -45,7 +45,7 @@ func NewMediatorController(
 ) (*MediatorController, error) {
 	log.Debug().Msgf("begin NewMediatorController")
 	// we know the address of the solver but what is it's url?
-	solverUrl, err := web3SDK.GetSolverUrl(options.Services.Solver)
+	solverUrl, err := web3SDK.GetSolverUrl(context.Background(), options.Services.Solver)
 	if err != nil {
 		log.Error().Msgf("error GetSolverUrl")
 		return nil, err
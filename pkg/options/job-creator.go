@@ -76,6 +76,10 @@ func CheckJobCreatorOptions(options jobcreator.JobCreatorOptions) error {
 	if err != nil {
 		return err
 	}
+	err = CheckPricingEnv()
+	if err != nil {
+		return err
+	}
 
 	if options.Mediation.CheckResultsPercentage < 0 || options.Mediation.CheckResultsPercentage > 100 {
 		return fmt.Errorf("mediation-chance must be between 0 and 100")
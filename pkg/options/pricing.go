@@ -1,10 +1,69 @@
 package options
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
 	"github.com/bacalhau-project/lilypad/pkg/data"
 	"github.com/spf13/cobra"
 )
 
+// the env vars for the DealPricing fields that are token amounts
+var pricingAmountEnvNames = []string{
+	"PRICING_INSTRUCTION_PRICE",
+	"PRICING_PAYMENT_COLLATERAL",
+	"PRICING_MEDIATION_FEE",
+}
+
+// a flag for a DealPricing amount written as a decimal e.g. "2" or "1.5 LP"
+type pricingAmountValue uint64
+
+func (value *pricingAmountValue) Set(amount string) error {
+	parsed, err := data.ParsePricingAmount(amount)
+	if err != nil {
+		return err
+	}
+	*value = pricingAmountValue(parsed)
+	return nil
+}
+
+func (value *pricingAmountValue) String() string {
+	return data.FormatPricingAmount(uint64(*value))
+}
+
+func (value *pricingAmountValue) Type() string {
+	return "amount"
+}
+
+// a bad amount falls back to the default here so use CheckPricingEnv to report it
+func GetDefaultServeOptionPricingAmount(envName string, defaultValue uint64) uint64 {
+	envValue := os.Getenv(envName)
+	if envValue != "" {
+		amount, err := data.ParsePricingAmount(envValue)
+		if err == nil {
+			return amount
+		}
+	}
+	return defaultValue
+}
+
+// the pricing env vars are read before we can return errors so we check them again here
+func CheckPricingEnv() error {
+	errs := []error{}
+	for _, envName := range pricingAmountEnvNames {
+		envValue := os.Getenv(envName)
+		if envValue == "" {
+			continue
+		}
+		_, err := data.ParsePricingAmount(envValue)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func GetDefaultPricingMode(mode data.PricingMode) data.PricingMode {
 	return data.PricingMode(GetDefaultServeOptionString("PRICING_MODE", string(mode)))
 }
@@ -12,13 +71,13 @@ func GetDefaultPricingMode(mode data.PricingMode) data.PricingMode {
 func GetDefaultPricingOptions() data.DealPricing {
 	return data.DealPricing{
 		// let's make the default price 1 ether
-		InstructionPrice: GetDefaultServeOptionUint64("PRICING_INSTRUCTION_PRICE", 1),
+		InstructionPrice: GetDefaultServeOptionPricingAmount("PRICING_INSTRUCTION_PRICE", 1*data.PRICING_TOKEN),
 		// 2 x ether for payment collateral (assuming modules that have a single instruction count)
-		PaymentCollateral: GetDefaultServeOptionUint64("PRICING_PAYMENT_COLLATERAL", 2),
+		PaymentCollateral: GetDefaultServeOptionPricingAmount("PRICING_PAYMENT_COLLATERAL", 2*data.PRICING_TOKEN),
 		// 2 x results collateral multiple
 		ResultsCollateralMultiple: GetDefaultServeOptionUint64("PRICING_RESULTS_COLLATERAL_MULTIPLE", 2),
 		// 1 ether for mediation fee
-		MediationFee: GetDefaultServeOptionUint64("PRICING_MEDIATION_FEE", 1),
+		MediationFee: GetDefaultServeOptionPricingAmount("PRICING_MEDIATION_FEE", 1*data.PRICING_TOKEN),
 	}
 }
 
@@ -30,20 +89,20 @@ func AddPricingModeCliFlags(cmd *cobra.Command, pricingMode *data.PricingMode) {
 }
 
 func AddPricingCliFlags(cmd *cobra.Command, pricingConfig *data.DealPricing) {
-	cmd.PersistentFlags().Uint64Var(
-		&pricingConfig.InstructionPrice, "pricing-instruction-price", pricingConfig.InstructionPrice,
-		`The price per instruction to offer (PRICING_INSTRUCTION_PRICE)`,
+	cmd.PersistentFlags().Var(
+		(*pricingAmountValue)(&pricingConfig.InstructionPrice), "pricing-instruction-price",
+		`The price per instruction to offer in LP e.g. "1.5 LP" (PRICING_INSTRUCTION_PRICE)`,
 	)
-	cmd.PersistentFlags().Uint64Var(
-		&pricingConfig.PaymentCollateral, "pricing-payment-collateral", pricingConfig.PaymentCollateral,
-		`The payment collateral (PRICING_PAYMENT_COLLATERAL)`,
+	cmd.PersistentFlags().Var(
+		(*pricingAmountValue)(&pricingConfig.PaymentCollateral), "pricing-payment-collateral",
+		`The payment collateral in LP (PRICING_PAYMENT_COLLATERAL)`,
 	)
 	cmd.PersistentFlags().Uint64Var(
 		&pricingConfig.ResultsCollateralMultiple, "pricing-results-collateral-multiple", pricingConfig.ResultsCollateralMultiple,
 		`The results collateral multiple (PRICING_RESULTS_COLLATERAL_MULTIPLE)`,
 	)
-	cmd.PersistentFlags().Uint64Var(
-		&pricingConfig.MediationFee, "pricing-mediation-fee", pricingConfig.MediationFee,
-		`The mediation fee (PRICING_MEDIATION_FEE)`,
+	cmd.PersistentFlags().Var(
+		(*pricingAmountValue)(&pricingConfig.MediationFee), "pricing-mediation-fee",
+		`The mediation fee in LP (PRICING_MEDIATION_FEE)`,
 	)
 }
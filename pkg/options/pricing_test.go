@@ -0,0 +1,28 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPricingAmountsReachTheChainExactly(t *testing.T) {
+	t.Setenv("PRICING_INSTRUCTION_PRICE", "1.5 LP")
+	t.Setenv("PRICING_MEDIATION_FEE", "0.000001")
+	assert.NoError(t, CheckPricingEnv())
+	pricing := GetDefaultPricingOptions()
+	onChain := data.ConvertDealPricing(pricing)
+	assert.Equal(t, "1500000000000000000", onChain.InstructionPrice.String())
+	assert.Equal(t, "2000000000000000000", onChain.PaymentCollateral.String(), "Unset amounts should use the default")
+	assert.Equal(t, "1000000000000", onChain.MediationFee.String())
+
+	// above 2^53 a float64 would round this
+	pricing.InstructionPrice = 1<<53 + 1
+	assert.Equal(t, "9007199254740993000000000000", data.ConvertDealPricing(pricing).InstructionPrice.String())
+
+	value := pricingAmountValue(0)
+	assert.NoError(t, value.Set("2.25 LP"))
+	assert.Equal(t, "2.25 LP", value.String())
+	assert.Error(t, value.Set("1.0000001"))
+}
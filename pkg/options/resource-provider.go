@@ -2,8 +2,10 @@ package options
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/http"
 	"github.com/bacalhau-project/lilypad/pkg/resourceprovider"
 	"github.com/bacalhau-project/lilypad/pkg/system"
 	"github.com/spf13/cobra"
@@ -11,9 +13,55 @@ import (
 
 func NewResourceProviderOptions() resourceprovider.ResourceProviderOptions {
 	options := resourceprovider.ResourceProviderOptions{
-		Bacalhau: GetDefaultBacalhauOptions(),
-		Offers:   GetDefaultResourceProviderOfferOptions(),
-		Web3:     GetDefaultWeb3Options(),
+		Bacalhau:            GetDefaultBacalhauOptions(),
+		Offers:              GetDefaultResourceProviderOfferOptions(),
+		Web3:                GetDefaultWeb3Options(),
+		SolverTLSClientCert: GetDefaultServeOptionString("SOLVER_TLS_CLIENT_CERT", ""),
+		SolverTLSClientKey:  GetDefaultServeOptionString("SOLVER_TLS_CLIENT_KEY", ""),
+		SolverTLSCACert:     GetDefaultServeOptionString("SOLVER_TLS_CA_CERT", ""),
+
+		SolverTimeout:         GetDefaultServeOptionDuration("SOLVER_TIMEOUT", http.DEFAULT_CLIENT_TIMEOUT),
+		SolverMaxIdleConns:    GetDefaultServeOptionInt("SOLVER_MAX_IDLE_CONNS", 0),
+		SolverMaxConnsPerHost: GetDefaultServeOptionInt("SOLVER_MAX_CONNS_PER_HOST", 0),
+		SolverIdleConnTimeout: GetDefaultServeOptionDuration("SOLVER_IDLE_CONN_TIMEOUT", 0),
+		SolverKeepAlive:       GetDefaultServeOptionDuration("SOLVER_KEEP_ALIVE", 0),
+		SolverForceHTTP2:      GetDefaultServeOptionBool("SOLVER_FORCE_HTTP2", false),
+		SolverGetRetryMax:     GetDefaultServeOptionInt("SOLVER_GET_RETRY_MAX", 0),
+		SolverGetRetryWaitMin: GetDefaultServeOptionDuration("SOLVER_GET_RETRY_WAIT_MIN", 0),
+		SolverGetRetryWaitMax: GetDefaultServeOptionDuration("SOLVER_GET_RETRY_WAIT_MAX", 0),
+		// by default we give a deal 5 attempts starting 5 seconds apart
+		AgreeMaxAttempts:     GetDefaultServeOptionInt("AGREE_MAX_ATTEMPTS", 5),                      //nolint:gomnd
+		AgreeRetryBaseDelay:  GetDefaultServeOptionDuration("AGREE_RETRY_BASE_DELAY", 5*time.Second), //nolint:gomnd
+		AgreeConcurrency:     GetDefaultServeOptionInt("AGREE_CONCURRENCY", 4),                       //nolint:gomnd
+		MaxConcurrentDeals:   GetDefaultServeOptionInt("MAX_CONCURRENT_DEALS", 0),
+		MaxDealAge:           GetDefaultServeOptionDuration("MAX_DEAL_AGE", 0),
+		SimulateBeforeSend:   GetDefaultServeOptionBool("SIMULATE_BEFORE_SEND", false),
+		OfferRateLimit:       GetDefaultServeOptionFloat64("OFFER_RATE_LIMIT", 0),
+		OfferRateBurst:       GetDefaultServeOptionInt("OFFER_RATE_BURST", 1),
+		ShareRateLimiter:     GetDefaultServeOptionBool("SHARE_RATE_LIMITER", false),
+		SolveInterval:        GetDefaultServeOptionDuration("SOLVE_INTERVAL", resourceprovider.CONTROL_LOOP_INTERVAL),
+		MaxSolveInterval:     GetDefaultServeOptionDuration("MAX_SOLVE_INTERVAL", time.Minute),
+		SolveJitter:          GetDefaultServeOptionFloat64("SOLVE_JITTER", 0.1),             //nolint:gomnd
+		SolveTimeout:         GetDefaultServeOptionDuration("SOLVE_TIMEOUT", 2*time.Minute), //nolint:gomnd
+		SolveSummaryLog:      GetDefaultServeOptionBool("SOLVE_SUMMARY_LOG", true),
+		MaxConsecutiveErrors: GetDefaultServeOptionInt("MAX_CONSECUTIVE_ERRORS", 0),
+		EventDedupeWindow:    GetDefaultServeOptionDuration("EVENT_DEDUPE_WINDOW", time.Minute),
+		StartupTimeout:       GetDefaultServeOptionDuration("STARTUP_TIMEOUT", time.Minute),
+		MetricsPort:          GetDefaultServeOptionInt("METRICS_PORT", 0),
+		OtelExporterEndpoint: GetDefaultServeOptionString("OTEL_EXPORTER_ENDPOINT", ""),
+		HealthPort:           GetDefaultServeOptionInt("HEALTH_PORT", 0),
+		ShutdownGracePeriod:  GetDefaultServeOptionDuration("SHUTDOWN_GRACE_PERIOD", 30*time.Second), //nolint:gomnd
+		DryRun:               GetDefaultServeOptionBool("DRY_RUN", false),
+		ObserverMode:         GetDefaultServeOptionBool("OBSERVER_MODE", false),
+		StateFile:            GetDefaultServeOptionString("STATE_FILE", ""),
+		AuditLogFile:         GetDefaultServeOptionString("AUDIT_LOG_FILE", ""),
+		AuditLogMaxSize:      GetDefaultServeOptionInt("AUDIT_LOG_MAX_SIZE", 100), //nolint:gomnd
+		// by default offers are left up so they are still there when we restart
+		RemoveOffersOnShutdown: GetDefaultServeOptionBool("REMOVE_OFFERS_ON_SHUTDOWN", false),
+		DrainTimeout:           GetDefaultServeOptionDuration("DRAIN_TIMEOUT", 30*time.Minute), //nolint:gomnd
+		JobCreatorAllowlist:    GetDefaultServeOptionStringArray("JOB_CREATOR_ALLOWLIST", []string{}),
+		JobCreatorDenylist:     GetDefaultServeOptionStringArray("JOB_CREATOR_DENYLIST", []string{}),
+		Webhook:                GetDefaultWebhookOptions(),
 	}
 	options.Web3.Service = system.ResourceProviderService
 	return options
@@ -22,14 +70,25 @@ func NewResourceProviderOptions() resourceprovider.ResourceProviderOptions {
 func GetDefaultResourceProviderOfferOptions() resourceprovider.ResourceProviderOfferOptions {
 	return resourceprovider.ResourceProviderOfferOptions{
 		// by default let's offer 1 CPU, 0 GPU and 1GB RAM
-		OfferSpec: data.MachineSpec{
-			CPU: GetDefaultServeOptionInt("OFFER_CPU", 1000), //nolint:gomnd
-			GPU: GetDefaultServeOptionInt("OFFER_GPU", 0),    //nolint:gomnd
-			RAM: GetDefaultServeOptionInt("OFFER_RAM", 1024), //nolint:gomnd
+		OfferSpec: resourceprovider.OfferSpec{
+			MachineSpec: data.MachineSpec{
+				CPU: GetDefaultServeOptionInt("OFFER_CPU", 1000), //nolint:gomnd
+				GPU: GetDefaultServeOptionInt("OFFER_GPU", 0),    //nolint:gomnd
+				RAM: GetDefaultServeOptionInt("OFFER_RAM", 1024), //nolint:gomnd
+				// only needed by RPs offering GPUs
+				GPUModel: GetDefaultServeOptionString("OFFER_GPU_MODEL", ""),
+				VRAM:     GetDefaultServeOptionUint64("OFFER_VRAM", 0),
+				// capabilities jobs can target us by e.g. cuda=12,region=eu
+				Labels: GetDefaultServeOptionStringMap("OFFER_LABELS", map[string]string{}),
+			},
+			// fill in the values above from the host instead
+			AutoDetect: GetDefaultServeOptionBool("OFFER_AUTO_DETECT", false),
 		},
 		OfferCount: GetDefaultServeOptionInt("OFFER_COUNT", 1), //nolint:gomnd
 		// this can be populated by a config file
-		Specs: []data.MachineSpec{},
+		Specs: []resourceprovider.OfferSpec{},
+		// how much of the host we offer when auto detecting
+		AutoDetectFraction: GetDefaultServeOptionFloat64("OFFER_AUTO_DETECT_FRACTION", 1),
 		// if an RP wants to only run certain modules they list them here
 		// XXX SECURITY: enforce that they are specified with specific git hashes!
 		Modules: GetDefaultServeOptionStringArray("OFFER_MODULES", []string{}),
@@ -42,6 +101,11 @@ func GetDefaultResourceProviderOfferOptions() resourceprovider.ResourceProviderO
 		ModulePricing:  map[string]data.DealPricing{},
 		ModuleTimeouts: map[string]data.DealTimeouts{},
 		Services:       GetDefaultServicesOptions(),
+		// any extra solvers we also want to post offers to
+		Solvers: GetDefaultServeOptionStringArray("SERVICE_SOLVERS", []string{}),
+		// offers are only refreshed if the solver expires them
+		OfferTTL:           GetDefaultServeOptionDuration("OFFER_TTL", 0),
+		OfferRefreshMargin: GetDefaultServeOptionDuration("OFFER_REFRESH_MARGIN", time.Minute),
 	}
 }
 
@@ -54,10 +118,30 @@ func AddResourceProviderOfferCliFlags(cmd *cobra.Command, offerOptions *resource
 		&offerOptions.OfferSpec.GPU, "offer-gpu", offerOptions.OfferSpec.GPU,
 		`How many milli-gpus to offer the network (OFFER_GPU).`,
 	)
+	cmd.PersistentFlags().StringVar(
+		&offerOptions.OfferSpec.GPUModel, "offer-gpu-model", offerOptions.OfferSpec.GPUModel,
+		`The model of the gpus we are offering (OFFER_GPU_MODEL).`,
+	)
+	cmd.PersistentFlags().Uint64Var(
+		&offerOptions.OfferSpec.VRAM, "offer-vram", offerOptions.OfferSpec.VRAM,
+		`How many megabytes of memory each gpu we are offering has (OFFER_VRAM).`,
+	)
 	cmd.PersistentFlags().IntVar(
 		&offerOptions.OfferSpec.RAM, "offer-ram", offerOptions.OfferSpec.RAM,
 		`How many megabytes of RAM to offer the network (OFFER_RAM).`,
 	)
+	cmd.PersistentFlags().StringToStringVar(
+		&offerOptions.OfferSpec.Labels, "offer-labels", offerOptions.OfferSpec.Labels,
+		`Labels jobs can target us by e.g. cuda=12,region=eu (OFFER_LABELS).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&offerOptions.OfferSpec.AutoDetect, "offer-auto-detect", offerOptions.OfferSpec.AutoDetect,
+		`Offer the cpu, ram and gpu detected on this host instead of the values above (OFFER_AUTO_DETECT).`,
+	)
+	cmd.PersistentFlags().Float64Var(
+		&offerOptions.AutoDetectFraction, "offer-auto-detect-fraction", offerOptions.AutoDetectFraction,
+		`What fraction of the detected host resources to offer (OFFER_AUTO_DETECT_FRACTION).`,
+	)
 	cmd.PersistentFlags().IntVar(
 		&offerOptions.OfferCount, "offer-count", offerOptions.OfferCount,
 		`How many machines will we offer using the cpu, ram and gpu settings (OFFER_COUNT).`,
@@ -70,15 +154,259 @@ func AddResourceProviderOfferCliFlags(cmd *cobra.Command, offerOptions *resource
 	AddPricingCliFlags(cmd, &offerOptions.DefaultPricing)
 	AddTimeoutCliFlags(cmd, &offerOptions.DefaultTimeouts)
 	AddServicesCliFlags(cmd, &offerOptions.Services)
+	cmd.PersistentFlags().StringSliceVar(
+		&offerOptions.Solvers, "service-solvers", offerOptions.Solvers,
+		`Extra solvers to also post our offers to (SERVICE_SOLVERS)`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&offerOptions.OfferTTL, "offer-ttl", offerOptions.OfferTTL,
+		`How long the solver keeps our offers before expiring them, 0 if it never does (OFFER_TTL).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&offerOptions.OfferRefreshMargin, "offer-refresh-margin", offerOptions.OfferRefreshMargin,
+		`How long before they expire to post our offers again (OFFER_REFRESH_MARGIN).`,
+	)
+}
+
+func GetDefaultWebhookOptions() resourceprovider.WebhookOptions {
+	return resourceprovider.WebhookOptions{
+		URL:             GetDefaultServeOptionString("WEBHOOK_URL", ""),
+		DealAgreed:      GetDefaultServeOptionBool("WEBHOOK_DEAL_AGREED", true),
+		AgreeFailed:     GetDefaultServeOptionBool("WEBHOOK_AGREE_FAILED", true),
+		DealStateChange: GetDefaultServeOptionBool("WEBHOOK_DEAL_STATE_CHANGE", true),
+		RetryMax:        GetDefaultServeOptionInt("WEBHOOK_RETRY_MAX", 3), //nolint:gomnd
+		Timeout:         GetDefaultServeOptionDuration("WEBHOOK_TIMEOUT", resourceprovider.WEBHOOK_DEFAULT_TIMEOUT),
+	}
+}
+
+func AddWebhookCliFlags(cmd *cobra.Command, options *resourceprovider.WebhookOptions) {
+	cmd.PersistentFlags().StringVar(
+		&options.URL, "webhook-url", options.URL,
+		`A url to POST deal events to as json, empty to disable (WEBHOOK_URL).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.DealAgreed, "webhook-deal-agreed", options.DealAgreed,
+		`Post an event when our agree tx for a deal is mined (WEBHOOK_DEAL_AGREED).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.AgreeFailed, "webhook-agree-failed", options.AgreeFailed,
+		`Post an event when an attempt to agree to a deal fails (WEBHOOK_AGREE_FAILED).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.DealStateChange, "webhook-deal-state-change", options.DealStateChange,
+		`Post an event when one of our deals changes state on-chain (WEBHOOK_DEAL_STATE_CHANGE).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.RetryMax, "webhook-retry-max", options.RetryMax,
+		`How many times to retry a failed post to the webhook (WEBHOOK_RETRY_MAX).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.Timeout, "webhook-timeout", options.Timeout,
+		`How long a single post to the webhook can take (WEBHOOK_TIMEOUT).`,
+	)
 }
 
 func AddResourceProviderCliFlags(cmd *cobra.Command, options *resourceprovider.ResourceProviderOptions) {
 	AddBacalhauCliFlags(cmd, &options.Bacalhau)
 	AddWeb3CliFlags(cmd, &options.Web3)
 	AddResourceProviderOfferCliFlags(cmd, &options.Offers)
+	AddWebhookCliFlags(cmd, &options.Webhook)
+	cmd.PersistentFlags().IntVar(
+		&options.AgreeMaxAttempts, "agree-max-attempts", options.AgreeMaxAttempts,
+		`How many times to try submitting an agree tx for a deal before giving up (AGREE_MAX_ATTEMPTS).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.AgreeRetryBaseDelay, "agree-retry-base-delay", options.AgreeRetryBaseDelay,
+		`How long to wait before retrying a failed agree tx, doubled for each attempt (AGREE_RETRY_BASE_DELAY).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.AgreeConcurrency, "agree-concurrency", options.AgreeConcurrency,
+		`How many deals to agree to in parallel (AGREE_CONCURRENCY).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.MaxConcurrentDeals, "max-concurrent-deals", options.MaxConcurrentDeals,
+		`The most deals to have running at once, 0 for no limit (MAX_CONCURRENT_DEALS).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.MaxDealAge, "max-deal-age", options.MaxDealAge,
+		`Skip polled deals whose job offer is older than this unless we just heard about them, 0 for no limit (MAX_DEAL_AGE).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.SimulateBeforeSend, "simulate-before-send", options.SimulateBeforeSend,
+		`Simulate each agree tx with eth_call and skip deals it would revert for, off by default (SIMULATE_BEFORE_SEND).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&options.SolverTLSClientCert, "solver-tls-client-cert", options.SolverTLSClientCert,
+		`The PEM client certificate to present to solvers that want mutual tls (SOLVER_TLS_CLIENT_CERT).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&options.SolverTLSClientKey, "solver-tls-client-key", options.SolverTLSClientKey,
+		`The PEM key for the solver client certificate (SOLVER_TLS_CLIENT_KEY).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&options.SolverTLSCACert, "solver-tls-ca-cert", options.SolverTLSCACert,
+		`The PEM CA certificate used to verify solvers, defaults to the system roots (SOLVER_TLS_CA_CERT).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolverTimeout, "solver-timeout", options.SolverTimeout,
+		`How long a single request to a solver can take (SOLVER_TIMEOUT).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.SolverMaxIdleConns, "solver-max-idle-conns", options.SolverMaxIdleConns,
+		`How many idle connections to keep open to solvers, 0 for the default (SOLVER_MAX_IDLE_CONNS).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.SolverMaxConnsPerHost, "solver-max-conns-per-host", options.SolverMaxConnsPerHost,
+		`The most connections to open to a single solver, 0 for no limit (SOLVER_MAX_CONNS_PER_HOST).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolverIdleConnTimeout, "solver-idle-conn-timeout", options.SolverIdleConnTimeout,
+		`How long an idle connection to a solver is kept for reuse, 0 for the default of 90s (SOLVER_IDLE_CONN_TIMEOUT).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolverKeepAlive, "solver-keep-alive", options.SolverKeepAlive,
+		`How often tcp keep-alive probes are sent to solvers, 0 for the default of 30s and negative to turn them off (SOLVER_KEEP_ALIVE).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.SolverForceHTTP2, "solver-force-http2", options.SolverForceHTTP2,
+		`Only talk HTTP/2 to solvers, using h2c for plain http - otherwise it is used when the solver offers it (SOLVER_FORCE_HTTP2).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.SolverGetRetryMax, "solver-get-retry-max", options.SolverGetRetryMax,
+		`How many times to retry a failed read from a solver, 0 for the default of 10 (SOLVER_GET_RETRY_MAX).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolverGetRetryWaitMin, "solver-get-retry-wait-min", options.SolverGetRetryWaitMin,
+		`The shortest wait before retrying a read from a solver, 0 for the default (SOLVER_GET_RETRY_WAIT_MIN).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolverGetRetryWaitMax, "solver-get-retry-wait-max", options.SolverGetRetryWaitMax,
+		`The longest wait before retrying a read from a solver, 0 for the default (SOLVER_GET_RETRY_WAIT_MAX).`,
+	)
+	cmd.PersistentFlags().Float64Var(
+		&options.OfferRateLimit, "offer-rate-limit", options.OfferRateLimit,
+		`The most resource offers to post per second, 0 for no limit (OFFER_RATE_LIMIT).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.OfferRateBurst, "offer-rate-burst", options.OfferRateBurst,
+		`How many resource offers can be posted at once before the rate limit applies (OFFER_RATE_BURST).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.ShareRateLimiter, "share-rate-limiter", options.ShareRateLimiter,
+		`Apply the offer rate limit to agree txs as well (SHARE_RATE_LIMITER).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolveInterval, "solve-interval", options.SolveInterval,
+		`How long to wait between background solves (SOLVE_INTERVAL).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.MaxSolveInterval, "max-solve-interval", options.MaxSolveInterval,
+		`The longest we will back off between solves when there is no work (MAX_SOLVE_INTERVAL).`,
+	)
+	cmd.PersistentFlags().Float64Var(
+		&options.SolveJitter, "solve-jitter", options.SolveJitter,
+		`Randomly move each wait between solves by up to this fraction of the interval, 0 to disable (SOLVE_JITTER).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.SolveTimeout, "solve-timeout", options.SolveTimeout,
+		`How long a single solve can take before it is abandoned (SOLVE_TIMEOUT).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.SolveSummaryLog, "solve-summary-log", options.SolveSummaryLog,
+		`Log a one line summary of what each solve did (SOLVE_SUMMARY_LOG).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.MaxConsecutiveErrors, "max-consecutive-errors", options.MaxConsecutiveErrors,
+		`Stop once this many solves in a row have failed, 0 to keep going (MAX_CONSECUTIVE_ERRORS).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.EventDedupeWindow, "event-dedupe-window", options.EventDedupeWindow,
+		`Ignore repeated solver events for the same deal within this window, 0 to disable (EVENT_DEDUPE_WINDOW).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.StartupTimeout, "startup-timeout", options.StartupTimeout,
+		`How long the web3 calls made at startup can take before we give up, 0 for no limit (STARTUP_TIMEOUT).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.MetricsPort, "metrics-port", options.MetricsPort,
+		`The port to serve prometheus metrics on, 0 to disable (METRICS_PORT).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&options.OtelExporterEndpoint, "otel-exporter-endpoint", options.OtelExporterEndpoint,
+		`The otlp/http collector to send traces to e.g. http://otel-collector:4318, empty to disable (OTEL_EXPORTER_ENDPOINT).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.HealthPort, "health-port", options.HealthPort,
+		`The port to serve /healthz and /readyz on, 0 to disable (HEALTH_PORT).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.ShutdownGracePeriod, "shutdown-grace-period", options.ShutdownGracePeriod,
+		`How long to wait for in-flight agree txs when shutting down (SHUTDOWN_GRACE_PERIOD).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.RemoveOffersOnShutdown, "remove-offers-on-shutdown", options.RemoveOffersOnShutdown,
+		`Remove our resource offers from the solver when shutting down (REMOVE_OFFERS_ON_SHUTDOWN).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&options.DrainTimeout, "drain-timeout", options.DrainTimeout,
+		`On SIGTERM, how long to wait for running deals to finish before shutting down (DRAIN_TIMEOUT).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&options.StateFile, "state-file", options.StateFile,
+		`A file to keep track of agree txs and offers in across restarts (STATE_FILE).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&options.AuditLogFile, "audit-log-file", options.AuditLogFile,
+		`A file to append a json line to for every deal we agree to (AUDIT_LOG_FILE).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&options.AuditLogMaxSize, "audit-log-max-size", options.AuditLogMaxSize,
+		`How many megabytes the audit log can grow to before it is rotated, 0 for no limit (AUDIT_LOG_MAX_SIZE).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.DryRun, "dry-run", options.DryRun,
+		`Log the offers and agree txs we would make without submitting them (DRY_RUN).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&options.ObserverMode, "observer-mode", options.ObserverMode,
+		`Only watch solver and chain events for every deal, never post offers or agree to deals (OBSERVER_MODE).`,
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&options.JobCreatorAllowlist, "job-creator-allowlist", options.JobCreatorAllowlist,
+		`Only take deals from these job creators, empty means anyone (JOB_CREATOR_ALLOWLIST).`,
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&options.JobCreatorDenylist, "job-creator-denylist", options.JobCreatorDenylist,
+		`Never take deals from these job creators (JOB_CREATOR_DENYLIST).`,
+	)
 }
 
 func CheckResourceProviderOfferOptions(options resourceprovider.ResourceProviderOfferOptions) error {
+	err := CheckPricingEnv()
+	if err != nil {
+		return err
+	}
+	if options.AutoDetectFraction <= 0 || options.AutoDetectFraction > 1 {
+		return fmt.Errorf("OFFER_AUTO_DETECT_FRACTION must be greater than zero and at most one")
+	}
+	if options.OfferTTL < 0 || options.OfferRefreshMargin < 0 {
+		return fmt.Errorf("OFFER_TTL and OFFER_REFRESH_MARGIN cannot be negative")
+	}
+	if options.OfferTTL > 0 && options.OfferRefreshMargin >= options.OfferTTL {
+		return fmt.Errorf("OFFER_REFRESH_MARGIN must be less than OFFER_TTL")
+	}
+
+	// auto detected specs are filled in when we start so there is nothing to check yet
+	autoDetect := false
+	for _, spec := range options.Specs {
+		if spec.AutoDetect {
+			autoDetect = true
+		}
+	}
+	if autoDetect {
+		return nil
+	}
+
 	// loop over all specs and add up the total number of cpus
 	totalCPU := 0
 	for _, spec := range options.Specs {
@@ -107,17 +435,59 @@ func CheckResourceProviderOptions(options resourceprovider.ResourceProviderOptio
 	if err != nil {
 		return err
 	}
-	err = CheckResourceProviderOfferOptions(options.Offers)
-	if err != nil {
-		return err
-	}
 	err = CheckServicesOptions(options.Offers.Services)
 	if err != nil {
 		return err
 	}
-	err = CheckBacalhauOptions(options.Bacalhau)
-	if err != nil {
-		return err
+	// an observer makes no offers and runs no jobs
+	if !options.ObserverMode {
+		err = CheckResourceProviderOfferOptions(options.Offers)
+		if err != nil {
+			return err
+		}
+		err = CheckBacalhauOptions(options.Bacalhau)
+		if err != nil {
+			return err
+		}
+	}
+	if options.AgreeConcurrency <= 0 {
+		return fmt.Errorf("AGREE_CONCURRENCY must be greater than zero")
+	}
+	if options.MaxConcurrentDeals < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_DEALS cannot be negative")
+	}
+	if options.MaxDealAge < 0 {
+		return fmt.Errorf("MAX_DEAL_AGE cannot be negative")
+	}
+	if options.OfferRateLimit < 0 {
+		return fmt.Errorf("OFFER_RATE_LIMIT cannot be negative")
+	}
+	if options.SolveJitter < 0 || options.SolveJitter >= 1 {
+		return fmt.Errorf("SOLVE_JITTER must be at least 0 and less than 1")
+	}
+	if options.SolverTimeout < 0 {
+		return fmt.Errorf("SOLVER_TIMEOUT cannot be negative")
+	}
+	if options.SolverMaxIdleConns < 0 || options.SolverMaxConnsPerHost < 0 {
+		return fmt.Errorf("SOLVER_MAX_IDLE_CONNS and SOLVER_MAX_CONNS_PER_HOST cannot be negative")
+	}
+	if options.SolverIdleConnTimeout < 0 {
+		return fmt.Errorf("SOLVER_IDLE_CONN_TIMEOUT cannot be negative")
+	}
+	if options.SolverGetRetryMax < 0 || options.SolverGetRetryWaitMin < 0 || options.SolverGetRetryWaitMax < 0 {
+		return fmt.Errorf("SOLVER_GET_RETRY_MAX, SOLVER_GET_RETRY_WAIT_MIN and SOLVER_GET_RETRY_WAIT_MAX cannot be negative")
+	}
+	if options.MaxConsecutiveErrors < 0 {
+		return fmt.Errorf("MAX_CONSECUTIVE_ERRORS cannot be negative")
+	}
+	if options.SolveTimeout <= 0 {
+		return fmt.Errorf("SOLVE_TIMEOUT must be greater than zero")
+	}
+	if options.DrainTimeout <= 0 {
+		return fmt.Errorf("DRAIN_TIMEOUT must be greater than zero")
+	}
+	if options.StartupTimeout < 0 {
+		return fmt.Errorf("STARTUP_TIMEOUT cannot be negative")
 	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func GetDefaultServeOptionString(envName string, defaultValue string) string {
@@ -35,6 +36,23 @@ func GetDefaultServeOptionStringArray(envName string, defaultValue []string) []s
 	return defaultValue
 }
 
+// key=value pairs separated by commas e.g. "cuda=12,region=eu"
+// pairs without an = are ignored
+func GetDefaultServeOptionStringMap(envName string, defaultValue map[string]string) map[string]string {
+	envValue := os.Getenv(envName)
+	if envValue == "" {
+		return defaultValue
+	}
+	ret := map[string]string{}
+	for _, pair := range strings.Split(envValue, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok {
+			ret[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return ret
+}
+
 func GetDefaultServeOptionInt(envName string, defaultValue int) int {
 	envValue := os.Getenv(envName)
 	if envValue != "" {
@@ -45,3 +63,36 @@ func GetDefaultServeOptionInt(envName string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func GetDefaultServeOptionDuration(envName string, defaultValue time.Duration) time.Duration {
+	envValue := os.Getenv(envName)
+	if envValue != "" {
+		d, err := time.ParseDuration(envValue)
+		if err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func GetDefaultServeOptionBool(envName string, defaultValue bool) bool {
+	envValue := os.Getenv(envName)
+	if envValue != "" {
+		b, err := strconv.ParseBool(envValue)
+		if err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func GetDefaultServeOptionFloat64(envName string, defaultValue float64) float64 {
+	envValue := os.Getenv(envName)
+	if envValue != "" {
+		f, err := strconv.ParseFloat(envValue, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
@@ -12,10 +12,42 @@ import (
 func GetDefaultWeb3Options() web3.Web3Options {
 	return web3.Web3Options{
 
+		// use a local in-memory chain instead of WEB3_RPC_URL
+		Mode:               GetDefaultServeOptionString("WEB3_MODE", web3.Web3ModeRPC),
+		SimulatedSolverURL: GetDefaultServeOptionString("WEB3_SIMULATED_SOLVER_URL", "http://localhost:8080"),
+		// skip looking the solver up on-chain
+		SolverURL: GetDefaultServeOptionString("WEB3_SOLVER_URL", ""),
+
 		// core settings
 		RpcURL:     GetDefaultServeOptionString("WEB3_RPC_URL", "ws://testnet.lilypad.tech:8546"),
 		PrivateKey: GetDefaultServeOptionString("WEB3_PRIVATE_KEY", ""),
 		ChainID:    GetDefaultServeOptionInt("WEB3_CHAIN_ID", 1337), //nolint:gomnd
+		// 0 means we trust whatever chain the rpc node is on
+		ExpectedChainID: GetDefaultServeOptionInt("WEB3_EXPECTED_CHAIN_ID", 0),
+
+		// other nodes to use when WEB3_RPC_URL is down
+		RpcFallbackURLs:         GetDefaultServeOptionStringArray("WEB3_RPC_FALLBACK_URLS", []string{}),
+		RpcPrimaryRetryInterval: GetDefaultServeOptionDuration("WEB3_RPC_PRIMARY_RETRY_INTERVAL", web3.DEFAULT_RPC_PRIMARY_RETRY_INTERVAL),
+
+		// backoff for subscribing to contract events again when the websocket drops
+		EventResubscribeBaseDelay: GetDefaultServeOptionDuration("WEB3_EVENT_RESUBSCRIBE_BASE_DELAY", web3.DEFAULT_EVENT_RESUBSCRIBE_BASE_DELAY),
+		EventResubscribeMaxDelay:  GetDefaultServeOptionDuration("WEB3_EVENT_RESUBSCRIBE_MAX_DELAY", web3.DEFAULT_EVENT_RESUBSCRIBE_MAX_DELAY),
+
+		// other ways of giving the private key so it is not in args or config
+		PrivateKeyPath: GetDefaultServeOptionString("WEB3_PRIVATE_KEY_PATH", ""),
+		PrivateKeyEnv:  GetDefaultServeOptionString("WEB3_PRIVATE_KEY_ENV", ""),
+		// sign with an encrypted keystore file rather than a private key
+		KeystorePath:     GetDefaultServeOptionString("WEB3_KEYSTORE_PATH", ""),
+		KeystorePassword: GetDefaultServeOptionString("WEB3_KEYSTORE_PASSWORD", ""),
+
+		// gas pricing
+		GasStrategy:          GetDefaultServeOptionString("WEB3_GAS_STRATEGY", web3.GasStrategyDefault),
+		GasPrice:             GetDefaultServeOptionUint64("WEB3_GAS_PRICE", 0),
+		GasMultiplier:        GetDefaultServeOptionFloat64("WEB3_GAS_MULTIPLIER", 1),
+		MaxFeePerGas:         GetDefaultServeOptionUint64("WEB3_MAX_FEE_PER_GAS", 0),
+		MaxPriorityFeePerGas: GetDefaultServeOptionUint64("WEB3_MAX_PRIORITY_FEE_PER_GAS", 0),
+		StuckTxTimeout:       GetDefaultServeOptionDuration("WEB3_STUCK_TX_TIMEOUT", 0),
+		GasBumpPercent:       GetDefaultServeOptionInt("WEB3_GAS_BUMP_PERCENT", 20), //nolint:gomnd
 
 		// contract addresses
 		ControllerAddress: GetDefaultServeOptionString("WEB3_CONTROLLER_ADDRESS", "0xCCAaFD2AdD790788436f10e2C84585C46388b9aF"),
@@ -32,10 +64,38 @@ func GetDefaultWeb3Options() web3.Web3Options {
 }
 
 func AddWeb3CliFlags(cmd *cobra.Command, web3Options *web3.Web3Options) {
+	cmd.PersistentFlags().StringVar(
+		&web3Options.Mode, "web3-mode", web3Options.Mode,
+		`Set to simulated to use an in-memory chain for local development instead of the RPC server (WEB3_MODE).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.SimulatedSolverURL, "web3-simulated-solver-url", web3Options.SimulatedSolverURL,
+		`The url of the local solver to use in simulated mode (WEB3_SIMULATED_SOLVER_URL).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.SolverURL, "web3-solver-url", web3Options.SolverURL,
+		`The url of the solver, for solvers that aren't registered on-chain (WEB3_SOLVER_URL).`,
+	)
 	cmd.PersistentFlags().StringVar(
 		&web3Options.RpcURL, "web3-rpc-url", web3Options.RpcURL,
 		`The URL of the web3 RPC server (WEB3_RPC_URL).`,
 	)
+	cmd.PersistentFlags().StringSliceVar(
+		&web3Options.RpcFallbackURLs, "web3-rpc-fallback-urls", web3Options.RpcFallbackURLs,
+		`The URLs of web3 RPC servers to fail over to in order if WEB3_RPC_URL can't be reached (WEB3_RPC_FALLBACK_URLS).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&web3Options.RpcPrimaryRetryInterval, "web3-rpc-primary-retry-interval", web3Options.RpcPrimaryRetryInterval,
+		`How often to check if WEB3_RPC_URL is back after failing over (WEB3_RPC_PRIMARY_RETRY_INTERVAL).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&web3Options.EventResubscribeBaseDelay, "web3-event-resubscribe-base-delay", web3Options.EventResubscribeBaseDelay,
+		`How long to wait before subscribing to a contract event again after it drops, doubled for each failed attempt (WEB3_EVENT_RESUBSCRIBE_BASE_DELAY).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&web3Options.EventResubscribeMaxDelay, "web3-event-resubscribe-max-delay", web3Options.EventResubscribeMaxDelay,
+		`The longest to wait between attempts to subscribe to a contract event again (WEB3_EVENT_RESUBSCRIBE_MAX_DELAY).`,
+	)
 
 	// don't use the env as the default here because otherwise it will show when --help is used
 	// instead we inject the env value into the options after boot if needed
@@ -43,10 +103,58 @@ func AddWeb3CliFlags(cmd *cobra.Command, web3Options *web3.Web3Options) {
 		&web3Options.PrivateKey, "web3-private-key", "",
 		`The private key to use for signing web3 transactions (WEB3_PRIVATE_KEY).`,
 	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.PrivateKeyPath, "web3-private-key-path", web3Options.PrivateKeyPath,
+		`A file containing the private key, instead of --web3-private-key (WEB3_PRIVATE_KEY_PATH).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.PrivateKeyEnv, "web3-private-key-env", web3Options.PrivateKeyEnv,
+		`The name of an env var containing the private key, instead of --web3-private-key (WEB3_PRIVATE_KEY_ENV).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.KeystorePath, "web3-keystore-path", web3Options.KeystorePath,
+		`An encrypted keystore file to sign with instead of a private key (WEB3_KEYSTORE_PATH).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.KeystorePassword, "web3-keystore-password", web3Options.KeystorePassword,
+		`The password for --web3-keystore-path (WEB3_KEYSTORE_PASSWORD).`,
+	)
 	cmd.PersistentFlags().IntVar(
 		&web3Options.ChainID, "web3-chain-id", web3Options.ChainID,
 		`The chain id for the web3 RPC server (WEB3_CHAIN_ID).`,
 	)
+	cmd.PersistentFlags().IntVar(
+		&web3Options.ExpectedChainID, "web3-expected-chain-id", web3Options.ExpectedChainID,
+		`Refuse to start if the web3 RPC server is not on this chain - 0 to not check (WEB3_EXPECTED_CHAIN_ID).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&web3Options.GasStrategy, "web3-gas-strategy", web3Options.GasStrategy,
+		`How to price gas: fixed, multiplier or eip1559 - empty lets the node decide (WEB3_GAS_STRATEGY).`,
+	)
+	cmd.PersistentFlags().Uint64Var(
+		&web3Options.GasPrice, "web3-gas-price", web3Options.GasPrice,
+		`The gas price in wei for the fixed gas strategy (WEB3_GAS_PRICE).`,
+	)
+	cmd.PersistentFlags().Float64Var(
+		&web3Options.GasMultiplier, "web3-gas-multiplier", web3Options.GasMultiplier,
+		`What to multiply the suggested gas price by for the multiplier gas strategy (WEB3_GAS_MULTIPLIER).`,
+	)
+	cmd.PersistentFlags().Uint64Var(
+		&web3Options.MaxFeePerGas, "web3-max-fee-per-gas", web3Options.MaxFeePerGas,
+		`The most we will pay per gas in wei - 0 for no cap (WEB3_MAX_FEE_PER_GAS).`,
+	)
+	cmd.PersistentFlags().Uint64Var(
+		&web3Options.MaxPriorityFeePerGas, "web3-max-priority-fee-per-gas", web3Options.MaxPriorityFeePerGas,
+		`The most we will tip per gas in wei - 0 for no cap (WEB3_MAX_PRIORITY_FEE_PER_GAS).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&web3Options.StuckTxTimeout, "web3-stuck-tx-timeout", web3Options.StuckTxTimeout,
+		`Resend txs with more gas if they are not mined after this long - 0 to never resend (WEB3_STUCK_TX_TIMEOUT).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&web3Options.GasBumpPercent, "web3-gas-bump-percent", web3Options.GasBumpPercent,
+		`How much to raise the gas by in percent when resending a stuck tx (WEB3_GAS_BUMP_PERCENT).`,
+	)
 	cmd.PersistentFlags().StringVar(
 		&web3Options.ControllerAddress, "web3-controller-address", web3Options.ControllerAddress,
 		`The address of the controller contract (WEB3_CONTROLLER_ADDRESS).`,
@@ -70,26 +178,50 @@ func AddWeb3CliFlags(cmd *cobra.Command, web3Options *web3.Web3Options) {
 }
 
 func CheckWeb3Options(options web3.Web3Options) error {
+	switch options.Mode {
+	case web3.Web3ModeRPC, web3.Web3ModeSimulated:
+	default:
+		return fmt.Errorf("unknown WEB3_MODE %q (expected %s or empty)", options.Mode, web3.Web3ModeSimulated)
+	}
+	// the simulated chain has no rpc url and we deploy the contracts ourselves
+	simulated := options.Mode == web3.Web3ModeSimulated
+
 	// core settings
-	if options.RpcURL == "" {
+	if options.RpcURL == "" && !simulated {
 		return fmt.Errorf("WEB3_RPC_URL is required")
 	}
-	if options.PrivateKey == "" {
-		return fmt.Errorf("WEB3_PRIVATE_KEY is required")
+	for _, url := range options.RpcFallbackURLs {
+		if url == "" {
+			return fmt.Errorf("WEB3_RPC_FALLBACK_URLS cannot contain an empty url")
+		}
+	}
+	if options.RpcPrimaryRetryInterval < 0 {
+		return fmt.Errorf("WEB3_RPC_PRIMARY_RETRY_INTERVAL cannot be negative")
+	}
+	if options.EventResubscribeBaseDelay < 0 || options.EventResubscribeMaxDelay < 0 {
+		return fmt.Errorf("WEB3_EVENT_RESUBSCRIBE_BASE_DELAY and WEB3_EVENT_RESUBSCRIBE_MAX_DELAY cannot be negative")
+	}
+	if options.PrivateKey == "" && options.KeystorePath == "" {
+		return fmt.Errorf("WEB3_PRIVATE_KEY or WEB3_KEYSTORE_PATH is required")
 	}
 
 	// this is the only address we actually need
 	// we can load the rest of the addresses from the controller address if needed
-	if options.ControllerAddress == "" {
+	if options.ControllerAddress == "" && !simulated {
 		return fmt.Errorf("WEB3_CONTROLLER_ADDRESS is required")
 	}
 
-	return nil
+	// we would be signing txs for a different chain than the one we expect
+	if options.ExpectedChainID != 0 && options.ExpectedChainID != options.ChainID {
+		return fmt.Errorf("WEB3_EXPECTED_CHAIN_ID (%d) does not match WEB3_CHAIN_ID (%d)", options.ExpectedChainID, options.ChainID)
+	}
+
+	return web3.CheckGasOptions(options)
 }
 
 func ProcessWeb3Options(options web3.Web3Options) (web3.Web3Options, error) {
 	if options.PrivateKey == "" {
 		options.PrivateKey = os.Getenv("WEB3_PRIVATE_KEY")
 	}
-	return options, nil
+	return web3.ResolvePrivateKey(options)
 }
@@ -0,0 +1,115 @@
+package resourceprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+)
+
+// one line of the audit log for each deal we agree to
+type auditRecord struct {
+	DealID           string           `json:"deal_id"`
+	Timestamp        time.Time        `json:"timestamp"`
+	ResourceProvider string           `json:"resource_provider"`
+	JobCreator       string           `json:"job_creator"`
+	Solver           string           `json:"solver"`
+	Pricing          data.DealPricing `json:"pricing"`
+	AgreeTx          string           `json:"agree_tx"`
+}
+
+// an append only record of the deals we have agreed to for settling disputes
+// once the file would grow past maxSize it is moved to path.1 (replacing
+// the one before) and a new file is started
+type auditLog struct {
+	mutex   sync.Mutex
+	path    string
+	maxSize int64
+}
+
+// returns nil if there is no path
+// the file is opened now so a path we can't write to fails on startup
+func newAuditLog(path string, maxSize int64) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log %s: %w", path, err)
+	}
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{
+		path:    path,
+		maxSize: maxSize,
+	}, nil
+}
+
+// the file is opened for each record and synced before we return
+// deals are agreed to rarely enough that this costs nothing
+func (audit *auditLog) write(record auditRecord) error {
+	if audit == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+	err = audit.rotate(int64(len(line)))
+	if err != nil {
+		return fmt.Errorf("error rotating audit log %s: %w", audit.path, err)
+	}
+	file, err := os.OpenFile(audit.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// must be called with the mutex held
+func (audit *auditLog) rotate(nextWrite int64) error {
+	if audit.maxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(audit.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// a single record bigger than the limit still gets written
+	if info.Size() == 0 || info.Size()+nextWrite <= audit.maxSize {
+		return nil
+	}
+	return os.Rename(audit.path, audit.path+".1")
+}
+
+func (controller *ResourceProviderController) writeAuditLog(conn *solverConnection, dealContainer data.DealContainer, txHash string) error {
+	return controller.auditLog.write(auditRecord{
+		DealID:           dealContainer.ID,
+		Timestamp:        controller.clock.Now().UTC(),
+		ResourceProvider: dealContainer.ResourceProvider,
+		JobCreator:       dealContainer.JobCreator,
+		Solver:           conn.address,
+		Pricing:          dealContainer.Deal.Pricing,
+		AgreeTx:          txHash,
+	})
+}
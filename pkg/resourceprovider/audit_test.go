@@ -0,0 +1,76 @@
+package resourceprovider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAuditLog(t *testing.T, path string) []auditRecord {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	records := []auditRecord{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := auditRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditLogWrittenOnAgree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	controller, conn, client := newTestController(t, ResourceProviderOptions{})
+	auditLog, err := newAuditLog(path, 0)
+	assert.NoError(t, err)
+	controller.auditLog = auditLog
+
+	dealContainer := data.DealContainer{
+		ID:         "deal1",
+		JobCreator: "0xjobcreator",
+		Deal:       data.Deal{ID: "deal1", Pricing: data.DealPricing{InstructionPrice: 10}},
+	}
+	assert.NoError(t, client.AddDeal(dealContainer))
+	// an agree tx we already know was mined so nothing is sent to the chain
+	assert.NoError(t, controller.state.setDeal("deal1", persistedDeal{Solver: conn.address, AgreeTx: "0xtx", Agreed: true}))
+	ok, err := controller.agreeToDeal(context.Background(), conn, dealContainer)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	records := readAuditLog(t, path)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "deal1", records[0].DealID)
+	assert.Equal(t, "0xtx", records[0].AgreeTx)
+	assert.Equal(t, uint64(10), records[0].Pricing.InstructionPrice)
+	assert.Equal(t, conn.address, records[0].Solver)
+	assert.False(t, records[0].Timestamp.IsZero())
+}
+
+func TestAuditLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// room for two records
+	line, err := json.Marshal(auditRecord{DealID: "deal1"})
+	assert.NoError(t, err)
+	audit, err := newAuditLog(path, int64(2*(len(line)+1)))
+	assert.NoError(t, err)
+
+	for _, dealID := range []string{"deal1", "deal2", "deal3"} {
+		assert.NoError(t, audit.write(auditRecord{DealID: dealID}))
+	}
+	rotated := readAuditLog(t, path+".1")
+	current := readAuditLog(t, path)
+	assert.Len(t, rotated, 2)
+	assert.Len(t, current, 1)
+	assert.Equal(t, "deal3", current[0].DealID, "New records should go in a new file once the limit is reached")
+
+	var nilLog *auditLog
+	assert.NoError(t, nilLog.write(auditRecord{DealID: "deal4"}), "No audit log should mean nothing is written")
+}
@@ -0,0 +1,91 @@
+package resourceprovider
+
+import (
+	"sync"
+	"time"
+)
+
+// how many solves in a row have to hit an overloaded solver before we back off
+const OVERLOAD_TRIP_SOLVES = 3
+
+// how many solves in a row have to go through before we drop back to the normal interval
+const OVERLOAD_COOLDOWN_SOLVES = 3
+
+// the most we will slow the solve loop down by if MaxSolveInterval doesn't allow more
+const OVERLOAD_MAX_MULTIPLIER = 16
+
+// a circuit breaker for the solve loop - when the solvers keep telling
+// us to slow down (429 or 503) we stretch the solve interval until they
+// have let a few solves through in a row
+type backpressureState struct {
+	mutex            sync.Mutex
+	backingOff       bool
+	overloadedSolves int
+	healthySolves    int
+	interval         time.Duration
+}
+
+func (controller *ResourceProviderController) isBackingOff() bool {
+	controller.backpressure.mutex.Lock()
+	defer controller.backpressure.mutex.Unlock()
+	return controller.backpressure.backingOff
+}
+
+// the longest interval we will back off to
+func (controller *ResourceProviderController) getMaxBackpressureInterval() time.Duration {
+	maxInterval := controller.getSolveInterval() * OVERLOAD_MAX_MULTIPLIER
+	if controller.options.MaxSolveInterval > maxInterval {
+		maxInterval = controller.options.MaxSolveInterval
+	}
+	return maxInterval
+}
+
+// called after each solve with whether any solver said it was overloaded
+// the interval doubles with every overloaded solve while we are backing off
+// and stays stretched through the cool-down so we don't go straight back to hammering
+func (controller *ResourceProviderController) updateBackpressure(overloaded bool) {
+	backpressure := &controller.backpressure
+	backpressure.mutex.Lock()
+	defer backpressure.mutex.Unlock()
+
+	if !overloaded {
+		backpressure.overloadedSolves = 0
+		if !backpressure.backingOff {
+			return
+		}
+		backpressure.healthySolves++
+		if backpressure.healthySolves < OVERLOAD_COOLDOWN_SOLVES {
+			return
+		}
+		backpressure.backingOff = false
+		backpressure.healthySolves = 0
+		solverBackpressureGauge.Set(0)
+		controller.log.Info("solvers recovered, resuming normal solve interval", controller.getSolveInterval())
+		if controller.loop != nil {
+			controller.loop.SetInterval(controller.getSolveInterval())
+		}
+		return
+	}
+
+	backpressure.healthySolves = 0
+	backpressure.overloadedSolves++
+	if backpressure.overloadedSolves < OVERLOAD_TRIP_SOLVES {
+		return
+	}
+	nextInterval := controller.getSolveInterval() * 2
+	if backpressure.backingOff {
+		nextInterval = backpressure.interval * 2
+	}
+	if nextInterval > controller.getMaxBackpressureInterval() {
+		nextInterval = controller.getMaxBackpressureInterval()
+	}
+	if !backpressure.backingOff || nextInterval != backpressure.interval {
+		controller.log.Warn("solvers overloaded, backing off solve interval", nextInterval)
+	}
+	backpressure.backingOff = true
+	backpressure.interval = nextInterval
+	solverBackpressureGauge.Set(1)
+	if controller.loop != nil {
+		controller.loop.SetInterval(nextInterval)
+	}
+}
@@ -0,0 +1,47 @@
+package resourceprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBackpressure(t *testing.T) {
+	controller := &ResourceProviderController{
+		options: ResourceProviderOptions{SolveInterval: time.Second},
+		log:     system.NewServiceLogger(system.ResourceProviderService),
+	}
+	controller.loop = system.NewControlLoop(system.ResourceProviderService, context.Background(), time.Second, func() error { return nil })
+
+	for i := 0; i < OVERLOAD_TRIP_SOLVES-1; i++ {
+		controller.updateBackpressure(true)
+	}
+	assert.False(t, controller.isBackingOff(), "A single bad patch should not trip the breaker")
+
+	controller.updateBackpressure(true)
+	assert.True(t, controller.isBackingOff())
+	assert.Equal(t, 2*time.Second, controller.loop.GetInterval())
+
+	controller.updateBackpressure(true)
+	assert.Equal(t, 4*time.Second, controller.loop.GetInterval())
+
+	controller.resetSolveInterval()
+	assert.Equal(t, 4*time.Second, controller.loop.GetInterval(), "Events should not undo the backoff")
+
+	for i := 0; i < 10; i++ {
+		controller.updateBackpressure(true)
+	}
+	assert.Equal(t, OVERLOAD_MAX_MULTIPLIER*time.Second, controller.loop.GetInterval())
+
+	for i := 0; i < OVERLOAD_COOLDOWN_SOLVES-1; i++ {
+		controller.updateBackpressure(false)
+	}
+	assert.True(t, controller.isBackingOff(), "We should keep backing off through the cool-down")
+
+	controller.updateBackpressure(false)
+	assert.False(t, controller.isBackingOff())
+	assert.Equal(t, time.Second, controller.loop.GetInterval())
+}
@@ -0,0 +1,63 @@
+package resourceprovider
+
+import "fmt"
+
+// a spec can run one deal on the whole machine or one deal in each of its slots
+// (see slots.go) - the solver can match more deals than that before it sees
+// our offers come down so we count what each spec is running before we agree
+type specCapacity struct {
+	// both keyed by the key of the spec's whole machine offer
+	slots map[offerKey]int
+	used  map[offerKey]int
+}
+
+func newSpecCapacity(specs []OfferSpec) *specCapacity {
+	capacity := &specCapacity{
+		slots: map[offerKey]int{},
+		used:  map[offerKey]int{},
+	}
+	for specIndex, spec := range specs {
+		slots := 1
+		if spec.Slots > 1 {
+			slots = spec.Slots
+		}
+		capacity.slots[getOfferKey(spec.ID, getOfferIndex(specIndex, 0))] = slots
+	}
+	return capacity
+}
+
+func getSpecKey(key offerKey) offerKey {
+	key.slot = 0
+	return key
+}
+
+// how many of the spec's slots a deal for the offer takes - the whole machine takes them all
+func (capacity *specCapacity) getUsage(key offerKey) int {
+	if key.slot == 0 {
+		return capacity.slots[getSpecKey(key)]
+	}
+	return 1
+}
+
+// count a deal we are already running against its spec
+func (capacity *specCapacity) use(key offerKey) {
+	capacity.used[getSpecKey(key)] += capacity.getUsage(key)
+}
+
+// take the slots a new deal needs if its spec has them free
+// deals for offers that don't match a spec in our config (e.g. posted
+// before the config changed) are not limited
+func (capacity *specCapacity) reserve(key offerKey) (bool, string) {
+	specKey := getSpecKey(key)
+	slots, ok := capacity.slots[specKey]
+	if !ok {
+		return true, ""
+	}
+	usage := capacity.getUsage(key)
+	used := capacity.used[specKey]
+	if used+usage > slots {
+		return false, fmt.Sprintf("spec %s is using %d of %d slots and the deal needs %d", specKey, used, slots, usage)
+	}
+	capacity.used[specKey] = used + usage
+	return true, ""
+}
@@ -0,0 +1,36 @@
+package resourceprovider
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecCapacity(t *testing.T) {
+	specs := []OfferSpec{
+		{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+		{ID: "split", MachineSpec: data.MachineSpec{CPU: 4000, RAM: 4096}, Slots: 2},
+	}
+	offer := func(specID string, specIndex int, slot int) offerKey {
+		return getOfferKey(specID, getOfferIndex(specIndex, slot))
+	}
+
+	capacity := newSpecCapacity(specs)
+	capacity.use(offer("", 0, 0))
+	ok, reason := capacity.reserve(offer("", 0, 0))
+	assert.False(t, ok, "A spec without slots runs one deal at a time")
+	assert.Contains(t, reason, "using 1 of 1 slots")
+
+	ok, _ = capacity.reserve(offer("split", 1, 1))
+	assert.True(t, ok)
+	ok, _ = capacity.reserve(offer("split", 1, 0))
+	assert.False(t, ok, "The whole machine needs every slot free")
+	ok, _ = capacity.reserve(offer("split", 1, 2))
+	assert.True(t, ok)
+	ok, _ = capacity.reserve(offer("split", 1, 2))
+	assert.False(t, ok, "Both slots are in use")
+
+	ok, _ = capacity.reserve(offer("removed", 5, 0))
+	assert.True(t, ok, "Offers for specs we no longer have are not limited")
+}
@@ -0,0 +1,207 @@
+package resourceprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile reads a json or yaml config file over the top of options
+// keys are the go field names (matched case insensitively) or the json tags where
+// a type has them - yaml files use the same keys because we convert them to json first
+// durations are in nanoseconds because that is how json encodes them
+// and pricing amounts are in millionths of a token (see data.PRICING_DECIMALS)
+// ${VAR} and ${VAR:-default} anywhere in the file are replaced from the
+// environment first (see interpolateEnv) so one file can be shared across hosts
+func LoadConfigFile(path string, options ResourceProviderOptions) (ResourceProviderOptions, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return options, fmt.Errorf("error reading config file: %w", err)
+	}
+	interpolated, err := interpolateEnv(string(fileBytes), os.LookupEnv)
+	if err != nil {
+		return options, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	fileBytes = []byte(interpolated)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var config interface{}
+		err = yaml.Unmarshal(fileBytes, &config)
+		if err != nil {
+			return options, fmt.Errorf("error parsing config file %s: %w", path, err)
+		}
+		fileBytes, err = json.Marshal(config)
+		if err != nil {
+			return options, fmt.Errorf("error parsing config file %s: %w", path, err)
+		}
+	}
+	err = json.Unmarshal(fileBytes, &options)
+	if err != nil {
+		return options, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return options, nil
+}
+
+// replace ${VAR} with the value of VAR and ${VAR:-default} with the value of
+// VAR or default if it is unset or empty - $${ is left as a literal ${
+// this is done on the raw text so a value can stand in for a number e.g. gpu: ${GPU_COUNT}
+// every variable that is unset with no default is reported at once
+func interpolateEnv(text string, lookup func(string) (string, bool)) (string, error) {
+	var out strings.Builder
+	missing := []string{}
+	for {
+		start := strings.Index(text, "${")
+		if start < 0 {
+			out.WriteString(text)
+			break
+		}
+		if start > 0 && text[start-1] == '$' {
+			out.WriteString(text[:start-1])
+			out.WriteString("${")
+			text = text[start+2:]
+			continue
+		}
+		out.WriteString(text[:start])
+		end := strings.Index(text[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated env var reference %q", text[start:])
+		}
+		reference := text[start+2 : start+end]
+		text = text[start+end+1:]
+		name, defaultValue, hasDefault := strings.Cut(reference, ":-")
+		if !isEnvVarName(name) {
+			return "", fmt.Errorf("invalid env var reference ${%s}", reference)
+		}
+		value, ok := lookup(name)
+		if hasDefault && value == "" {
+			value, ok = defaultValue, true
+		}
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		out.WriteString(value)
+	}
+	if len(missing) == 1 {
+		return "", fmt.Errorf("env var %s is not set and has no default (use ${%s:-default})", missing[0], missing[0])
+	}
+	if len(missing) > 1 {
+		return "", fmt.Errorf("env vars %s are not set and have no default (use ${VAR:-default})", strings.Join(missing, ", "))
+	}
+	return out.String(), nil
+}
+
+func isEnvVarName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, c := range name {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateConfigFile loads the config file at path, checks it with Validate
+// and prints what the RP would offer to stdout
+func ValidateConfigFile(path string) error {
+	return CheckConfigFile(path, ResourceProviderOptions{}, os.Stdout)
+}
+
+// CheckConfigFile is ValidateConfigFile with the defaults the file is loaded over
+// and where the report is written - the cli uses this so the file only
+// needs to contain what differs from the flags and env
+func CheckConfigFile(path string, defaults ResourceProviderOptions, out io.Writer) error {
+	options, err := LoadConfigFile(path, defaults)
+	if err != nil {
+		return err
+	}
+	// the same as the cli does - a single spec repeated if no list is given
+	if len(options.Offers.Specs) == 0 {
+		for i := 0; i < options.Offers.OfferCount; i++ {
+			options.Offers.Specs = append(options.Offers.Specs, options.Offers.OfferSpec)
+		}
+	}
+	writeConfigReport(out, options)
+	err = options.Validate()
+	if err != nil {
+		fmt.Fprintf(out, "\nconfig is invalid:\n")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(out, "  - %s\n", line)
+		}
+		return fmt.Errorf("invalid resource provider config %s: %w", path, err)
+	}
+	fmt.Fprintf(out, "\nconfig is valid\n")
+	return nil
+}
+
+func writeConfigReport(out io.Writer, options ResourceProviderOptions) {
+	fmt.Fprintf(out, "solvers: %s\n", strings.Join(options.Offers.GetSolverAddresses(), ", "))
+
+	fmt.Fprintf(out, "specs: %d\n", len(options.Offers.Specs))
+	for index, spec := range options.Offers.Specs {
+		fmt.Fprintf(out, "  %d: cpu=%d gpu=%d ram=%d", index, spec.CPU, spec.GPU, spec.RAM)
+		if spec.GPUModel != "" || spec.VRAM > 0 {
+			fmt.Fprintf(out, " gpu_model=%q vram=%d", spec.GPUModel, spec.VRAM)
+		}
+		if spec.AutoDetect {
+			fmt.Fprintf(out, " (auto detect)")
+		}
+		if spec.Slots > 1 {
+			fmt.Fprintf(out, " slots=%d", spec.Slots)
+		}
+		if spec.ID != "" {
+			fmt.Fprintf(out, " id=%q", spec.ID)
+		}
+		if len(spec.Labels) > 0 {
+			fmt.Fprintf(out, " labels=%s", formatLabels(spec.Labels))
+		}
+		if len(spec.Schedule) > 0 {
+			fmt.Fprintf(out, " schedule=%q", strings.Join(spec.Schedule, ", "))
+		}
+		fmt.Fprintf(out, "\n")
+	}
+
+	if len(options.Offers.Modules) == 0 {
+		fmt.Fprintf(out, "modules: any\n")
+	} else {
+		fmt.Fprintf(out, "modules: %s\n", strings.Join(options.Offers.Modules, ", "))
+	}
+
+	fmt.Fprintf(out, "pricing mode: %s\n", options.Offers.Mode)
+	fmt.Fprintf(out, "default pricing: %s\n", formatPricing(options.Offers.DefaultPricing))
+	modules := []string{}
+	for module := range options.Offers.ModulePricing {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		fmt.Fprintf(out, "  %s: %s\n", module, formatPricing(options.Offers.ModulePricing[module]))
+	}
+}
+
+func formatPricing(pricing data.DealPricing) string {
+	return fmt.Sprintf(
+		"instruction_price=%q payment_collateral=%q results_collateral_multiple=%d mediation_fee=%q",
+		data.FormatPricingAmount(pricing.InstructionPrice), data.FormatPricingAmount(pricing.PaymentCollateral),
+		pricing.ResultsCollateralMultiple, data.FormatPricingAmount(pricing.MediationFee),
+	)
+}
+
+// sorted so the report is the same every time
+func formatLabels(labels map[string]string) string {
+	pairs := []string{}
+	for label, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", label, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,73 @@
+package resourceprovider
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlConfig := filepath.Join(dir, "rp.yaml")
+	assert.NoError(t, os.WriteFile(yamlConfig, []byte(`
+offers:
+  services:
+    solver: "0xd4646ef9f7336b06841db3019b617ceadf435316"
+  specs:
+    - cpu: 2000
+      ram: 4096
+      gpu_model: A100
+      labels:
+        region: eu
+        cuda: "12"
+    - cpu: 1000
+      ram: 1024
+      auto_detect: true
+`), 0600))
+
+	options, err := LoadConfigFile(yamlConfig, ResourceProviderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2000, options.Offers.Specs[0].CPU, "Yaml keys should match the go field names")
+	assert.Equal(t, "A100", options.Offers.Specs[0].GPUModel, "Yaml keys should match the json tags")
+	assert.True(t, options.Offers.Specs[1].AutoDetect, "Offer settings should sit alongside the machine spec")
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, CheckConfigFile(yamlConfig, ResourceProviderOptions{}, out))
+	assert.Contains(t, out.String(), "0: cpu=2000 gpu=0 ram=4096")
+	assert.Contains(t, out.String(), "labels=cuda=12,region=eu")
+
+	jsonConfig := filepath.Join(dir, "rp.json")
+	assert.NoError(t, os.WriteFile(jsonConfig, []byte(`{"offers": {"specs": []}}`), 0600))
+	out = &bytes.Buffer{}
+	err = CheckConfigFile(jsonConfig, ResourceProviderOptions{}, out)
+	assert.Error(t, err, "A config with no specs or solver should be invalid")
+	assert.Contains(t, out.String(), "at least one spec must be configured")
+}
+
+func TestLoadConfigFileInterpolatesEnv(t *testing.T) {
+	t.Setenv("TEST_RP_GPU_COUNT", "2")
+	t.Setenv("TEST_RP_REGION", "")
+	config := filepath.Join(t.TempDir(), "rp.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte(`
+offers:
+  specs:
+    - gpu: ${TEST_RP_GPU_COUNT}
+      ram: ${TEST_RP_RAM:-4096}
+      labels:
+        region: ${TEST_RP_REGION:-eu}
+        note: "$${NOT_A_VAR}"
+`), 0600))
+	options, err := LoadConfigFile(config, ResourceProviderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, options.Offers.Specs[0].GPU, "Numbers should come from the environment")
+	assert.Equal(t, 4096, options.Offers.Specs[0].RAM, "Unset vars should use their default")
+	assert.Equal(t, "eu", options.Offers.Specs[0].Labels["region"], "Empty vars should use their default")
+	assert.Equal(t, "${NOT_A_VAR}", options.Offers.Specs[0].Labels["note"])
+
+	assert.NoError(t, os.WriteFile(config, []byte("offers:\n  specs:\n    - gpu: ${TEST_RP_UNSET}\n"), 0600))
+	_, err = LoadConfigFile(config, ResourceProviderOptions{})
+	assert.ErrorContains(t, err, "env var TEST_RP_UNSET is not set and has no default")
+}
@@ -2,7 +2,9 @@ package resourceprovider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,56 +17,268 @@ import (
 	"github.com/bacalhau-project/lilypad/pkg/system"
 	"github.com/bacalhau-project/lilypad/pkg/web3"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ResourceProviderController struct {
-	solverClient *solver.SolverClient
-	options      ResourceProviderOptions
-	web3SDK      *web3.Web3SDK
-	web3Events   *web3.EventChannels
-	loop         *system.ControlLoop
-	log          *system.ServiceLogger
-	executor     executor.Executor
+	// we can advertise the same resources to multiple solvers
+	solvers    []*solverConnection
+	options    ResourceProviderOptions
+	web3SDK    *web3.Web3SDK
+	web3Events *web3.EventChannels
+	loop       *system.ControlLoop
+	log        *system.ServiceLogger
+	executor   executor.Executor
 	// keep track of which jobs are running
 	// this is because no remote state will change
 	// whilst we are actually running a job
 	runningJobsMutex sync.RWMutex
 	runningJobs      map[string]bool
+	// keep track of how many times we have tried to agree to each deal
+	// so that a deal that keeps failing is backed off and eventually dropped
+	agreeAttemptsMutex sync.Mutex
+	agreeAttempts      map[string]*agreeAttempt
+	// used to stop new work and wait for in-flight agree txs on shutdown
+	shutdownMutex sync.Mutex
+	shuttingDown  bool
+	inflightWork  sync.WaitGroup
+	// set by Drain - we still run jobs for agreed deals but take on nothing new
+	draining bool
+	// which solver we have agreed to each deal through
+	// so that we never agree to the same deal twice
+	// only one solve runs at a time
+	solveMutex        sync.Mutex
+	claimedDealsMutex sync.Mutex
+	claimedDeals      map[string]string
+	// what the host has if any of our specs are auto detected
+	hostSpec data.MachineSpec
+	// what we charge for each resource offer
+	pricingStrategy PricingStrategy
+	// deals we have recently had DealAdded events for
+	seenDeals *seenDeals
+	// deals on the chain that we have looked up and found are not ours
+	foreignDeals *seenDeals
+	// deal id and reason of the rejections we have reported
+	rejectedDeals *seenDeals
+	// when we first heard about the deals we are yet to agree to
+	dealTimers *dealTimers
+	// agree txs we stopped waiting for before they were mined
+	pendingAgrees *pendingAgrees
+	// reported by the health server
+	health healthState
+	// how many solves in a row have failed, only touched by the solve loop
+	consecutiveSolveErrors int
+	// what we have done that needs to survive a restart
+	state *stateStore
+	// on-chain state changes for our deals for anyone who wants them
+	dealStateChanges chan DealStateChange
+	// spec indexes that have been taken down with RemoveOffer
+	// and so should not be posted again by ensureResourceOffers
+	withdrawnOffersMutex sync.Mutex
+	withdrawnOffers      map[int]bool
+	// limits how fast we post offers (and send agree txs if shared)
+	// these are nil when there is no limit
+	offerRateLimiter *rateLimiter
+	agreeRateLimiter *rateLimiter
+	// slows the solve loop down while the solvers are overloaded
+	backpressure backpressureState
+	// posts deal events to the operator's webhook, nil if there isn't one
+	webhook *webhookNotifier
+	// where offer times, agree backoff and the solve loop get the time from
+	// so tests can use a fake clock
+	clock system.Clock
+	// a record of every deal we agree to, nil if there isn't one
+	auditLog *auditLog
 }
 
+// some of the resource offers could not be posted to the solver
+var errResourceOffersNotPosted = errors.New("resource offers not posted")
+
+// a tally of what a single solve actually did
+// used to decide if we should back off the solve loop
+type solveResult struct {
+	offersAdded int
+	dealsTried  int
+	dealsAgreed int
+	jobsStarted int
+	// a solver told us to slow down
+	overloaded bool
+}
+
+func (result solveResult) foundWork() bool {
+	return result.offersAdded > 0 || result.dealsTried > 0 || result.jobsStarted > 0
+}
+
+type agreeAttempt struct {
+	solver      string
+	count       int
+	nextAttempt time.Time
+}
+
+// how many times we try to agree to a deal if AgreeMaxAttempts is not set
+const DEFAULT_AGREE_MAX_ATTEMPTS = 5
+
+// the longest we wait between agree attempts however many have failed
+const MAX_AGREE_RETRY_DELAY = time.Hour
+
 // the background "even if we have not heard of an event" loop
 // i.e. things will not wait 10 seconds - the control loop
 // reacts to events in the system - this 10 second background
 // loop is just for in case we miss any events
 const CONTROL_LOOP_INTERVAL = 10 * time.Second
 
+// signer is what we sign solver requests with and should be the one web3SDK sends txs with
+// if it is nil we use the signer from web3SDK
 func NewResourceProviderController(
 	options ResourceProviderOptions,
 	web3SDK *web3.Web3SDK,
 	executor executor.Executor,
+	signer web3.Signer,
 ) (*ResourceProviderController, error) {
-	// we know the address of the solver but what is it's url?
-	solverUrl, err := web3SDK.GetSolverUrl(options.Offers.Services.Solver)
+	// the cli has already done this but the controller can be used without it
+	web3Options, err := web3.ResolvePrivateKey(options.Web3)
 	if err != nil {
 		return nil, err
 	}
+	options.Web3 = web3Options
 
-	solverClient, err := solver.NewSolverClient(http.ClientOptions{
-		URL:        solverUrl,
-		PrivateKey: options.Web3.PrivateKey,
-	})
+	err = options.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource provider options: %w", err)
+	}
+
+	// the rpc calls we make before we can start are bounded so an rpc node
+	// that never answers fails the start instead of hanging it
+	startupCtx, cancelStartup := context.WithCancel(context.Background())
+	if options.StartupTimeout > 0 {
+		startupCtx, cancelStartup = context.WithTimeout(context.Background(), options.StartupTimeout)
+	}
+	defer cancelStartup()
+
+	if web3SDK != nil {
+		err = web3SDK.CheckChainID(startupCtx)
+		if err != nil {
+			system.Error(system.ResourceProviderService, "refusing to start on the wrong chain", err)
+			return nil, err
+		}
+	}
+
+	if signer == nil && web3SDK != nil {
+		signer = web3SDK.Signer
+	}
+	if signer == nil {
+		signer, err = web3.NewSigner(options.Web3)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	state, err := newStateStore(options.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog, err := newAuditLog(options.AuditLogFile, int64(options.AuditLogMaxSize)*1024*1024)
 	if err != nil {
 		return nil, err
 	}
 
+	hostSpec := data.MachineSpec{}
+	for _, spec := range options.Offers.Specs {
+		if !spec.AutoDetect {
+			continue
+		}
+		detectedSpec, err := data.DetectMachineSpec()
+		if err != nil {
+			return nil, err
+		}
+		hostSpec = detectedSpec
+		break
+	}
+
+	solvers := []*solverConnection{}
+	for _, solverAddress := range options.Offers.GetSolverAddresses() {
+		// we know the address of the solver but what is it's url?
+		// unless we have been told (see Validate for why there can only be one solver then)
+		solverUrl := options.Web3.SolverURL
+		if solverUrl == "" {
+			if web3SDK == nil {
+				return nil, fmt.Errorf("no solver url for %s and no web3 sdk to look it up", solverAddress)
+			}
+			solverUrl, err = web3SDK.GetSolverUrl(startupCtx, solverAddress)
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("timed out after %s looking up the url for solver %s: %w", options.StartupTimeout, solverAddress, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+			err = checkSolverURL(solverAddress, solverUrl)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		solverClient, err := solver.NewSolverClient(http.ClientOptions{
+			URL:           solverUrl,
+			PrivateKey:    options.Web3.PrivateKey,
+			Signer:        signer,
+			TLSClientCert: options.SolverTLSClientCert,
+			TLSClientKey:  options.SolverTLSClientKey,
+			TLSCACert:     options.SolverTLSCACert,
+
+			Timeout:         options.SolverTimeout,
+			MaxIdleConns:    options.SolverMaxIdleConns,
+			MaxConnsPerHost: options.SolverMaxConnsPerHost,
+			IdleConnTimeout: options.SolverIdleConnTimeout,
+			KeepAlive:       options.SolverKeepAlive,
+			ForceHTTP2:      options.SolverForceHTTP2,
+
+			GetRetryMax:     options.SolverGetRetryMax,
+			GetRetryWaitMin: options.SolverGetRetryWaitMin,
+			GetRetryWaitMax: options.SolverGetRetryWaitMax,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		solvers = append(solvers, &solverConnection{
+			address: solverAddress,
+			client:  solverClient,
+		})
+	}
+
 	controller := &ResourceProviderController{
-		solverClient: solverClient,
-		options:      options,
-		web3SDK:      web3SDK,
-		web3Events:   web3.NewEventChannels(),
-		log:          system.NewServiceLogger(system.ResourceProviderService),
-		executor:     executor,
-		runningJobs:  map[string]bool{},
+		solvers:       solvers,
+		options:       options,
+		web3SDK:       web3SDK,
+		web3Events:    web3.NewEventChannels(),
+		log:           system.NewServiceLogger(system.ResourceProviderService),
+		executor:      executor,
+		runningJobs:   map[string]bool{},
+		agreeAttempts: map[string]*agreeAttempt{},
+		claimedDeals:  map[string]string{},
+		hostSpec:      hostSpec,
+
+		dealStateChanges: make(chan DealStateChange, DEAL_STATE_CHANGES_BUFFER),
+		withdrawnOffers:  map[int]bool{},
+		seenDeals:        newSeenDeals(options.EventDedupeWindow, SEEN_DEALS_MAX_SIZE),
+		foreignDeals:     newSeenDeals(FOREIGN_DEALS_WINDOW, SEEN_DEALS_MAX_SIZE),
+		rejectedDeals:    newSeenDeals(REJECTED_DEALS_WINDOW, SEEN_DEALS_MAX_SIZE),
+		dealTimers:       newDealTimers(SEEN_DEALS_MAX_SIZE),
+		pendingAgrees:    newPendingAgrees(),
+		state:            state,
+		clock:            system.RealClock,
+		auditLog:         auditLog,
+	}
+	controller.webhook = newWebhookNotifier(options.Webhook, controller.log)
+	controller.offerRateLimiter = newRateLimiter(options.OfferRateLimit, options.OfferRateBurst)
+	if options.ShareRateLimiter {
+		controller.agreeRateLimiter = controller.offerRateLimiter
+	}
+	controller.pricingStrategy = options.Offers.PricingStrategy
+	if controller.pricingStrategy == nil {
+		controller.pricingStrategy = NewStaticPricingStrategy(options.Offers)
 	}
 	return controller, nil
 }
@@ -80,85 +294,196 @@ func NewResourceProviderController(
 *
 *
 */
-func (controller *ResourceProviderController) subscribeToSolver() error {
-	controller.solverClient.SubscribeEvents(func(ev solver.SolverEvent) {
+func (controller *ResourceProviderController) subscribeToSolver(conn *solverConnection) error {
+	conn.client.SubscribeConnection(func(connected bool) {
+		controller.setSolverSubscribed(conn.address, connected)
+	})
+	conn.client.SubscribeEvents(func(ev solver.SolverEvent) {
+		solverEventsReceivedTotal.WithLabelValues(conn.address, ev.EventType.String()).Inc()
+		if controller.options.OnSolverEvent != nil {
+			controller.options.OnSolverEvent(conn.address, ev)
+		}
+		if controller.options.ObserverMode {
+			return
+		}
 		// we need to agree to the deal now we've heard about it
 		if ev.EventType == solver.DealAdded {
 			if ev.Deal == nil {
-				controller.log.Error("solver event", fmt.Errorf("RP received nil deal"))
+				// this is a solver bug rather than something we can recover from
+				// so we count it for alerting instead of stopping the RP
+				controller.log.Error("solver event", fmt.Errorf("RP received nil deal from solver %s", conn.address))
+				solverEventErrorsTotal.WithLabelValues(conn.address, "nil_deal").Inc()
 				return
 			}
 
 			// check if this deal is for us
 			if ev.Deal.ResourceProvider != controller.web3SDK.GetAddress().String() {
+				controller.rejectDeal(context.Background(), *ev.Deal, DealRejectWrongResourceProvider, ev.Deal.ResourceProvider)
+				return
+			}
+
+			// check if we are willing to work with the parties on this deal
+			if reason, detail := controller.isDealAllowed(*ev.Deal); reason != "" {
+				controller.rejectDeal(context.Background(), *ev.Deal, reason, detail)
+				return
+			}
+			controller.dealTimers.start(ev.Deal.ID, controller.clock.Now())
+
+			// the same event can arrive more than once e.g. after a reconnect
+			if controller.seenDeals.checkAndAdd(ev.Deal.ID, controller.clock.Now()) {
+				controller.log.Debug("ignoring duplicate deal event", ev.Deal.ID)
 				return
 			}
 
 			solver.ServiceLogSolverEvent(system.ResourceProviderService, ev)
 
 			// trigger the solver
+			controller.resetSolveInterval()
 			controller.loop.Trigger()
 		}
 	})
 	return nil
 }
 
+// the DealStateChange event has no indexed fields so we can't filter it by
+// our address on-chain - instead we remember which deals are not ours so we
+// only ask the solvers about each one once
 func (controller *ResourceProviderController) subscribeToWeb3() error {
+	controller.web3Events.Storage.SubscribeConnection(controller.setWeb3Subscribed)
 	controller.web3Events.Storage.SubscribeDealStateChange(func(ev storage.StorageDealStateChange) {
-		deal, err := controller.solverClient.GetDeal(ev.DealId)
-		if err != nil {
-			controller.log.Error("error getting deal", err)
-			return
-		}
-		if deal.ResourceProvider != controller.web3SDK.GetAddress().String() {
+		web3EventsReceivedTotal.WithLabelValues("DealStateChange").Inc()
+		// an observer wants every deal so there is nothing to look up
+		if !controller.options.ObserverMode && !controller.isOurDeal(ev.DealId) {
 			return
 		}
 		controller.log.Info("StorageDealStateChange", data.GetAgreementStateString(ev.State))
 		system.DumpObjectDebug(ev)
+		controller.publishDealStateChange(ev)
+		controller.notifyWebhook(WebhookEvent{
+			Type:   WEBHOOK_EVENT_DEAL_STATE_CHANGE,
+			DealID: ev.DealId,
+			State:  data.GetAgreementStateString(ev.State),
+		})
+		if controller.options.ObserverMode {
+			return
+		}
+		controller.resetSolveInterval()
 		controller.loop.Trigger()
 	})
 	return nil
 }
 
-func (controller *ResourceProviderController) Start(ctx context.Context, cm *system.CleanupManager) chan error {
-	errorChan := make(chan error)
-	err := controller.subscribeToSolver()
-	if err != nil {
-		errorChan <- err
-		return errorChan
+func (controller *ResourceProviderController) isOurDeal(dealID string) bool {
+	if controller.foreignDeals.contains(dealID, controller.clock.Now()) {
+		return false
 	}
-	err = controller.subscribeToWeb3()
+	deal, err := controller.getDealFromSolvers(dealID)
 	if err != nil {
-		errorChan <- err
-		return errorChan
+		controller.log.Error("error getting deal", err)
+		return false
+	}
+	if deal.ResourceProvider != controller.web3SDK.GetAddress().String() {
+		controller.foreignDeals.checkAndAdd(dealID, controller.clock.Now())
+		return false
 	}
-	err = controller.solverClient.Start(ctx, cm)
+	return true
+}
+
+// a chain event only gives us the deal id so ask each solver in turn
+func (controller *ResourceProviderController) getDealFromSolvers(dealID string) (data.DealContainer, error) {
+	errs := []error{}
+	for _, conn := range controller.solvers {
+		deal, err := conn.client.GetDeal(dealID)
+		if err == nil && deal.ID != "" {
+			return deal, nil
+		}
+		// the deal is probably on one of the other solvers
+		if err != nil && !errors.Is(err, solver.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("solver %s: %w", conn.address, err))
+		}
+	}
+	if len(errs) > 0 {
+		return data.DealContainer{}, errors.Join(errs...)
+	}
+	return data.DealContainer{}, fmt.Errorf("deal %s not found on any solver", dealID)
+}
+
+func (controller *ResourceProviderController) Start(ctx context.Context, cm *system.CleanupManager) chan error {
+	// buffered so that we never block sending the error that stops us
+	errorChan := make(chan error, 1)
+	if controller.options.OtelExporterEndpoint != "" {
+		err := startTracing(ctx, controller.options.OtelExporterEndpoint, cm)
+		if err != nil {
+			errorChan <- err
+			return errorChan
+		}
+	}
+	// start this first so probes can see us while we are connecting
+	if controller.options.HealthPort > 0 {
+		controller.startHealthServer(ctx, cm)
+	}
+	for _, conn := range controller.solvers {
+		err := controller.subscribeToSolver(conn)
+		if err != nil {
+			errorChan <- err
+			return errorChan
+		}
+	}
+	err := controller.subscribeToWeb3()
 	if err != nil {
 		errorChan <- err
 		return errorChan
 	}
+	for _, conn := range controller.solvers {
+		err = conn.client.Start(ctx, cm)
+		if err != nil {
+			errorChan <- err
+			return errorChan
+		}
+	}
 	err = controller.web3Events.Start(controller.web3SDK, ctx, cm)
 	if err != nil {
 		errorChan <- err
 		return errorChan
 	}
 
+	if controller.options.MetricsPort > 0 {
+		controller.startMetricsServer(ctx, cm)
+	}
+	if controller.webhook != nil {
+		go controller.webhook.run(ctx)
+	}
+
+	cm.RegisterCallbackWithContext(controller.drainInflightWork)
+	// an observer's key might belong to a real resource provider so leave its offers alone
+	if controller.options.RemoveOffersOnShutdown && !controller.options.ObserverMode {
+		cm.RegisterCallbackWithContext(controller.withdrawAllOffers)
+	}
+
 	controller.loop = system.NewControlLoop(
 		system.ResourceProviderService,
 		ctx,
-		CONTROL_LOOP_INTERVAL,
+		controller.getSolveInterval(),
 		func() error {
-			err := controller.solve()
+			err := controller.checkSolveError(controller.solveWithTimeout(ctx))
 			if err != nil {
-				errorChan <- err
+				select {
+				case errorChan <- err:
+				default:
+				}
 			}
 			return err
 		},
 	)
 
+	controller.loop.SetJitter(controller.options.SolveJitter)
+	controller.loop.SetClock(controller.clock)
+
+	controller.setLoopRunning(true)
 	err = controller.loop.Start(true)
 	if err != nil {
-		errorChan <- err
+		// the loop handler has already sent this to errorChan
+		controller.setLoopRunning(false)
 		return errorChan
 	}
 
@@ -177,32 +502,220 @@ func (controller *ResourceProviderController) Start(ctx context.Context, cm *sys
  *
 */
 
-func (controller *ResourceProviderController) solve() error {
-	controller.log.Debug("solving", "")
+// run a single solve but give up waiting for it after SolveTimeout
+// so that one stuck call can't stop the loop forever
+func (controller *ResourceProviderController) solveWithTimeout(parentCtx context.Context) error {
+	// an abandoned solve might still be finishing off in the background
+	// and we don't want two solves agreeing to the same deals at once
+	if !controller.solveMutex.TryLock() {
+		controller.log.Info("previous solve still running, skipping solve", "")
+		return nil
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if controller.options.SolveTimeout > 0 {
+		ctx, cancel = context.WithTimeout(parentCtx, controller.options.SolveTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(parentCtx)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer controller.solveMutex.Unlock()
+		done <- controller.solve(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			controller.log.Warn("solve timed out and was abandoned", controller.options.SolveTimeout.String())
+			return fmt.Errorf("solve timed out after %s", controller.options.SolveTimeout)
+		}
+		return nil
+	}
+}
+
+// a failed solve is retried on the next run so on its own it is not fatal
+// we only give up once MaxConsecutiveErrors solves in a row have failed
+// returns the error that should stop the resource provider (if any)
+func (controller *ResourceProviderController) checkSolveError(err error) error {
+	if err == nil {
+		controller.consecutiveSolveErrors = 0
+		return nil
+	}
+	solveErrorsTotal.Inc()
+	controller.consecutiveSolveErrors++
+	maxErrors := controller.options.MaxConsecutiveErrors
+	if maxErrors > 0 && controller.consecutiveSolveErrors >= maxErrors {
+		return fmt.Errorf("giving up after %d failed solves in a row: %w", controller.consecutiveSolveErrors, err)
+	}
+	return nil
+}
+
+func (controller *ResourceProviderController) solve(ctx context.Context) error {
+	// we are shutting down so don't post offers or agree to anything new
+	if controller.isShuttingDown() {
+		controller.log.Debug("shutting down, skipping solve", "")
+		return nil
+	}
+	ctx, span := startSpan(ctx, "solve")
+	// an abandoned solve can still be logging while the next one runs
+	ctx = system.WithLogFields(ctx, system.F("solveID", system.NewCorrelationID()))
+	err := controller.solveSolvers(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// one pass of the solve loop over every solver we are connected to
+func (controller *ResourceProviderController) solveSolvers(ctx context.Context) error {
+	log := controller.log.Ctx(ctx)
+	log.Debug("solving", "")
+	timer := prometheus.NewTimer(solveDuration)
+	defer timer.ObserveDuration()
+	start := controller.clock.Now()
+
+	result := solveResult{}
+
+	// run through the same steps against each solver we are connected to
+	// a problem with one solver should not stop us working with the others
+	// and we keep going if a solver is unreachable because it may come back
+	// (possibly at a new url - see recordSolverFailure)
+	solveErrors := []error{}
+	for _, conn := range controller.solvers {
+		if ctx.Err() != nil {
+			return nil
+		}
+		err := controller.solveForSolver(ctx, conn, &result)
+		if err != nil {
+			solveErrors = append(solveErrors, fmt.Errorf("solver %s: %w", conn.address, err))
+			log.Error(fmt.Sprintf("error solving with solver %s", conn.address), err)
+			// only a solver we can't reach might have moved
+			// one that says it's overloaded is still where we left it
+			if errors.Is(err, solver.ErrSolverOverloaded) {
+				result.overloaded = true
+			} else if errors.Is(err, solver.ErrSolverUnavailable) {
+				controller.recordSolverFailure(ctx, conn)
+			}
+			continue
+		}
+		controller.recordSolverSuccess(conn)
+	}
+	solveErr := errors.Join(solveErrors...)
+	controller.setLastSolveError(solveErr)
+
+	err := controller.state.pruneDeals(controller.clock.Now().Add(-STATE_DEAL_RETENTION))
+	if err != nil {
+		log.Error("error pruning deal state", err)
+	}
+
+	controller.updateBackpressure(result.overloaded)
+	controller.updateSolveInterval(result)
+
+	if controller.options.SolveSummaryLog {
+		log.Info("solve summary", fmt.Sprintf(
+			"offers added: %d, deals tried: %d, deals agreed: %d, jobs started: %d, took: %s",
+			result.offersAdded, result.dealsTried, result.dealsAgreed, result.jobsStarted, controller.clock.Now().Sub(start),
+		))
+	}
+
+	return solveErr
+}
+
+func (controller *ResourceProviderController) solveForSolver(ctx context.Context, conn *solverConnection, result *solveResult) error {
+	log := controller.log.Ctx(ctx)
+	// an observer only watches the events (see subscribeToSolver)
+	if controller.options.ObserverMode {
+		return nil
+	}
+	// when draining we only run the jobs for deals we have already agreed to
+	if controller.isDraining() {
+		jobsStarted, err := controller.runJobs(conn)
+		result.jobsStarted += jobsStarted
+		return err
+	}
 
 	// if the solver does not know about resource offers
 	// that we have - we should post them to the solver
-	err := controller.ensureResourceOffers()
-	if err != nil {
+	offersAdded, err := controller.ensureResourceOffers(ctx, conn)
+	result.offersAdded += offersAdded
+	if errors.Is(err, errResourceOffersNotPosted) {
+		// the missing offers will be retried on the next solve
+		log.Error("error posting resource offers", err)
+		if errors.Is(err, solver.ErrSolverOverloaded) {
+			result.overloaded = true
+		}
+	} else if err != nil {
 		return err
 	}
 
 	// if there are deals that have been matched and we have not agreed
 	// then we should agree to them
-	err = controller.agreeToDeals()
+	if ctx.Err() != nil {
+		return nil
+	}
+	dealsTried, dealsAgreed, err := controller.agreeToDeals(ctx, conn)
 	if err != nil {
 		return err
 	}
+	result.dealsTried += dealsTried
+	result.dealsAgreed += dealsAgreed
 
 	// if there are jobs that have had both sides agree then we should run the job
-	err = controller.runJobs()
+	if ctx.Err() != nil {
+		return nil
+	}
+	jobsStarted, err := controller.runJobs(conn)
 	if err != nil {
 		return err
 	}
+	result.jobsStarted += jobsStarted
 
 	return nil
 }
 
+func (controller *ResourceProviderController) getSolveInterval() time.Duration {
+	if controller.options.SolveInterval <= 0 {
+		return CONTROL_LOOP_INTERVAL
+	}
+	return controller.options.SolveInterval
+}
+
+// if there was nothing to do then wait longer before the next background solve
+// as soon as something happens we drop back to the configured interval
+func (controller *ResourceProviderController) updateSolveInterval(result solveResult) {
+	// the backpressure interval wins while the solvers are overloaded
+	if controller.loop == nil || controller.isBackingOff() {
+		return
+	}
+	if result.foundWork() {
+		controller.resetSolveInterval()
+		return
+	}
+	nextInterval := controller.loop.GetInterval() * 2
+	if nextInterval > controller.options.MaxSolveInterval {
+		nextInterval = controller.options.MaxSolveInterval
+	}
+	// a max interval lower than the base interval means backoff is disabled
+	if nextInterval < controller.getSolveInterval() {
+		nextInterval = controller.getSolveInterval()
+	}
+	if nextInterval != controller.loop.GetInterval() {
+		controller.log.Debug("no work found, backing off solve interval", nextInterval)
+		controller.loop.SetInterval(nextInterval)
+	}
+}
+
+func (controller *ResourceProviderController) resetSolveInterval() {
+	if controller.loop == nil || controller.isBackingOff() {
+		return
+	}
+	controller.loop.SetInterval(controller.getSolveInterval())
+}
+
 /*
  *
  *
@@ -219,66 +732,322 @@ func (controller *ResourceProviderController) solve() error {
 Ensure resource offers are posted to the solve
 */
 
-func (controller *ResourceProviderController) getResourceOffer(index int, spec data.MachineSpec) data.ResourceOffer {
+func (controller *ResourceProviderController) getResourceOffer(solverAddress string, index int, spec OfferSpec) data.ResourceOffer {
+	// each offer names the solver it is posted to
+	services := controller.options.Offers.Services
+	services.Solver = solverAddress
+	offerSpec := controller.getOfferSpec(spec, index)
+	defaultPricing := controller.pricingStrategy.PriceFor(offerSpec, "")
+	// ask for a price for every module we know about and only list
+	// the ones that differ from the default price
+	modulePricing := map[string]data.DealPricing{}
+	pricedModules := append([]string{}, controller.options.Offers.Modules...)
+	for module := range controller.options.Offers.ModulePricing {
+		pricedModules = append(pricedModules, module)
+	}
+	for _, module := range pricedModules {
+		pricing := controller.pricingStrategy.PriceFor(offerSpec, module)
+		if pricing != defaultPricing {
+			modulePricing[module] = pricing
+		}
+	}
 	return data.ResourceOffer{
 		// assign CreatedAt to the current millisecond timestamp
-		CreatedAt:        int(time.Now().UnixNano() / int64(time.Millisecond)),
+		CreatedAt:        int(controller.clock.Now().UnixNano() / int64(time.Millisecond)),
 		ResourceProvider: controller.web3SDK.GetAddress().String(),
 		Index:            index,
-		Spec:             spec,
+		Spec:             offerSpec,
 		Modules:          controller.options.Offers.Modules,
 		Mode:             controller.options.Offers.Mode,
-		DefaultPricing:   controller.options.Offers.DefaultPricing,
+		DefaultPricing:   defaultPricing,
 		DefaultTimeouts:  controller.options.Offers.DefaultTimeouts,
-		ModulePricing:    map[string]data.DealPricing{},
-		ModuleTimeouts:   map[string]data.DealTimeouts{},
-		Services:         controller.options.Offers.Services,
+		ModulePricing:    modulePricing,
+		ModuleTimeouts:   getModuleTimeouts(controller.options.Offers),
+		Services:         services,
 	}
 }
 
-func (controller *ResourceProviderController) ensureResourceOffers() error {
+// what we offer at index for the spec: the detected host resources
+// for specs that asked for them and then the share for the offer's slot
+func (controller *ResourceProviderController) getOfferSpec(spec OfferSpec, index int) data.MachineSpec {
+	offerSpec := spec.MachineSpec
+	if spec.AutoDetect {
+		offerSpec = scaleMachineSpec(controller.hostSpec, controller.options.Offers.AutoDetectFraction)
+		offerSpec.Labels = spec.Labels
+	}
+	_, slot := splitOfferIndex(index)
+	return getSlotSpec(offerSpec, spec.Slots, slot)
+}
+
+// returns how many resource offers we posted to the solver
+func (controller *ResourceProviderController) ensureResourceOffers(ctx context.Context, conn *solverConnection) (int, error) {
+	ctx, span := startSpan(ctx, "ensureResourceOffers", attribute.String("solver", conn.address))
+	added, err := controller.postResourceOffers(ctx, conn)
+	span.SetAttributes(attribute.Int("offers_added", added))
+	endSpan(span, err)
+	return added, err
+}
+
+func (controller *ResourceProviderController) postResourceOffers(ctx context.Context, conn *solverConnection) (int, error) {
+	log := controller.log.Ctx(ctx)
+	// the solver requests are part of our span
+	client := conn.client.WithContext(ctx)
+
 	// load the resource offers that are currently active and so should not be replaced
-	activeResourceOffers, err := controller.solverClient.GetResourceOffers(store.GetResourceOffersQuery{
+	activeResourceOffers, err := client.GetResourceOffers(store.GetResourceOffersQuery{
 		ResourceProvider: controller.web3SDK.GetAddress().String(),
 		Active:           true,
 	})
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	// the ids we have for the lost offers are no use now and
+	// every offer is missing so they are all posted again below
+	lost, err := controller.haveOffersBeenLost(client, conn, len(activeResourceOffers))
+	if err != nil {
+		return 0, err
+	}
+	if lost {
+		log.Info("solver has lost our resource offers, posting them all again", conn.address)
+		offerResyncsTotal.WithLabelValues(conn.address).Inc()
+		err = controller.state.removeSolverOffers(conn.address)
+		if err != nil {
+			log.Error("error saving offer state", err)
+		}
 	}
 
 	// create a map of the ids of resource offers we have
 	// this will allow us to check if we need to create a new one
-	// or update an existing one - we use the "index" because
-	// the id's are changing because of the timestamps
-	existingResourceOffersMap := map[int]data.ResourceOfferContainer{}
+	// or update an existing one - we use the spec ID or the "index" because
+	// the id's are changing because of the timestamps (see offerKey)
+	existingResourceOffersMap := map[offerKey]data.ResourceOfferContainer{}
 	for _, existingResourceOffer := range activeResourceOffers {
-		existingResourceOffersMap[existingResourceOffer.ResourceOffer.Index] = existingResourceOffer
+		existingResourceOffersMap[controller.getResourceOfferKey(existingResourceOffer.ResourceOffer)] = existingResourceOffer
 	}
 
+	activeResourceOffersGauge.WithLabelValues(conn.address).Set(float64(len(activeResourceOffers)))
+	activeOffers := len(activeResourceOffers)
+	defer func() {
+		controller.setActiveOffers(conn.address, activeOffers)
+	}()
+
 	addResourceOffers := []data.ResourceOffer{}
+	// the key of each offer in addResourceOffers as the offers don't carry their spec ID
+	addResourceOfferKeys := []offerKey{}
+	errs := []error{}
+
+	// the offers that have been matched to a deal tell us which hardware is in use
+	matchedOffers := map[offerKey]bool{}
+	for key, existingResourceOffer := range existingResourceOffersMap {
+		if existingResourceOffer.DealID != "" {
+			matchedOffers[key] = true
+		}
+	}
 
 	// map over the specs we have in the config
-	for index, spec := range controller.options.Offers.Specs {
+	// and every offer we make for each of them (see slots.go)
+	for specIndex, spec := range controller.options.Offers.Specs {
+		// a spec outside its schedule has its offers taken down and isn't posted
+		// until a window opens - Validate has already checked the schedule
+		scheduled, err := isSpecScheduled(spec, controller.clock.Now())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("spec %d: %w", specIndex, err))
+			continue
+		}
+		for _, index := range getSpecOfferIndexes(spec, specIndex) {
+			key := getOfferKey(spec.ID, index)
 
-		// check if the resource offer already exists
-		// if it does then we need to update it
-		// if it doesn't then we need to add it
-		_, ok := existingResourceOffersMap[index]
-		if !ok {
-			addResourceOffers = append(addResourceOffers, controller.getResourceOffer(index, spec))
+			// we won't promise hardware that an overlapping offer has already been matched for
+			committed := isSlotCommitted(key, matchedOffers)
+
+			// check if the resource offer already exists
+			// if it does then we need to update it
+			// if it doesn't then we need to add it
+			existingResourceOffer, ok := existingResourceOffersMap[key]
+			if ok {
+				// the solver can't update an offer so if our config has changed
+				// we take the old one down and post the new one in its place
+				// offers that have been matched are left for the deal to play out
+				if existingResourceOffer.DealID != "" || controller.isOfferWithdrawn(specIndex) {
+					continue
+				}
+				// the solver may have matched overlapping offers before we saw
+				// the first match, we can only stop it happening again
+				if committed || !scheduled {
+					reason := "hardware in use"
+					if !scheduled {
+						reason = "spec outside its schedule"
+					}
+					if controller.options.DryRun {
+						log.Info("dry run: would remove resource offer for "+reason, existingResourceOffer.ID)
+						continue
+					}
+					log.Info("remove resource offer for "+reason, existingResourceOffer.ID)
+					_, err := client.RemoveResourceOffer(existingResourceOffer.ID)
+					if err != nil {
+						log.Error(fmt.Sprintf("error removing resource offer %s", key), err)
+						errs = append(errs, fmt.Errorf("resource offer %s: %w", key, err))
+						continue
+					}
+					activeResourceOffersGauge.WithLabelValues(conn.address).Dec()
+					activeOffers--
+					err = controller.state.removeOffer(conn.address, key)
+					if err != nil {
+						log.Error("error saving offer state", err)
+					}
+					continue
+				}
+				// a named spec that has moved in the config keeps the index it was posted under
+				resourceOffer := controller.getResourceOffer(conn.address, existingResourceOffer.ResourceOffer.Index, spec)
+				outOfDate := isResourceOfferOutOfDate(existingResourceOffer.ResourceOffer, resourceOffer)
+				// an unchanged offer is still posted again before the solver expires it
+				expiring := isResourceOfferExpiring(
+					existingResourceOffer.ResourceOffer,
+					controller.options.Offers.OfferTTL,
+					controller.options.Offers.OfferRefreshMargin,
+					controller.clock.Now(),
+				)
+				if !outOfDate && !expiring {
+					continue
+				}
+				if controller.options.DryRun {
+					log.Info("dry run: would replace resource offer", resourceOffer)
+					continue
+				}
+				if outOfDate {
+					log.Info("replace resource offer", existingResourceOffer.ID)
+				} else {
+					log.Info("refresh resource offer", existingResourceOffer.ID)
+				}
+				_, err := client.RemoveResourceOffer(existingResourceOffer.ID)
+				if err != nil {
+					log.Error(fmt.Sprintf("error removing resource offer %s", key), err)
+					errs = append(errs, fmt.Errorf("resource offer %s: %w", key, err))
+					continue
+				}
+				activeResourceOffersGauge.WithLabelValues(conn.address).Dec()
+				activeOffers--
+				addResourceOffers = append(addResourceOffers, resourceOffer)
+				addResourceOfferKeys = append(addResourceOfferKeys, key)
+			} else {
+				if controller.isOfferWithdrawn(specIndex) || committed || !scheduled {
+					continue
+				}
+				// the operator can hold back specs that don't have capacity right now
+				// we still key the offer the same way so it is deduped
+				// correctly when it is posted on a later solve
+				offerSpec := controller.getOfferSpec(spec, index)
+				if controller.options.Offers.SpecFilter != nil && !controller.options.Offers.SpecFilter(offerSpec) {
+					log.Debug(fmt.Sprintf("spec %s filtered out", key), offerSpec)
+					continue
+				}
+				addResourceOffers = append(addResourceOffers, controller.getResourceOffer(conn.address, index, spec))
+				addResourceOfferKeys = append(addResourceOfferKeys, key)
+			}
 		}
 	}
 
 	// add the resource offers we need to add
-	for _, resourceOffer := range addResourceOffers {
-		controller.log.Info("add resource offer", resourceOffer)
-		_, err := controller.solverClient.AddResourceOffer(resourceOffer)
+	// we keep going if one fails - the next solve will only retry
+	// the offers that are still missing from the solver
+	postOffers := []data.ResourceOffer{}
+	postOfferKeys := []offerKey{}
+	for i, resourceOffer := range addResourceOffers {
+		if controller.options.DryRun {
+			log.Info("dry run: would add resource offer", resourceOffer)
+			continue
+		}
+		// the rate limit is per offer even when they go in one request
+		err := controller.offerRateLimiter.wait(ctx)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("resource offer %s: %w", addResourceOfferKeys[i], err))
+			break
 		}
+		log.Info("add resource offer", resourceOffer)
+		postOffers = append(postOffers, resourceOffer)
+		postOfferKeys = append(postOfferKeys, addResourceOfferKeys[i])
 	}
 
-	return err
+	added := 0
+	for i, result := range controller.addResourceOffers(ctx, client, conn, postOffers) {
+		resourceOffer := postOffers[i]
+		key := postOfferKeys[i]
+		if result.err != nil {
+			log.Error(fmt.Sprintf("error adding resource offer %s", key), result.err)
+			errs = append(errs, fmt.Errorf("resource offer %s: %w", key, result.err))
+			continue
+		}
+		createdOffer := result.offer
+		err := controller.state.setOffer(persistedOffer{
+			Solver:   conn.address,
+			Index:    resourceOffer.Index,
+			SpecID:   key.specID,
+			ID:       createdOffer.ID,
+			PostedAt: controller.clock.Now(),
+		})
+		if err != nil {
+			log.Error("error saving offer state", err)
+		}
+		// the solver gives the offer its id so this is what to look for in the solver's logs
+		log.Info(fmt.Sprintf("added resource offer %s", key), createdOffer.ID)
+		activeResourceOffersGauge.WithLabelValues(conn.address).Inc()
+		activeOffers++
+		added++
+	}
+
+	if len(errs) > 0 {
+		return added, fmt.Errorf("%w: added %d of %d: %w", errResourceOffersNotPosted, added, len(addResourceOffers), errors.Join(errs...))
+	}
+	return added, nil
+}
+
+// what happened when we posted one offer
+type addedResourceOffer struct {
+	offer data.ResourceOfferContainer
+	err   error
+}
+
+// post the offers in one request if there is more than one and the solver takes batches
+// the results are in the same order as the offers
+func (controller *ResourceProviderController) addResourceOffers(
+	ctx context.Context,
+	client solver.SolverClientInterface,
+	conn *solverConnection,
+	resourceOffers []data.ResourceOffer,
+) []addedResourceOffer {
+	results := make([]addedResourceOffer, len(resourceOffers))
+	if len(resourceOffers) > 1 && !conn.batchUnsupported {
+		batchResults, err := client.AddResourceOffers(resourceOffers)
+		switch {
+		case errors.Is(err, solver.ErrNotSupported):
+			controller.log.Ctx(ctx).Info(fmt.Sprintf("solver %s cannot take a batch of offers, posting them one at a time", conn.address), err)
+			conn.batchUnsupported = true
+		case err != nil:
+			for i := range results {
+				results[i].err = err
+			}
+			return results
+		case len(batchResults) != len(resourceOffers):
+			for i := range results {
+				results[i].err = fmt.Errorf("%w: solver returned %d results for %d offers", solver.ErrBadRequest, len(batchResults), len(resourceOffers))
+			}
+			return results
+		default:
+			for i, batchResult := range batchResults {
+				if batchResult.Offer == nil {
+					results[i].err = fmt.Errorf("%w: %s", solver.ErrBadRequest, batchResult.Error)
+					continue
+				}
+				results[i].offer = *batchResult.Offer
+			}
+			return results
+		}
+	}
+	for i, resourceOffer := range resourceOffers {
+		results[i].offer, results[i].err = client.AddResourceOffer(resourceOffer)
+	}
+	return results
 }
 
 /*
@@ -294,9 +1063,19 @@ func (controller *ResourceProviderController) ensureResourceOffers() error {
 */
 
 // list the deals we have been assigned to that we have not yet posted and agree tx to the contract for
-func (controller *ResourceProviderController) agreeToDeals() error {
+// returns how many deals we tried to agree to
+func (controller *ResourceProviderController) agreeToDeals(ctx context.Context, conn *solverConnection) (int, int, error) {
+	ctx, span := startSpan(ctx, "agreeToDeals", attribute.String("solver", conn.address))
+	tried, agreed, err := controller.agreeToMatchedDeals(ctx, conn)
+	span.SetAttributes(attribute.Int("deals_tried", tried), attribute.Int("deals_agreed", agreed))
+	endSpan(span, err)
+	return tried, agreed, err
+}
+
+func (controller *ResourceProviderController) agreeToMatchedDeals(ctx context.Context, conn *solverConnection) (int, int, error) {
+	log := controller.log.Ctx(ctx)
 	// load all deals that are in DealAgreed state and are for us
-	matchedDeals, err := controller.solverClient.GetDealsWithFilter(
+	matchedDeals, err := conn.client.WithContext(ctx).GetDealsWithFilter(
 		store.GetDealsQuery{
 			ResourceProvider: controller.web3SDK.GetAddress().String(),
 			State:            "DealNegotiating",
@@ -307,41 +1086,622 @@ func (controller *ResourceProviderController) agreeToDeals() error {
 		},
 	)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	if len(matchedDeals) <= 0 {
-		return nil
+		return 0, 0, nil
 	}
 
-	// map over the deals and agree to them
+	controller.pruneAgreeAttempts(conn, matchedDeals)
+	// we might have missed the DealAdded event for some of these
 	for _, dealContainer := range matchedDeals {
-		controller.log.Info("agree", dealContainer)
-		txHash, err := controller.web3SDK.Agree(dealContainer.Deal)
+		controller.dealTimers.start(dealContainer.ID, controller.clock.Now())
+	}
+
+	// work out which deals we are allowed to try right now
+	agreeableDeals := []data.DealContainer{}
+	for _, dealContainer := range matchedDeals {
+		// the solver should never match a spec we can't read but if it does
+		// the deal can't be run so we skip it rather than fail the solve
+		module, inputs, err := getDealSpec(dealContainer.Deal)
 		if err != nil {
-			// TODO: we need a way of deciding based on certain classes of error what happens
-			// some will be retryable - otherwise will be fatal
-			// we need a way to exit a job loop as a baseline
-			controller.log.Error("error calling agree tx for deal", err)
+			controller.rejectDeal(ctx, dealContainer, DealRejectMalformedSpec, err.Error())
+			continue
+		}
+		if reason, detail := controller.isDealAllowed(dealContainer); reason != "" {
+			controller.rejectDeal(ctx, dealContainer, reason, detail)
+			continue
+		}
+		if ok, detail := controller.isDealPriceAcceptable(dealContainer); !ok {
+			controller.rejectDeal(ctx, dealContainer, DealRejectUnderpriced, detail)
+			continue
+		}
+		if !controller.canAttemptAgree(dealContainer.ID) {
+			continue
+		}
+		if controller.isAgreePending(ctx, dealContainer.ID) {
+			log.Debug(fmt.Sprintf("skipping deal %s: our agree tx is still pending", dealContainer.ID), dealContainer.JobCreator)
+			continue
+		}
+		if ok, detail := controller.isDealFresh(dealContainer); !ok {
+			controller.rejectDeal(ctx, dealContainer, DealRejectTooOld, detail)
+			continue
+		}
+		if expired, detail := controller.isDealExpired(dealContainer); expired {
+			controller.rejectDeal(ctx, dealContainer, DealRejectExpired, detail)
+			continue
+		}
+		log.Debug(fmt.Sprintf("deal %s can be agreed to", dealContainer.ID), dealContainer.JobCreator,
+			system.F("module", getModuleName(module)),
+			system.F("inputs", inputs),
+		)
+		agreeableDeals = append(agreeableDeals, dealContainer)
+	}
+
+	if len(agreeableDeals) <= 0 {
+		return 0, 0, nil
+	}
+	runningDeals, err := controller.getRunningDeals(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// never agree to more deals than our specs can run at once
+	specCapacity := newSpecCapacity(controller.options.Offers.Specs)
+	for _, dealContainer := range runningDeals {
+		specCapacity.use(controller.getResourceOfferKey(dealContainer.Deal.ResourceOffer))
+	}
+	withCapacity := []data.DealContainer{}
+	for _, dealContainer := range agreeableDeals {
+		if ok, detail := specCapacity.reserve(controller.getResourceOfferKey(dealContainer.Deal.ResourceOffer)); !ok {
+			controller.rejectDeal(ctx, dealContainer, DealRejectOverCapacity, detail)
 			continue
 		}
-		controller.log.Info("agree tx", txHash)
+		withCapacity = append(withCapacity, dealContainer)
+	}
+	agreeableDeals = withCapacity
+
+	// only take on as many deals as we have room for
+	// the rest stay negotiating and are picked up on a later solve
+	if controller.options.MaxConcurrentDeals > 0 && len(agreeableDeals) > 0 {
+		running := len(runningDeals)
+		capacity := controller.options.MaxConcurrentDeals - running
+		if capacity < 0 {
+			capacity = 0
+		}
+		if len(agreeableDeals) > capacity {
+			detail := fmt.Sprintf("running: %d, max: %d", running, controller.options.MaxConcurrentDeals)
+			for _, dealContainer := range agreeableDeals[capacity:] {
+				controller.rejectDeal(ctx, dealContainer, DealRejectMaxConcurrentDeals, detail)
+			}
+			agreeableDeals = agreeableDeals[:capacity]
+		}
+	}
 
-		// we have agreed to the deal so we need to update the tx in the solver
-		_, err = controller.solverClient.UpdateTransactionsResourceProvider(dealContainer.ID, data.DealTransactionsResourceProvider{
-			Agree: txHash,
+	// failed deals are backed off and retried on a later solve
+	// so we log the errors rather than stopping the solve loop
+	agreed, err := controller.agreeToDealsConcurrently(ctx, conn, agreeableDeals)
+	if err != nil {
+		log.Error("error agreeing to deals", err)
+	}
+
+	return len(agreeableDeals), agreed, nil
+}
+
+// how many deals we have agreed to that have not finished yet across all our solvers
+// this counts deals we have sent an agree tx for that the solver still has as negotiating
+func (controller *ResourceProviderController) countRunningDeals(ctx context.Context) (int, error) {
+	runningDeals, err := controller.getRunningDeals(ctx)
+	return len(runningDeals), err
+}
+
+// the deals counted by countRunningDeals
+func (controller *ResourceProviderController) getRunningDeals(ctx context.Context) ([]data.DealContainer, error) {
+	address := controller.web3SDK.GetAddress().String()
+	running := map[string]data.DealContainer{}
+	agreed := data.GetAgreementStateIndex("DealAgreed")
+	for _, conn := range controller.solvers {
+		deals, err := conn.client.WithContext(ctx).GetDealsWithFilter(
+			store.GetDealsQuery{
+				ResourceProvider: address,
+				States:           []string{"DealAgreed", "DealNegotiating"},
+			},
+			func(dealContainer data.DealContainer) bool {
+				return dealContainer.State == agreed || dealContainer.Transactions.ResourceProvider.Agree != ""
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, dealContainer := range deals {
+			running[dealContainer.ID] = dealContainer
+		}
+	}
+	runningDeals := []data.DealContainer{}
+	for _, dealContainer := range running {
+		runningDeals = append(runningDeals, dealContainer)
+	}
+	return runningDeals, nil
+}
+
+// on a big backlog polling can turn up deals whose job has been waiting so long
+// it is no longer worth running - a deal we have just had a DealAdded event for
+// is always fresh as the job creator is still around to have it matched
+func (controller *ResourceProviderController) isDealFresh(dealContainer data.DealContainer) (bool, string) {
+	createdAt := dealContainer.Deal.JobOffer.CreatedAt
+	if controller.options.MaxDealAge <= 0 || createdAt <= 0 {
+		return true, ""
+	}
+	now := controller.clock.Now()
+	if controller.seenDeals.contains(dealContainer.ID, now) {
+		return true, ""
+	}
+	age := now.Sub(time.UnixMilli(int64(createdAt)))
+	if age <= controller.options.MaxDealAge {
+		return true, ""
+	}
+	return false, fmt.Sprintf("deal is %s old which is more than the max of %s", age.Round(time.Second), controller.options.MaxDealAge)
+}
+
+// a deal whose agree window has already closed on-chain would just revert
+// so we don't waste gas on it
+// if we can't read the agreement we assume the deal is still open
+func (controller *ResourceProviderController) isDealExpired(dealContainer data.DealContainer) (bool, string) {
+	agreement, err := controller.web3SDK.GetAgreement(dealContainer.ID)
+	if err != nil {
+		controller.log.Error(fmt.Sprintf("error getting agreement for deal %s", dealContainer.ID), err)
+		return false, ""
+	}
+	if agreement.DealCreatedAt == nil || !agreement.DealCreatedAt.IsUint64() {
+		return false, ""
+	}
+	deadline, ok := getAgreeDeadline(dealContainer.Deal, agreement.DealCreatedAt.Uint64())
+	if !ok || controller.clock.Now().Before(deadline) {
+		return false, ""
+	}
+	return true, fmt.Sprintf("the agree window closed at %s", deadline.UTC().Format(time.RFC3339))
+}
+
+// agree to the given deals using a bounded pool of workers
+// the web3 SDK serializes tx submission so the workers don't clash on nonces
+// but the (slow) wait for each tx to be mined happens in parallel
+// returns how many of the deals we now have a mined agree tx for
+func (controller *ResourceProviderController) agreeToDealsConcurrently(ctx context.Context, conn *solverConnection, deals []data.DealContainer) (int, error) {
+	concurrency := controller.options.AgreeConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(deals) {
+		concurrency = len(deals)
+	}
+
+	dealsChan := make(chan data.DealContainer)
+	errorsMutex := sync.Mutex{}
+	errs := []error{}
+	agreed := 0
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for dealContainer := range dealsChan {
+				// don't start any more agree txs once the solve has been abandoned
+				if ctx.Err() != nil {
+					continue
+				}
+				ok, err := controller.agreeToDeal(ctx, conn, dealContainer)
+				errorsMutex.Lock()
+				if ok {
+					agreed++
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("deal %s: %w", dealContainer.ID, err))
+				}
+				errorsMutex.Unlock()
+			}
+		}()
+	}
+
+	for _, dealContainer := range deals {
+		dealsChan <- dealContainer
+	}
+	close(dealsChan)
+	wg.Wait()
+
+	return agreed, errors.Join(errs...)
+}
+
+func (controller *ResourceProviderController) agreeToDeal(ctx context.Context, conn *solverConnection, dealContainer data.DealContainer) (bool, error) {
+	// deals are agreed to in parallel so tag everything we log with the deal
+	ctx = system.WithLogFields(ctx, system.F("dealID", dealContainer.ID))
+	log := controller.log.Ctx(ctx)
+	if !controller.beginWork() {
+		return false, fmt.Errorf("shutting down")
+	}
+	defer controller.endWork()
+
+	if controller.options.DryRun {
+		log.Info("dry run: would agree to deal", dealContainer)
+		return false, nil
+	}
+
+	// make sure we don't agree to the same deal via two solvers
+	if !controller.claimDeal(dealContainer.ID, conn.address) {
+		log.Debug("deal already claimed via another solver", dealContainer.ID)
+		return false, nil
+	}
+
+	log.Info("agree", dealContainer)
+	// once an agree tx has been sent we want it to be mined even if we are
+	// shutting down (see drainInflightWork) so only the solve deadline applies
+	agreeCtx, cancel := withDeadlineOnly(ctx)
+	defer cancel()
+	txHash, err := controller.sendAgreeTx(agreeCtx, conn, dealContainer)
+	if err != nil {
+		dealsFailedTotal.Inc()
+		controller.releaseDeal(dealContainer.ID)
+		controller.recordAgreeFailure(conn.address, dealContainer.ID, err)
+		if controller.options.OnDealFailed != nil {
+			controller.options.OnDealFailed(dealContainer.Deal, err)
+		}
+		controller.notifyWebhook(WebhookEvent{
+			Type:   WEBHOOK_EVENT_AGREE_FAILED,
+			DealID: dealContainer.ID,
+			Error:  err.Error(),
 		})
+		return false, err
+	}
+	dealsAgreedTotal.Inc()
+	if latency, ok := controller.dealTimers.stop(dealContainer.ID, controller.clock.Now()); ok {
+		dealAgreeLatency.Observe(latency.Seconds())
+	}
+	controller.addAgreedDeal()
+	log.Info("agree tx", txHash)
+	err = controller.writeAuditLog(conn, dealContainer, txHash)
+	if err != nil {
+		log.Error("error writing audit log", err)
+	}
+	if controller.options.OnDealAgreed != nil {
+		controller.options.OnDealAgreed(dealContainer.Deal)
+	}
+	controller.notifyWebhook(WebhookEvent{
+		Type:   WEBHOOK_EVENT_DEAL_AGREED,
+		DealID: dealContainer.ID,
+		TxHash: txHash,
+	})
+	controller.clearAgreeAttempts(dealContainer.ID)
+
+	// we have agreed to the deal so we need to update the tx in the solver
+	_, err = conn.client.UpdateTransactionsResourceProvider(dealContainer.ID, data.DealTransactionsResourceProvider{
+		Agree: txHash,
+	})
+	if err != nil {
+		// TODO: we need a way of deciding based on certain classes of error what happens
+		// some will be retryable - otherwise will be fatal
+		// we need a way to exit a job loop as a baseline
+		log.Error("error adding agree tx hash for deal", err)
+		return true, err
+	}
+	log.Info("updated deal with agree tx", txHash)
+	return true, nil
+}
+
+// send the agree tx for a deal and wait for it to be mined
+// if we already sent one (possibly before we restarted) we wait for that instead
+func (controller *ResourceProviderController) sendAgreeTx(ctx context.Context, conn *solverConnection, dealContainer data.DealContainer) (string, error) {
+	log := controller.log.Ctx(ctx)
+	previous, ok := controller.state.getDeal(dealContainer.ID)
+	if ok && previous.AgreeTx != "" {
+		if previous.Agreed {
+			log.Info("deal already agreed", previous.AgreeTx)
+			return previous.AgreeTx, nil
+		}
+		log.Info("waiting for previous agree tx", previous.AgreeTx)
+		controller.pendingAgrees.add(dealContainer.ID, previous.AgreeTx)
+		_, err := controller.web3SDK.WaitTxHashSuccess(ctx, previous.AgreeTx)
+		if err == nil || ctx.Err() == nil {
+			controller.pendingAgrees.remove(dealContainer.ID)
+		}
+		if err == nil {
+			controller.saveDealState(dealContainer.ID, persistedDeal{
+				Solver:  conn.address,
+				AgreeTx: previous.AgreeTx,
+				Agreed:  true,
+			})
+			return previous.AgreeTx, nil
+		}
+		// if we just ran out of time the tx might still be mined
+		// so keep it and we'll wait for it again next time
+		if ctx.Err() != nil {
+			return "", err
+		}
+		// otherwise it reverted or was dropped so we need to send a new one
+		log.Error("previous agree tx failed", err)
+		controller.forgetDealState(dealContainer.ID)
+	}
+
+	if controller.options.SimulateBeforeSend {
+		err := controller.web3SDK.SimulateAgree(ctx, dealContainer.Deal)
+		revertErr := &web3.RevertError{}
+		if errors.As(err, &revertErr) {
+			controller.rejectDeal(ctx, dealContainer, DealRejectWouldRevert, revertErr.Reason)
+			return "", err
+		}
+		// the simulation is only a precaution so if we couldn't run it we send the tx anyway
 		if err != nil {
-			// TODO: we need a way of deciding based on certain classes of error what happens
-			// some will be retryable - otherwise will be fatal
-			// we need a way to exit a job loop as a baseline
-			controller.log.Error("error adding agree tx hash for deal", err)
-			continue
+			log.Error("error simulating agree tx", err)
 		}
-		controller.log.Info("updated deal with agree tx", txHash)
 	}
 
-	return err
+	err := controller.agreeRateLimiter.wait(ctx)
+	if err != nil {
+		return "", err
+	}
+	tx, err := controller.web3SDK.SubmitAgree(ctx, dealContainer.Deal)
+	if err != nil {
+		return "", err
+	}
+	txHash := tx.Hash().String()
+	controller.saveDealState(dealContainer.ID, persistedDeal{
+		Solver:  conn.address,
+		AgreeTx: txHash,
+	})
+	controller.pendingAgrees.add(dealContainer.ID, txHash)
+
+	// only treat the deal as agreed once the tx has been mined successfully
+	receipt, err := controller.web3SDK.WaitTxSuccess(ctx, tx)
+	// if we ran out of time the tx is still out there so later solves leave the deal alone
+	if err == nil || ctx.Err() == nil {
+		controller.pendingAgrees.remove(dealContainer.ID)
+	}
+	if err != nil {
+		log.Error("agree tx failed", err)
+		if ctx.Err() == nil {
+			controller.forgetDealState(dealContainer.ID)
+		}
+		return "", err
+	}
+	// the tx might have been resent with more gas so use the hash that was mined
+	txHash = receipt.TxHash.String()
+	controller.saveDealState(dealContainer.ID, persistedDeal{
+		Solver:  conn.address,
+		AgreeTx: txHash,
+		Agreed:  true,
+	})
+	return txHash, nil
+}
+
+// failing to write the state file shouldn't stop us working
+// we just lose the protection against resending txs after a restart
+func (controller *ResourceProviderController) saveDealState(dealID string, deal persistedDeal) {
+	err := controller.state.setDeal(dealID, deal)
+	if err != nil {
+		controller.log.Error("error saving deal state", err)
+	}
+}
+
+func (controller *ResourceProviderController) forgetDealState(dealID string) {
+	err := controller.state.removeDeal(dealID)
+	if err != nil {
+		controller.log.Error("error saving deal state", err)
+	}
+}
+
+// we only agree to deals with job creators and mediators we are willing to work with
+// returns an empty reason if the deal is allowed
+func (controller *ResourceProviderController) isDealAllowed(dealContainer data.DealContainer) (DealRejectReason, string) {
+	if ok, detail := controller.isJobCreatorAllowed(dealContainer.JobCreator); !ok {
+		return DealRejectJobCreatorNotAllowed, detail
+	}
+	module, err := dealContainer.Deal.Module()
+	if err != nil {
+		return DealRejectMalformedSpec, err.Error()
+	}
+	if ok, detail := controller.isModuleOffered(module); !ok {
+		return DealRejectModuleNotOffered, detail
+	}
+	if ok, detail := controller.isMediatorTrusted(dealContainer.Deal.Members.Mediators); !ok {
+		return DealRejectMediatorNotTrusted, detail
+	}
+	return "", ""
+}
 
+// a job creator could make a deal against our offer on worse terms than we set
+// so every part of the deal's pricing is checked against our pricing for its module
+// right now - paying us more or putting up more collateral for us is fine but
+// asking more collateral of us or a different mediation fee is not
+func (controller *ResourceProviderController) isDealPriceAcceptable(dealContainer data.DealContainer) (bool, string) {
+	module := getPricedModule(controller.options.Offers, dealContainer.Deal.JobOffer.Module)
+	expected := controller.pricingStrategy.PriceFor(dealContainer.Deal.ResourceOffer.Spec, module)
+	offered := dealContainer.Deal.Pricing
+	if offered.InstructionPrice < expected.InstructionPrice {
+		return false, fmt.Sprintf("instruction price %d is below our price of %d for module %q", offered.InstructionPrice, expected.InstructionPrice, module)
+	}
+	if offered.PaymentCollateral < expected.PaymentCollateral {
+		return false, fmt.Sprintf("payment collateral %d is below our %d for module %q", offered.PaymentCollateral, expected.PaymentCollateral, module)
+	}
+	if offered.ResultsCollateralMultiple > expected.ResultsCollateralMultiple {
+		return false, fmt.Sprintf("results collateral multiple %d is above our %d for module %q", offered.ResultsCollateralMultiple, expected.ResultsCollateralMultiple, module)
+	}
+	if offered.MediationFee != expected.MediationFee {
+		return false, fmt.Sprintf("mediation fee %d is not our %d for module %q", offered.MediationFee, expected.MediationFee, module)
+	}
+	return true, ""
+}
+
+// the solver should only match us with modules from our offers but we
+// check anyway so we never run a module we haven't curated
+// modules can be listed by name (e.g. cowsay:v0.0.1) or by module id
+// and an empty list means we run anything
+func (controller *ResourceProviderController) isModuleOffered(module data.ModuleConfig) (bool, string) {
+	offered := controller.options.Offers.Modules
+	if len(offered) == 0 {
+		return true, ""
+	}
+	moduleID, err := data.GetModuleID(module)
+	if err != nil {
+		return false, fmt.Sprintf("error getting module id for %s: %s", module.Name, err.Error())
+	}
+	for _, offeredModule := range offered {
+		if offeredModule == moduleID || (module.Name != "" && offeredModule == module.Name) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("module %s is not offered", getModuleName(module))
+}
+
+// the module and inputs a deal asks us to run
+func getDealSpec(deal data.Deal) (data.ModuleConfig, map[string]string, error) {
+	module, err := deal.Module()
+	if err != nil {
+		return data.ModuleConfig{}, nil, err
+	}
+	inputs, err := deal.Inputs()
+	if err != nil {
+		return data.ModuleConfig{}, nil, err
+	}
+	return module, inputs, nil
+}
+
+// the shortcut name if the module has one otherwise its id
+func getModuleName(module data.ModuleConfig) string {
+	if module.Name != "" {
+		return module.Name
+	}
+	moduleID, err := data.GetModuleID(module)
+	if err != nil {
+		return module.Repo
+	}
+	return moduleID
+}
+
+// our offers only list the mediators we trust but the solver could still
+// match us into a deal with others so every mediator on the deal must be one of ours
+// no configured mediators means we trust any
+func (controller *ResourceProviderController) isMediatorTrusted(mediators []string) (bool, string) {
+	trusted := controller.options.Offers.Services.Mediator
+	if len(trusted) == 0 {
+		return true, ""
+	}
+	for _, mediator := range mediators {
+		found := false
+		for _, trustedMediator := range trusted {
+			if strings.EqualFold(trustedMediator, mediator) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, fmt.Sprintf("mediator %s is not trusted", mediator)
+		}
+	}
+	return true, ""
+}
+
+// the denylist always wins, then if there is an allowlist the job creator must be on it
+func (controller *ResourceProviderController) isJobCreatorAllowed(jobCreator string) (bool, string) {
+	for _, denied := range controller.options.JobCreatorDenylist {
+		if strings.EqualFold(denied, jobCreator) {
+			return false, "job creator is denylisted"
+		}
+	}
+	if len(controller.options.JobCreatorAllowlist) == 0 {
+		return true, ""
+	}
+	for _, allowed := range controller.options.JobCreatorAllowlist {
+		if strings.EqualFold(allowed, jobCreator) {
+			return true, ""
+		}
+	}
+	return false, "job creator is not allowlisted"
+}
+
+// returns false if we have given up on this deal or if it is still
+// backing off from a previous failed attempt
+func (controller *ResourceProviderController) canAttemptAgree(dealID string) bool {
+	controller.agreeAttemptsMutex.Lock()
+	defer controller.agreeAttemptsMutex.Unlock()
+	attempt, ok := controller.agreeAttempts[dealID]
+	if !ok {
+		return true
+	}
+	if attempt.count >= controller.getAgreeMaxAttempts() {
+		return false
+	}
+	return !controller.clock.Now().Before(attempt.nextAttempt)
+}
+
+func (controller *ResourceProviderController) recordAgreeFailure(solverAddress string, dealID string, err error) {
+	controller.agreeAttemptsMutex.Lock()
+	defer controller.agreeAttemptsMutex.Unlock()
+	attempt, ok := controller.agreeAttempts[dealID]
+	if !ok {
+		attempt = &agreeAttempt{solver: solverAddress}
+		controller.agreeAttempts[dealID] = attempt
+	}
+	attempt.count++
+	if attempt.count >= controller.getAgreeMaxAttempts() {
+		controller.log.Error(
+			fmt.Sprintf("giving up on agree tx for deal %s after %d attempts", dealID, attempt.count),
+			err,
+		)
+		return
+	}
+	delay := getRetryDelay(controller.options.AgreeRetryBaseDelay, attempt.count, MAX_AGREE_RETRY_DELAY)
+	attempt.nextAttempt = controller.clock.Now().Add(delay)
+	controller.log.Error(
+		fmt.Sprintf("error calling agree tx for deal %s (attempt %d, retrying in %s)", dealID, attempt.count, delay),
+		err,
+	)
+}
+
+// the options can be built without the cli so zero means the default
+func (controller *ResourceProviderController) getAgreeMaxAttempts() int {
+	if controller.options.AgreeMaxAttempts == 0 {
+		return DEFAULT_AGREE_MAX_ATTEMPTS
+	}
+	return controller.options.AgreeMaxAttempts
+}
+
+func (controller *ResourceProviderController) clearAgreeAttempts(dealID string) {
+	controller.agreeAttemptsMutex.Lock()
+	defer controller.agreeAttemptsMutex.Unlock()
+	delete(controller.agreeAttempts, dealID)
+}
+
+// forget about any deals on this solver that are no longer waiting for us to agree
+// (they have been agreed, cancelled or timed out) so the map does not grow forever
+func (controller *ResourceProviderController) pruneAgreeAttempts(conn *solverConnection, negotiatingDeals []data.DealContainer) {
+	controller.agreeAttemptsMutex.Lock()
+	defer controller.agreeAttemptsMutex.Unlock()
+	stillNegotiating := map[string]bool{}
+	for _, dealContainer := range negotiatingDeals {
+		stillNegotiating[dealContainer.ID] = true
+	}
+	for dealID, attempt := range controller.agreeAttempts {
+		if attempt.solver == conn.address && !stillNegotiating[dealID] {
+			delete(controller.agreeAttempts, dealID)
+		}
+	}
+}
+
+// returns false if the deal has already been claimed through a different solver
+func (controller *ResourceProviderController) claimDeal(dealID string, solverAddress string) bool {
+	controller.claimedDealsMutex.Lock()
+	defer controller.claimedDealsMutex.Unlock()
+	claimedBy, ok := controller.claimedDeals[dealID]
+	if ok && claimedBy != solverAddress {
+		return false
+	}
+	controller.claimedDeals[dealID] = solverAddress
+	return true
+}
+
+func (controller *ResourceProviderController) releaseDeal(dealID string) {
+	controller.claimedDealsMutex.Lock()
+	defer controller.claimedDealsMutex.Unlock()
+	delete(controller.claimedDeals, dealID)
 }
 
 /*
@@ -356,8 +1716,9 @@ func (controller *ResourceProviderController) agreeToDeals() error {
  *
 */
 
-func (controller *ResourceProviderController) runJobs() error {
-	agreedDeals, err := controller.solverClient.GetDealsWithFilter(
+// returns how many jobs we started running
+func (controller *ResourceProviderController) runJobs(conn *solverConnection) (int, error) {
+	agreedDeals, err := conn.client.GetDealsWithFilter(
 		store.GetDealsQuery{
 			ResourceProvider: controller.web3SDK.GetAddress().String(),
 			State:            "DealAgreed",
@@ -371,11 +1732,11 @@ func (controller *ResourceProviderController) runJobs() error {
 		},
 	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if len(agreedDeals) <= 0 {
-		return nil
+		return 0, nil
 	}
 
 	// TODO - we are relying on the rate at which we post resource offers
@@ -392,17 +1753,19 @@ func (controller *ResourceProviderController) runJobs() error {
 			controller.runningJobs[dealContainer.ID] = true
 		}()
 
-		go controller.runJob(dealContainer)
+		go controller.runJob(conn, dealContainer)
 	}
 
-	return err
+	return len(agreedDeals), nil
 }
 
 // this is run in it's own go-routine
 // we've already updated controller.runningJobs so we know this will only
 // run once
-func (controller *ResourceProviderController) runJob(deal data.DealContainer) {
+func (controller *ResourceProviderController) runJob(conn *solverConnection, deal data.DealContainer) {
 	controller.log.Info("run job", deal)
+	// once the job is done there is nothing left to protect the deal from
+	defer controller.releaseDeal(deal.ID)
 	result := data.Result{
 		DealID: deal.ID,
 		Error:  "",
@@ -425,7 +1788,7 @@ func (controller *ResourceProviderController) runJob(deal data.DealContainer) {
 
 		controller.log.Info(fmt.Sprintf("uploading results: %s %s %s", deal.ID, executorResult.ResultsDir, executorResult.ResultsCID), executorResult.ResultsDir)
 
-		_, err = controller.solverClient.UploadResultFiles(deal.ID, executorResult.ResultsDir)
+		_, err = conn.client.UploadResultFiles(deal.ID, executorResult.ResultsDir)
 		if err != nil {
 			return fmt.Errorf("error uploading results: %s", err.Error())
 		}
@@ -442,7 +1805,7 @@ func (controller *ResourceProviderController) runJob(deal data.DealContainer) {
 	// the tarball of the results has been uploaded
 	// now let's post the result data itself to the solver
 	// then we will post the results on-chain
-	createdResult, err := controller.solverClient.AddResult(result)
+	createdResult, err := conn.client.AddResult(result)
 	if err != nil {
 		// TODO: what should we do here?
 		// the current path would be the post results times out
@@ -463,7 +1826,7 @@ func (controller *ResourceProviderController) runJob(deal data.DealContainer) {
 		return
 	}
 
-	_, err = controller.solverClient.UpdateTransactionsResourceProvider(deal.ID, data.DealTransactionsResourceProvider{
+	_, err = conn.client.UpdateTransactionsResourceProvider(deal.ID, data.DealTransactionsResourceProvider{
 		AddResult: txHash,
 	})
 	if err != nil {
@@ -0,0 +1,658 @@
+package resourceprovider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/solver"
+	"github.com/bacalhau-project/lilypad/pkg/solver/mock"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/bacalhau-project/lilypad/pkg/web3"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsJobCreatorAllowed(t *testing.T) {
+	controller := &ResourceProviderController{}
+	ok, _ := controller.isJobCreatorAllowed("0xabc")
+	assert.True(t, ok, "Anyone should be allowed with no lists")
+
+	controller.options.JobCreatorDenylist = []string{"0xABC"}
+	ok, reason := controller.isJobCreatorAllowed("0xabc")
+	assert.False(t, ok, "Denylisted job creators should be refused regardless of case")
+	assert.Equal(t, "job creator is denylisted", reason)
+
+	controller.options.JobCreatorDenylist = []string{}
+	controller.options.JobCreatorAllowlist = []string{"0xdef"}
+	ok, reason = controller.isJobCreatorAllowed("0xabc")
+	assert.False(t, ok, "Job creators missing from the allowlist should be refused")
+	assert.Equal(t, "job creator is not allowlisted", reason)
+	ok, _ = controller.isJobCreatorAllowed("0xdef")
+	assert.True(t, ok, "Allowlisted job creators should be allowed")
+}
+
+func TestIsMediatorTrusted(t *testing.T) {
+	controller := &ResourceProviderController{}
+	ok, _ := controller.isMediatorTrusted([]string{"0xabc"})
+	assert.True(t, ok, "Any mediator should be trusted with none configured")
+
+	controller.options.Offers.Services.Mediator = []string{"0xABC"}
+	ok, _ = controller.isMediatorTrusted([]string{"0xabc"})
+	assert.True(t, ok, "Configured mediators should be trusted regardless of case")
+	ok, reason := controller.isMediatorTrusted([]string{"0xabc", "0xdef"})
+	assert.False(t, ok, "Every mediator on the deal should have to be trusted")
+	assert.Equal(t, "mediator 0xdef is not trusted", reason)
+}
+
+func TestIsModuleOffered(t *testing.T) {
+	controller := &ResourceProviderController{}
+	cowsay := data.ModuleConfig{Name: "cowsay:v0.0.1", Repo: "https://github.com/lilypad-tech/lilypad-module-cowsay", Hash: "v0.0.1"}
+	ok, _ := controller.isModuleOffered(cowsay)
+	assert.True(t, ok, "Any module should be offered with none configured")
+
+	controller.options.Offers.Modules = []string{"cowsay:v0.0.1"}
+	ok, _ = controller.isModuleOffered(cowsay)
+	assert.True(t, ok, "Modules should be offered by name")
+
+	sdxl := data.ModuleConfig{Name: "sdxl:v0.9", Repo: "https://github.com/lilypad-tech/lilypad-module-sdxl", Hash: "v0.9"}
+	ok, reason := controller.isModuleOffered(sdxl)
+	assert.False(t, ok)
+	assert.Equal(t, "module sdxl:v0.9 is not offered", reason)
+
+	sdxlID, err := data.GetModuleID(sdxl)
+	assert.NoError(t, err)
+	controller.options.Offers.Modules = []string{sdxlID}
+	ok, _ = controller.isModuleOffered(sdxl)
+	assert.True(t, ok, "Modules should be offered by id")
+}
+
+func TestAgreeMaxAttemptsDefault(t *testing.T) {
+	controller, _, _ := newTestController(t, ResourceProviderOptions{AgreeRetryBaseDelay: time.Minute})
+	controller.agreeAttempts = map[string]*agreeAttempt{}
+	clock := system.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	controller.clock = clock
+	for attempt := 1; attempt < DEFAULT_AGREE_MAX_ATTEMPTS; attempt++ {
+		controller.recordAgreeFailure("0xsolver", "deal1", fmt.Errorf("agree failed"))
+		assert.False(t, controller.canAttemptAgree("deal1"), "Should back off after a failure")
+		clock.Advance(MAX_AGREE_RETRY_DELAY)
+		assert.True(t, controller.canAttemptAgree("deal1"), "Options built without the cli should still retry")
+	}
+	controller.recordAgreeFailure("0xsolver", "deal1", fmt.Errorf("agree failed"))
+	clock.Advance(MAX_AGREE_RETRY_DELAY)
+	assert.False(t, controller.canAttemptAgree("deal1"), "Should give up after the default number of attempts")
+}
+
+func newTestController(t *testing.T, options ResourceProviderOptions) (*ResourceProviderController, *solverConnection, *mock.SolverClient) {
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	state, err := newStateStore("")
+	assert.NoError(t, err)
+	client, err := mock.NewSolverClient("http://solver")
+	assert.NoError(t, err)
+	conn := &solverConnection{
+		address: "0xsolver",
+		client:  client,
+	}
+	controller := &ResourceProviderController{
+		solvers:         []*solverConnection{conn},
+		options:         options,
+		web3SDK:         &web3.Web3SDK{Signer: web3.NewPrivateKeySigner(privateKey)},
+		log:             system.NewServiceLogger(system.ResourceProviderService),
+		state:           state,
+		withdrawnOffers: map[int]bool{},
+		claimedDeals:    map[string]string{},
+		pricingStrategy: NewStaticPricingStrategy(options.Offers),
+		seenDeals:       newSeenDeals(time.Minute, SEEN_DEALS_MAX_SIZE),
+		rejectedDeals:   newSeenDeals(REJECTED_DEALS_WINDOW, SEEN_DEALS_MAX_SIZE),
+		dealTimers:      newDealTimers(SEEN_DEALS_MAX_SIZE),
+		pendingAgrees:   newPendingAgrees(),
+		clock:           system.RealClock,
+	}
+	return controller, conn, client
+}
+
+func TestEnsureResourceOffers(t *testing.T) {
+	specs := []OfferSpec{
+		{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+		{MachineSpec: data.MachineSpec{CPU: 2000, RAM: 2048}},
+	}
+
+	testCases := []struct {
+		name          string
+		setup         func(controller *ResourceProviderController, conn *solverConnection, client *mock.SolverClient)
+		expectedAdded int
+		expectedTotal int
+		expectError   bool
+	}{
+		{
+			name:          "Posts every spec to an empty solver",
+			setup:         func(*ResourceProviderController, *solverConnection, *mock.SolverClient) {},
+			expectedAdded: 2,
+			expectedTotal: 2,
+		},
+		{
+			name: "Only posts the missing indexes",
+			setup: func(controller *ResourceProviderController, conn *solverConnection, client *mock.SolverClient) {
+				_, err := client.AddResourceOffer(controller.getResourceOffer(conn.address, 0, specs[0]))
+				assert.NoError(t, err)
+			},
+			expectedAdded: 1,
+			expectedTotal: 2,
+		},
+		{
+			name: "Replaces offers that no longer match our config",
+			setup: func(controller *ResourceProviderController, conn *solverConnection, client *mock.SolverClient) {
+				resourceOffer := controller.getResourceOffer(conn.address, 0, specs[0])
+				resourceOffer.DefaultPricing.InstructionPrice = 99
+				_, err := client.AddResourceOffer(resourceOffer)
+				assert.NoError(t, err)
+			},
+			expectedAdded: 2,
+			expectedTotal: 2,
+		},
+		{
+			name: "Skips withdrawn offers",
+			setup: func(controller *ResourceProviderController, conn *solverConnection, client *mock.SolverClient) {
+				controller.withdrawnOffers[1] = true
+			},
+			expectedAdded: 1,
+			expectedTotal: 1,
+		},
+		{
+			name: "Posts nothing in a dry run",
+			setup: func(controller *ResourceProviderController, conn *solverConnection, client *mock.SolverClient) {
+				controller.options.DryRun = true
+			},
+			expectedAdded: 0,
+			expectedTotal: 0,
+		},
+		{
+			name: "Returns the error when the solver is down",
+			setup: func(controller *ResourceProviderController, conn *solverConnection, client *mock.SolverClient) {
+				client.SetError(solver.ErrSolverUnavailable)
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options := ResourceProviderOptions{}
+			options.Offers.Specs = specs
+			controller, conn, client := newTestController(t, options)
+			tc.setup(controller, conn, client)
+
+			added, err := controller.ensureResourceOffers(context.Background(), conn)
+			if tc.expectError {
+				assert.ErrorIs(t, err, solver.ErrSolverUnavailable)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAdded, added)
+
+			offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{
+				ResourceProvider: controller.web3SDK.GetAddress().String(),
+			})
+			assert.NoError(t, err)
+			assert.Len(t, offers, tc.expectedTotal)
+		})
+	}
+}
+
+func TestEnsureResourceOffersWithSlots(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 4000, GPU: 2000, RAM: 4096}, Slots: 2}}
+	controller, conn, client := newTestController(t, options)
+	address := controller.web3SDK.GetAddress().String()
+	getOffers := func() map[int]data.ResourceOfferContainer {
+		offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{ResourceProvider: address})
+		assert.NoError(t, err)
+		ret := map[int]data.ResourceOfferContainer{}
+		for _, offer := range offers {
+			ret[offer.ResourceOffer.Index] = offer
+		}
+		return ret
+	}
+
+	added, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, added, "The whole machine and each slot should be offered")
+	offers := getOffers()
+	assert.Equal(t, data.MachineSpec{CPU: 4000, GPU: 2000, RAM: 4096}, offers[0].ResourceOffer.Spec)
+	assert.Equal(t, data.MachineSpec{CPU: 2000, GPU: 1000, RAM: 2048}, offers[getOfferIndex(0, 1)].ResourceOffer.Spec)
+	assert.Equal(t, data.MachineSpec{CPU: 2000, GPU: 1000, RAM: 2048}, offers[getOfferIndex(0, 2)].ResourceOffer.Spec)
+
+	// a job takes one slot so the whole machine is no longer free
+	assert.NoError(t, client.MatchResourceOffer(offers[getOfferIndex(0, 1)].ID, "deal1"))
+	_, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	offers = getOffers()
+	assert.NotContains(t, offers, 0, "The whole machine offer should be taken down")
+	assert.Contains(t, offers, getOfferIndex(0, 2), "The other slot is still free")
+
+	// once the whole machine is taken none of the slots are free
+	assert.NoError(t, client.MatchResourceOffer(offers[getOfferIndex(0, 1)].ID, ""))
+	_, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	offers = getOffers()
+	assert.NoError(t, client.MatchResourceOffer(offers[0].ID, "deal2"))
+	_, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	offers = getOffers()
+	assert.Len(t, offers, 1)
+	assert.Contains(t, offers, 0)
+}
+
+func TestEnsureResourceOffersKeepsNamedSpecsWhenReordered(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{
+		{ID: "small", MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+		{ID: "large", MachineSpec: data.MachineSpec{CPU: 4000, RAM: 4096}},
+	}
+	controller, conn, _ := newTestController(t, options)
+
+	added, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	// a new spec at the front moves the others down but they are still posted
+	controller.options.Offers.Specs = []OfferSpec{
+		{ID: "medium", MachineSpec: data.MachineSpec{CPU: 2000, RAM: 2048}},
+		{ID: "large", MachineSpec: data.MachineSpec{CPU: 4000, RAM: 4096}},
+		{ID: "small", MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+	}
+	added, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, added, "Only the new spec should be posted")
+
+	assert.NoError(t, controller.RemoveOffer(2))
+	_, ok := controller.state.getOffer(conn.address, getOfferKey("small", 0))
+	assert.False(t, ok, "Removing a spec should find its offer by name")
+
+	// the spec ID isn't sent to the solver so an offer we have no record of
+	// is taken to be for the spec at its position
+	controller.state, err = newStateStore("")
+	assert.NoError(t, err)
+	assert.Equal(t, getOfferKey("large", 1), controller.getResourceOfferKey(data.ResourceOffer{ID: "unknown", Index: 1}))
+}
+
+func TestEnsureResourceOffersPostsABatch(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{
+		{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+		{MachineSpec: data.MachineSpec{CPU: 2000, RAM: 2048}},
+		{MachineSpec: data.MachineSpec{CPU: 4000, RAM: 4096}},
+	}
+
+	controller, conn, client := newTestController(t, options)
+	client.SetOfferError(func(offer data.ResourceOffer) error {
+		if offer.Spec.CPU == 2000 {
+			return fmt.Errorf("bad offer")
+		}
+		return nil
+	})
+	added, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.ErrorIs(t, err, errResourceOffersNotPosted)
+	assert.Equal(t, 2, added, "The rest of the batch should be added when one offer fails")
+	assert.Equal(t, 1, client.GetBatchCalls())
+	_, ok := controller.state.getOffer(conn.address, getOfferKey("", 1))
+	assert.False(t, ok)
+	_, ok = controller.state.getOffer(conn.address, getOfferKey("", 2))
+	assert.True(t, ok)
+
+	// an old solver without the batch endpoint gets them one at a time
+	controller, conn, client = newTestController(t, options)
+	client.SetBatchUnsupported(true)
+	added, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, added)
+	assert.True(t, conn.batchUnsupported)
+	controller.options.Offers.Specs = append(controller.options.Offers.Specs, OfferSpec{MachineSpec: data.MachineSpec{CPU: 8000, RAM: 8192}}, OfferSpec{MachineSpec: data.MachineSpec{CPU: 16000, RAM: 16384}})
+	_, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, client.GetBatchCalls(), "Should not try the batch endpoint again")
+}
+
+func TestEnsureResourceOffersRefreshesExpiringOffers(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}}
+	options.Offers.OfferTTL = 10 * time.Minute
+	options.Offers.OfferRefreshMargin = time.Minute
+	controller, conn, client := newTestController(t, options)
+	clock := system.NewFakeClock(time.Now())
+	controller.clock = clock
+
+	_, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	posted, ok := controller.state.getOffer(conn.address, getOfferKey("", 0))
+	assert.True(t, ok)
+
+	clock.Advance(8 * time.Minute)
+	_, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	current, _ := controller.state.getOffer(conn.address, getOfferKey("", 0))
+	assert.Equal(t, posted.ID, current.ID, "Should not refresh an offer before the margin")
+
+	clock.Advance(time.Minute)
+	_, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	current, _ = controller.state.getOffer(conn.address, getOfferKey("", 0))
+	assert.NotEqual(t, posted.ID, current.ID, "Should post the offer again once it is within the margin of expiring")
+	offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{
+		ResourceProvider: controller.web3SDK.GetAddress().String(),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, offers, 1)
+}
+
+func TestEnsureResourceOffersResyncsAfterSolverRestart(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{
+		{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+		{MachineSpec: data.MachineSpec{CPU: 2000, RAM: 2048}},
+	}
+	controller, conn, _ := newTestController(t, options)
+	_, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+
+	// a restarted solver comes back with none of our offers
+	restarted, err := mock.NewSolverClient("http://solver")
+	assert.NoError(t, err)
+	conn.client = restarted
+	resyncs := testutil.ToFloat64(offerResyncsTotal.WithLabelValues(conn.address))
+	added, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added, "Every offer should be posted again in the same solve")
+	assert.Equal(t, resyncs+1, testutil.ToFloat64(offerResyncsTotal.WithLabelValues(conn.address)))
+	offers, err := restarted.GetResourceOffers(store.GetResourceOffersQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, offers, 2)
+	_, ok := controller.state.getOffer(conn.address, getOfferKey("", 0))
+	assert.True(t, ok)
+
+	added, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, added)
+	assert.Equal(t, resyncs+1, testutil.ToFloat64(offerResyncsTotal.WithLabelValues(conn.address)), "Should only resync when the offers are lost")
+}
+
+func TestRemoveOfferUsesPostedID(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}}
+	controller, conn, client := newTestController(t, options)
+
+	_, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	postedOffer, ok := controller.state.getOffer(conn.address, getOfferKey("", 0))
+	assert.True(t, ok, "The id the solver gave the offer should be remembered")
+
+	assert.NoError(t, controller.RemoveOffer(0))
+	_, ok = controller.state.getOffer(conn.address, getOfferKey("", 0))
+	assert.False(t, ok)
+	offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{
+		ResourceProvider: controller.web3SDK.GetAddress().String(),
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, offers, "The posted offer %s should have been removed from the solver", postedOffer.ID)
+}
+
+func TestRemoveAllOffersIncludesOrphans(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}}
+	controller, conn, client := newTestController(t, options)
+
+	_, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	// left behind by a config we no longer run with
+	_, err = client.AddResourceOffer(controller.getResourceOffer(conn.address, 5, OfferSpec{MachineSpec: data.MachineSpec{CPU: 500, RAM: 512}}))
+	assert.NoError(t, err)
+
+	offers, err := controller.ListActiveOffers(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, offers, 2)
+
+	assert.NoError(t, controller.RemoveAllOffers(context.Background()))
+	offers, err = controller.ListActiveOffers(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, offers)
+	assert.Zero(t, controller.state.countOffers(conn.address), "The removed offers should be forgotten")
+}
+
+func TestCountRunningDeals(t *testing.T) {
+	controller, _, client := newTestController(t, ResourceProviderOptions{})
+	address := controller.web3SDK.GetAddress().String()
+
+	deals := []data.DealContainer{
+		{ID: "agreed", ResourceProvider: address, State: data.GetAgreementStateIndex("DealAgreed")},
+		{ID: "agreeing", ResourceProvider: address, State: data.GetAgreementStateIndex("DealNegotiating")},
+		{ID: "negotiating", ResourceProvider: address, State: data.GetAgreementStateIndex("DealNegotiating")},
+		{ID: "someone-else", ResourceProvider: "0xother", State: data.GetAgreementStateIndex("DealAgreed")},
+	}
+	deals[1].Transactions.ResourceProvider.Agree = "0xtx"
+	for _, deal := range deals {
+		assert.NoError(t, client.AddDeal(deal))
+	}
+
+	running, err := controller.countRunningDeals(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, running, "Agreed deals and deals with an agree tx sent should count as running")
+}
+
+func TestDrain(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}}
+	controller, conn, client := newTestController(t, options)
+	address := controller.web3SDK.GetAddress().String()
+
+	_, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.NoError(t, client.AddDeal(data.DealContainer{
+		ID:               "agreed",
+		ResourceProvider: address,
+		State:            data.GetAgreementStateIndex("DealAgreed"),
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = controller.Drain(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "We should wait for the agreed deal until we run out of time")
+	assert.False(t, controller.beginWork(), "No new deals should be agreed to while draining")
+	offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{ResourceProvider: address})
+	assert.NoError(t, err)
+	assert.Empty(t, offers, "Our offers should have been taken down")
+
+	assert.NoError(t, client.AddDeal(data.DealContainer{
+		ID:               "agreed",
+		ResourceProvider: address,
+		State:            data.GetAgreementStateIndex("ResultsAccepted"),
+	}))
+	assert.NoError(t, controller.Drain(context.Background()), "Draining should finish once our deals have")
+}
+
+func TestOnDealAgreed(t *testing.T) {
+	agreedDeals := []string{}
+	options := ResourceProviderOptions{
+		OnDealAgreed: func(deal data.Deal) {
+			agreedDeals = append(agreedDeals, deal.ID)
+		},
+		OnDealFailed: func(deal data.Deal, err error) {
+			t.Errorf("deal %s should not have failed: %s", deal.ID, err)
+		},
+	}
+	controller, conn, client := newTestController(t, options)
+	dealContainer := data.DealContainer{
+		ID:   "deal1",
+		Deal: data.Deal{ID: "deal1"},
+	}
+	assert.NoError(t, client.AddDeal(dealContainer))
+	// an agree tx we already know was mined so nothing is sent to the chain
+	assert.NoError(t, controller.state.setDeal("deal1", persistedDeal{Solver: conn.address, AgreeTx: "0xtx", Agreed: true}))
+
+	ok, err := controller.agreeToDeal(context.Background(), conn, dealContainer)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"deal1"}, agreedDeals, "The hook should be called once the agree tx is mined")
+}
+
+func TestCheckSolveError(t *testing.T) {
+	controller := &ResourceProviderController{}
+	solveErr := fmt.Errorf("solver down")
+
+	assert.NoError(t, controller.checkSolveError(solveErr), "Failed solves should be retried when there is no limit")
+	assert.NoError(t, controller.checkSolveError(solveErr))
+
+	controller.options.MaxConsecutiveErrors = 2
+	controller.consecutiveSolveErrors = 0
+	assert.NoError(t, controller.checkSolveError(solveErr))
+	assert.NoError(t, controller.checkSolveError(nil), "A good solve should reset the count")
+	assert.NoError(t, controller.checkSolveError(solveErr))
+	err := controller.checkSolveError(solveErr)
+	assert.ErrorIs(t, err, solveErr, "We should give up once the limit is reached")
+}
+
+func TestObserverMode(t *testing.T) {
+	options := ResourceProviderOptions{ObserverMode: true}
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}}
+	events := []solver.SolverEvent{}
+	options.OnSolverEvent = func(solverAddress string, ev solver.SolverEvent) {
+		events = append(events, ev)
+	}
+	controller, conn, client := newTestController(t, options)
+	assert.NoError(t, controller.subscribeToSolver(conn))
+
+	deal := data.DealContainer{ID: "deal1", ResourceProvider: "0xsomeone-else"}
+	client.Emit(solver.SolverEvent{EventType: solver.DealAdded, Deal: &deal})
+	assert.Len(t, events, 1, "Every solver event should be passed on, not just ours")
+
+	assert.NoError(t, controller.solveForSolver(context.Background(), conn, &solveResult{}))
+	offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, offers, "An observer should never post offers")
+}
+
+func TestSolverSubscriptionHealth(t *testing.T) {
+	options := ResourceProviderOptions{ObserverMode: true}
+	controller, conn, client := newTestController(t, options)
+	assert.NoError(t, controller.subscribeToSolver(conn))
+	assert.Error(t, controller.checkReady())
+
+	assert.NoError(t, client.Start(context.Background(), nil))
+	assert.True(t, controller.Status().SolversSubscribed[conn.address])
+	assert.Equal(t, 1.0, testutil.ToFloat64(solverSubscriptionConnected.WithLabelValues(conn.address)))
+
+	client.SetConnected(false)
+	assert.False(t, controller.Status().SolversSubscribed[conn.address], "A dropped websocket should be reported")
+
+	received := solverEventsReceivedTotal.WithLabelValues(conn.address, "DealAdded")
+	before := testutil.ToFloat64(received)
+	deal := data.DealContainer{ID: "deal1"}
+	client.Emit(solver.SolverEvent{EventType: solver.DealAdded, Deal: &deal})
+	assert.Equal(t, before+1, testutil.ToFloat64(received))
+}
+
+func TestIsDealFresh(t *testing.T) {
+	controller, _, _ := newTestController(t, ResourceProviderOptions{MaxDealAge: time.Hour})
+	clock := system.NewFakeClock(time.Now())
+	controller.clock = clock
+	newDeal := func(id string, age time.Duration) data.DealContainer {
+		deal := data.DealContainer{ID: id}
+		deal.Deal.JobOffer.CreatedAt = int(clock.Now().Add(-age).UnixMilli())
+		return deal
+	}
+
+	ok, _ := controller.isDealFresh(newDeal("new", time.Minute))
+	assert.True(t, ok)
+	ok, reason := controller.isDealFresh(newDeal("old", 2*time.Hour))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "2h0m0s old")
+
+	controller.seenDeals.checkAndAdd("event", clock.Now())
+	ok, _ = controller.isDealFresh(newDeal("event", 2*time.Hour))
+	assert.True(t, ok, "A deal we just had an event for should not be skipped however old it is")
+
+	controller.options.MaxDealAge = 0
+	ok, _ = controller.isDealFresh(newDeal("old", 2*time.Hour))
+	assert.True(t, ok, "0 should mean no limit")
+}
+
+func TestIsDealPriceAcceptable(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.DefaultPricing = data.DealPricing{InstructionPrice: 10}
+	options.Offers.Modules = []string{"cowsay:v0.0.1", "sdxl:v0.0.1"}
+	options.Offers.ModulePricing = map[string]data.DealPricing{"sdxl:v0.0.1": {InstructionPrice: 50}}
+	controller, _, _ := newTestController(t, options)
+
+	getDeal := func(module string, price uint64) data.DealContainer {
+		deal := data.DealContainer{ID: "deal1"}
+		deal.Deal.JobOffer.Module.Name = module
+		deal.Deal.Pricing.InstructionPrice = price
+		return deal
+	}
+	ok, _ := controller.isDealPriceAcceptable(getDeal("cowsay:v0.0.1", 10))
+	assert.True(t, ok, "A deal at our default price should be accepted")
+	ok, reason := controller.isDealPriceAcceptable(getDeal("cowsay:v0.0.1", 9))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "instruction price 9 is below our price of 10")
+	ok, _ = controller.isDealPriceAcceptable(getDeal("sdxl:v0.0.1", 10))
+	assert.False(t, ok, "A deal should pay the price for its module")
+	ok, _ = controller.isDealPriceAcceptable(getDeal("sdxl:v0.0.1", 60))
+	assert.True(t, ok)
+
+	controller.pricingStrategy = NewStaticPricingStrategy(ResourceProviderOfferOptions{
+		DefaultPricing: data.DealPricing{InstructionPrice: 10, PaymentCollateral: 100, ResultsCollateralMultiple: 2, MediationFee: 5},
+	})
+	getTerms := func(pricing data.DealPricing) data.DealContainer {
+		deal := getDeal("cowsay:v0.0.1", 10)
+		deal.Deal.Pricing = pricing
+		return deal
+	}
+	ok, _ = controller.isDealPriceAcceptable(getTerms(data.DealPricing{InstructionPrice: 10, PaymentCollateral: 200, ResultsCollateralMultiple: 1, MediationFee: 5}))
+	assert.True(t, ok, "Terms that are better for us should be accepted")
+	ok, reason = controller.isDealPriceAcceptable(getTerms(data.DealPricing{InstructionPrice: 10, PaymentCollateral: 50, ResultsCollateralMultiple: 2, MediationFee: 5}))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "payment collateral 50 is below our 100")
+	ok, reason = controller.isDealPriceAcceptable(getTerms(data.DealPricing{InstructionPrice: 10, PaymentCollateral: 100, ResultsCollateralMultiple: 4, MediationFee: 5}))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "results collateral multiple 4 is above our 2")
+	ok, reason = controller.isDealPriceAcceptable(getTerms(data.DealPricing{InstructionPrice: 10, PaymentCollateral: 100, ResultsCollateralMultiple: 2, MediationFee: 1}))
+	assert.False(t, ok)
+	assert.Contains(t, reason, "mediation fee 1 is not our 5")
+}
+
+func TestSendAgreeTxSkipsDealsThatWouldRevert(t *testing.T) {
+	sdk, err := web3.NewSimulatedContractSDK(web3.Web3Options{Mode: web3.Web3ModeSimulated})
+	if !assert.NoError(t, err) {
+		return
+	}
+	controller, conn, _ := newTestController(t, ResourceProviderOptions{SimulateBeforeSend: true})
+	controller.web3SDK = sdk
+
+	otherAddress := func() string {
+		key, err := crypto.GenerateKey()
+		assert.NoError(t, err)
+		return web3.GetAddress(key).String()
+	}
+	deal := data.Deal{
+		ID: "deal1",
+		Members: data.DealMembers{
+			Solver:           otherAddress(),
+			JobCreator:       otherAddress(),
+			ResourceProvider: sdk.GetAddress().String(),
+			Mediators:        []string{otherAddress()},
+		},
+	}
+	// agreed to behind our back e.g. by a previous run that lost its state
+	_, err = sdk.AgreeWithContext(context.Background(), deal)
+	assert.NoError(t, err)
+
+	_, err = controller.sendAgreeTx(context.Background(), conn, data.DealContainer{ID: deal.ID, Deal: deal})
+	revertErr := &web3.RevertError{}
+	assert.ErrorAs(t, err, &revertErr)
+	_, ok := controller.state.getDeal(deal.ID)
+	assert.False(t, ok, "No agree tx should have been sent")
+}
@@ -0,0 +1,78 @@
+package resourceprovider
+
+import (
+	"sync"
+	"time"
+)
+
+// the most deal ids we remember, whatever the window is
+const SEEN_DEALS_MAX_SIZE = 10000
+
+// how long we remember that a deal on the chain belongs to someone else
+// a deal only changes state a handful of times so this covers most of its life
+const FOREIGN_DEALS_WINDOW = time.Hour
+
+// remembers which deal ids we have recently heard about
+// entries expire after the window and the oldest are dropped
+// once we reach maxSize so this can't grow forever
+type seenDeals struct {
+	mutex   sync.Mutex
+	window  time.Duration
+	maxSize int
+	seenAt  map[string]time.Time
+	// deal ids in the order we first saw them so we can expire the oldest
+	order []string
+}
+
+func newSeenDeals(window time.Duration, maxSize int) *seenDeals {
+	return &seenDeals{
+		window:  window,
+		maxSize: maxSize,
+		seenAt:  map[string]time.Time{},
+		order:   []string{},
+	}
+}
+
+// returns true if we have already seen this deal within the window
+// otherwise it records the deal and returns false
+func (seen *seenDeals) checkAndAdd(dealID string, now time.Time) bool {
+	seen.mutex.Lock()
+	defer seen.mutex.Unlock()
+
+	seen.expire(now)
+
+	if _, ok := seen.seenAt[dealID]; ok {
+		return true
+	}
+	seen.seenAt[dealID] = now
+	seen.order = append(seen.order, dealID)
+
+	for len(seen.order) > seen.maxSize {
+		delete(seen.seenAt, seen.order[0])
+		seen.order = seen.order[1:]
+	}
+	return false
+}
+
+// returns true if we have seen this deal within the window without recording it
+func (seen *seenDeals) contains(dealID string, now time.Time) bool {
+	seen.mutex.Lock()
+	defer seen.mutex.Unlock()
+
+	seen.expire(now)
+
+	_, ok := seen.seenAt[dealID]
+	return ok
+}
+
+// entries are appended in time order so we only need to look at the front
+func (seen *seenDeals) expire(now time.Time) {
+	for len(seen.order) > 0 {
+		oldest := seen.order[0]
+		if now.Sub(seen.seenAt[oldest]) < seen.window {
+			return
+		}
+		delete(seen.seenAt, oldest)
+		seen.order = seen.order[1:]
+	}
+}
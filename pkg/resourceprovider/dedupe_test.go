@@ -0,0 +1,33 @@
+package resourceprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeenDeals(t *testing.T) {
+	now := time.Now()
+	seen := newSeenDeals(time.Minute, 2)
+
+	assert.False(t, seen.checkAndAdd("a", now), "First sighting should not be a duplicate")
+	assert.True(t, seen.checkAndAdd("a", now.Add(time.Second)), "Second sighting within the window should be a duplicate")
+	assert.False(t, seen.checkAndAdd("a", now.Add(2*time.Minute)), "Sighting after the window should not be a duplicate")
+
+	assert.False(t, seen.checkAndAdd("b", now.Add(2*time.Minute)))
+	assert.False(t, seen.checkAndAdd("c", now.Add(2*time.Minute)))
+	assert.Len(t, seen.seenAt, 2, "Cache should never grow beyond its max size")
+	assert.False(t, seen.checkAndAdd("a", now.Add(2*time.Minute)), "Oldest entry should have been dropped")
+}
+
+func TestSeenDealsContains(t *testing.T) {
+	now := time.Now()
+	seen := newSeenDeals(time.Minute, 10)
+
+	assert.False(t, seen.contains("a", now))
+	assert.False(t, seen.contains("a", now), "Checking should not record the deal")
+	seen.checkAndAdd("a", now)
+	assert.True(t, seen.contains("a", now.Add(time.Second)))
+	assert.False(t, seen.contains("a", now.Add(2*time.Minute)), "Entries should expire after the window")
+}
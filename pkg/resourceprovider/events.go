@@ -0,0 +1,41 @@
+package resourceprovider
+
+import (
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
+)
+
+// how many state changes we buffer for consumers before we start dropping them
+const DEAL_STATE_CHANGES_BUFFER = 100
+
+// a deal of ours (or any deal in ObserverMode) changed state on-chain
+type DealStateChange struct {
+	DealID string
+	// the decoded AgreementState e.g. "DealAgreed"
+	State string
+	// the event as it came from the storage contract
+	Event storage.StorageDealStateChange
+}
+
+// DealStateChanges is a feed of on-chain state changes for our deals (or every deal in ObserverMode)
+// the channel is buffered and changes are dropped if nobody is reading it
+func (controller *ResourceProviderController) DealStateChanges() <-chan DealStateChange {
+	return controller.dealStateChanges
+}
+
+func (controller *ResourceProviderController) publishDealStateChange(ev storage.StorageDealStateChange) {
+	state := "Unknown"
+	if int(ev.State) < len(data.AgreementState) {
+		state = data.GetAgreementStateString(ev.State)
+	}
+	change := DealStateChange{
+		DealID: ev.DealId,
+		State:  state,
+		Event:  ev,
+	}
+	select {
+	case controller.dealStateChanges <- change:
+	default:
+		controller.log.Debug("deal state change channel full, dropping", change.DealID)
+	}
+}
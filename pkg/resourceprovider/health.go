@@ -0,0 +1,210 @@
+package resourceprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	corehttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+)
+
+// what the health server reports on
+type healthState struct {
+	mutex sync.Mutex
+	// the solve loop has been started
+	loopRunning bool
+	// when the most recent solve finished and the error (if any) it had
+	lastSolveAt    time.Time
+	lastSolveError error
+	// a solve has gone through since we started so we know the solvers
+	// and the chain are reachable - it stays set if later solves fail
+	firstSolveSucceeded bool
+	// how many offers each solver had for us after the last solve
+	activeOffers map[string]int
+	// how many deals we have agreed to since we started
+	agreedDeals int
+	// whether the event websocket to each solver is connected and whether
+	// we are subscribed to web3 events - either can drop at any time
+	solversSubscribed map[string]bool
+	web3Subscribed    bool
+}
+
+func (controller *ResourceProviderController) setLoopRunning(running bool) {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	controller.health.loopRunning = running
+}
+
+func (controller *ResourceProviderController) setLastSolveError(err error) {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	controller.health.lastSolveAt = controller.clock.Now()
+	controller.health.lastSolveError = err
+	if err == nil {
+		controller.health.firstSolveSucceeded = true
+	}
+}
+
+func (controller *ResourceProviderController) setActiveOffers(solverAddress string, count int) {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	if controller.health.activeOffers == nil {
+		controller.health.activeOffers = map[string]int{}
+	}
+	controller.health.activeOffers[solverAddress] = count
+}
+
+func (controller *ResourceProviderController) addAgreedDeal() {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	controller.health.agreedDeals++
+}
+
+// what the controller is up to, for debugging without digging through the logs
+type ControllerStatus struct {
+	LoopRunning bool `json:"loop_running"`
+	// zero if we haven't finished a solve yet
+	LastSolveAt time.Time `json:"last_solve_at"`
+	// empty if the last solve went through
+	LastSolveError      string `json:"last_solve_error,omitempty"`
+	FirstSolveSucceeded bool   `json:"first_solve_succeeded"`
+	// across all solvers as of the last solve
+	ActiveOffers int `json:"active_offers"`
+	// since we started
+	AgreedDeals int  `json:"agreed_deals"`
+	Draining    bool `json:"draining"`
+	// solver address onto whether its event websocket is connected
+	SolversSubscribed map[string]bool `json:"solvers_subscribed"`
+	Web3Subscribed    bool            `json:"web3_subscribed"`
+}
+
+func (controller *ResourceProviderController) Status() ControllerStatus {
+	draining := controller.isDraining()
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	status := ControllerStatus{
+		LoopRunning: controller.health.loopRunning,
+		LastSolveAt: controller.health.lastSolveAt,
+		AgreedDeals: controller.health.agreedDeals,
+		Draining:    draining,
+
+		FirstSolveSucceeded: controller.health.firstSolveSucceeded,
+		SolversSubscribed:   map[string]bool{},
+		Web3Subscribed:      controller.health.web3Subscribed,
+	}
+	for _, conn := range controller.solvers {
+		status.SolversSubscribed[conn.address] = controller.health.solversSubscribed[conn.address]
+	}
+	if controller.health.lastSolveError != nil {
+		status.LastSolveError = controller.health.lastSolveError.Error()
+	}
+	for _, count := range controller.health.activeOffers {
+		status.ActiveOffers += count
+	}
+	return status
+}
+
+func (controller *ResourceProviderController) setSolverSubscribed(solverAddress string, connected bool) {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	if controller.health.solversSubscribed == nil {
+		controller.health.solversSubscribed = map[string]bool{}
+	}
+	controller.health.solversSubscribed[solverAddress] = connected
+	solverSubscriptionConnected.WithLabelValues(solverAddress).Set(boolToGauge(connected))
+}
+
+func (controller *ResourceProviderController) setWeb3Subscribed(connected bool) {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	controller.health.web3Subscribed = connected
+	web3SubscriptionConnected.Set(boolToGauge(connected))
+}
+
+// healthy means the solve loop is running and the last solve went through
+func (controller *ResourceProviderController) checkHealthy() error {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	if !controller.health.loopRunning {
+		return fmt.Errorf("solve loop is not running")
+	}
+	if controller.health.lastSolveError != nil {
+		return fmt.Errorf("last solve failed: %s", controller.health.lastSolveError.Error())
+	}
+	return nil
+}
+
+// ready means we can hear about deals from every solver and the chain right now
+// and have been able to solve with them at least once
+// a subscription that has dropped makes us unready until it is back
+func (controller *ResourceProviderController) checkReady() error {
+	controller.health.mutex.Lock()
+	defer controller.health.mutex.Unlock()
+	if !controller.health.firstSolveSucceeded {
+		return fmt.Errorf("no solve has succeeded yet")
+	}
+	for _, conn := range controller.solvers {
+		if !controller.health.solversSubscribed[conn.address] {
+			return fmt.Errorf("not subscribed to events from solver %s", conn.address)
+		}
+	}
+	if !controller.health.web3Subscribed {
+		return fmt.Errorf("not subscribed to web3 events")
+	}
+	return nil
+}
+
+func healthHandler(check func() error) corehttp.HandlerFunc {
+	return func(res corehttp.ResponseWriter, req *corehttp.Request) {
+		err := check()
+		if err != nil {
+			corehttp.Error(res, err.Error(), corehttp.StatusServiceUnavailable)
+			return
+		}
+		res.WriteHeader(corehttp.StatusOK)
+		_, _ = res.Write([]byte("ok"))
+	}
+}
+
+func statusHandler(getStatus func() ControllerStatus) corehttp.HandlerFunc {
+	return func(res corehttp.ResponseWriter, req *corehttp.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(res).Encode(getStatus())
+		if err != nil {
+			corehttp.Error(res, err.Error(), corehttp.StatusInternalServerError)
+		}
+	}
+}
+
+// serve /healthz, /readyz and /status for liveness and readiness probes until the context is cancelled
+func (controller *ResourceProviderController) startHealthServer(ctx context.Context, cm *system.CleanupManager) {
+	mux := corehttp.NewServeMux()
+	mux.HandleFunc("/healthz", healthHandler(controller.checkHealthy))
+	mux.HandleFunc("/readyz", healthHandler(controller.checkReady))
+	mux.HandleFunc("/status", statusHandler(controller.Status))
+
+	srv := &corehttp.Server{
+		Addr:              fmt.Sprintf(":%d", controller.options.HealthPort),
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler:           mux,
+	}
+
+	go func() {
+		controller.log.Info("health server listening", srv.Addr)
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, corehttp.ErrServerClosed) {
+			controller.log.Error("health server error", err)
+		}
+	}()
+
+	cm.RegisterCallbackWithContext(func(ctx context.Context) error {
+		controller.setLoopRunning(false)
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	})
+}
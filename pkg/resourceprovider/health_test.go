@@ -0,0 +1,77 @@
+package resourceprovider
+
+import (
+	"fmt"
+	corehttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func getHealthStatus(check func() error) int {
+	recorder := httptest.NewRecorder()
+	healthHandler(check)(recorder, httptest.NewRequest("GET", "/", nil))
+	return recorder.Code
+}
+
+func TestHealthz(t *testing.T) {
+	controller := &ResourceProviderController{clock: system.RealClock}
+	assert.Equal(t, corehttp.StatusServiceUnavailable, getHealthStatus(controller.checkHealthy), "Should not be healthy before the loop starts")
+
+	controller.setLoopRunning(true)
+	assert.Equal(t, corehttp.StatusOK, getHealthStatus(controller.checkHealthy))
+
+	controller.setLastSolveError(fmt.Errorf("solver unavailable"))
+	assert.Equal(t, corehttp.StatusServiceUnavailable, getHealthStatus(controller.checkHealthy), "Should not be healthy after a failed solve")
+}
+
+func TestReadyz(t *testing.T) {
+	controller := &ResourceProviderController{
+		clock:   system.RealClock,
+		solvers: []*solverConnection{{address: "solver1"}, {address: "solver2"}},
+	}
+	controller.setSolverSubscribed("solver1", true)
+	controller.setSolverSubscribed("solver2", true)
+	controller.setWeb3Subscribed(true)
+	controller.setLastSolveError(fmt.Errorf("solver unavailable"))
+	assert.Equal(t, corehttp.StatusServiceUnavailable, getHealthStatus(controller.checkReady), "Should not be ready until a solve succeeds")
+
+	controller.setLastSolveError(nil)
+	assert.Equal(t, corehttp.StatusOK, getHealthStatus(controller.checkReady))
+	assert.True(t, controller.Status().FirstSolveSucceeded)
+
+	controller.setLastSolveError(fmt.Errorf("solver unavailable"))
+	assert.Equal(t, corehttp.StatusOK, getHealthStatus(controller.checkReady), "A later failed solve is for /healthz to report")
+
+	controller.setSolverSubscribed("solver2", false)
+	assert.Equal(t, corehttp.StatusServiceUnavailable, getHealthStatus(controller.checkReady), "A dropped solver websocket should fail readiness")
+	assert.Equal(t, 0.0, testutil.ToFloat64(solverSubscriptionConnected.WithLabelValues("solver2")))
+	assert.Equal(t, map[string]bool{"solver1": true, "solver2": false}, controller.Status().SolversSubscribed)
+
+	controller.setSolverSubscribed("solver2", true)
+	assert.Equal(t, corehttp.StatusOK, getHealthStatus(controller.checkReady), "Should be ready again once it reconnects")
+
+	controller.setWeb3Subscribed(false)
+	assert.Equal(t, corehttp.StatusServiceUnavailable, getHealthStatus(controller.checkReady), "A dropped web3 subscription should fail readiness")
+	assert.Equal(t, 0.0, testutil.ToFloat64(web3SubscriptionConnected))
+}
+
+func TestStatus(t *testing.T) {
+	controller := &ResourceProviderController{clock: system.RealClock}
+	status := controller.Status()
+	assert.True(t, status.LastSolveAt.IsZero(), "Should not have a solve time before the first solve")
+
+	controller.setActiveOffers("solver1", 2)
+	controller.setActiveOffers("solver2", 1)
+	controller.addAgreedDeal()
+	controller.setLastSolveError(fmt.Errorf("solver unavailable"))
+
+	status = controller.Status()
+	assert.False(t, status.LastSolveAt.IsZero())
+	assert.Equal(t, "solver unavailable", status.LastSolveError)
+	assert.Equal(t, 3, status.ActiveOffers)
+	assert.Equal(t, 1, status.AgreedDeals)
+}
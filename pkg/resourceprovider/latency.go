@@ -0,0 +1,55 @@
+package resourceprovider
+
+import (
+	"sync"
+	"time"
+)
+
+// how long we remember when we first heard about a deal we haven't agreed to
+// a deal we never agree to is forgotten after this
+const DEAL_TIMER_WINDOW = time.Hour
+
+// when we first heard about each deal so we can measure how long
+// it takes us to get an agree tx mined for it
+type dealTimers struct {
+	mutex     sync.Mutex
+	maxSize   int
+	startedAt map[string]time.Time
+}
+
+func newDealTimers(maxSize int) *dealTimers {
+	return &dealTimers{
+		maxSize:   maxSize,
+		startedAt: map[string]time.Time{},
+	}
+}
+
+// start timing the deal unless we already are
+func (timers *dealTimers) start(dealID string, now time.Time) {
+	timers.mutex.Lock()
+	defer timers.mutex.Unlock()
+	if _, ok := timers.startedAt[dealID]; ok {
+		return
+	}
+	for id, startedAt := range timers.startedAt {
+		if now.Sub(startedAt) > DEAL_TIMER_WINDOW {
+			delete(timers.startedAt, id)
+		}
+	}
+	if len(timers.startedAt) >= timers.maxSize {
+		return
+	}
+	timers.startedAt[dealID] = now
+}
+
+// how long since we started timing the deal, false if we weren't
+func (timers *dealTimers) stop(dealID string, now time.Time) (time.Duration, bool) {
+	timers.mutex.Lock()
+	defer timers.mutex.Unlock()
+	startedAt, ok := timers.startedAt[dealID]
+	if !ok {
+		return 0, false
+	}
+	delete(timers.startedAt, dealID)
+	return now.Sub(startedAt), true
+}
@@ -0,0 +1,33 @@
+package resourceprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDealTimers(t *testing.T) {
+	timers := newDealTimers(2)
+	now := time.Now()
+
+	timers.start("deal1", now)
+	timers.start("deal1", now.Add(time.Second))
+	latency, ok := timers.stop("deal1", now.Add(5*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, latency, "The timer starts when we first hear about the deal")
+	_, ok = timers.stop("deal1", now)
+	assert.False(t, ok, "A deal is only timed once")
+
+	timers.start("deal2", now)
+	timers.start("deal3", now)
+	timers.start("deal4", now)
+	_, ok = timers.stop("deal4", now)
+	assert.False(t, ok, "We stop timing new deals once we are full")
+
+	timers.start("deal5", now.Add(2*DEAL_TIMER_WINDOW))
+	_, ok = timers.stop("deal2", now)
+	assert.False(t, ok, "Old deals are forgotten")
+	_, ok = timers.stop("deal5", now)
+	assert.True(t, ok)
+}
@@ -0,0 +1,124 @@
+package resourceprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	corehttp "net/http"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const METRICS_NAMESPACE = "lilypad_resource_provider"
+
+var (
+	dealsAgreedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "deals_agreed_total",
+		Help:      "The number of deals we have successfully agreed to on-chain.",
+	})
+	dealsFailedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "deals_agree_failed_total",
+		Help:      "The number of agree txs that failed.",
+	})
+	activeResourceOffersGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "active_resource_offers",
+		Help:      "The number of resource offers we have active on each solver.",
+	}, []string{"solver"})
+	dealsRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "deals_rejected_total",
+		Help:      "The number of deals we did not agree to, by reason (see DealRejectReason).",
+	}, []string{"reason"})
+	solverEventErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "solver_event_errors_total",
+		Help:      "The number of malformed events we have received from each solver.",
+	}, []string{"solver", "reason"})
+	offerResyncsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "offer_resyncs_total",
+		Help:      "The number of times a solver lost all of our resource offers and we posted them again.",
+	}, []string{"solver"})
+	solveErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "solve_errors_total",
+		Help:      "The number of solves that failed or timed out.",
+	})
+	solverBackpressureGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "solver_backpressure",
+		Help:      "1 while the solve loop is backing off because the solvers are overloaded.",
+	})
+	dealAgreeLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "deal_agree_latency_seconds",
+		Help:      "How long from first hearing about a deal until our agree tx for it is mined.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10), //nolint:gomnd
+	})
+	solveDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "solve_duration_seconds",
+		Help:      "How long each solve iteration takes.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	solverSubscriptionConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "solver_subscription_connected",
+		Help:      "1 while the event websocket to each solver is connected.",
+	}, []string{"solver"})
+	web3SubscriptionConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "web3_subscription_connected",
+		Help:      "1 while we are subscribed to deal state changes on-chain.",
+	})
+	solverEventsReceivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "solver_events_received_total",
+		Help:      "The number of events we have received from each solver, by event type.",
+	}, []string{"solver", "event_type"})
+	web3EventsReceivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "web3_events_received_total",
+		Help:      "The number of contract events we have received, by event type.",
+	}, []string{"event_type"})
+)
+
+func boolToGauge(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// serve the prometheus metrics on /metrics until the context is cancelled
+func (controller *ResourceProviderController) startMetricsServer(ctx context.Context, cm *system.CleanupManager) {
+	mux := corehttp.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	srv := &corehttp.Server{
+		Addr:              fmt.Sprintf(":%d", controller.options.MetricsPort),
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler:           mux,
+	}
+
+	go func() {
+		controller.log.Info("metrics server listening", srv.Addr)
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, corehttp.ErrServerClosed) {
+			controller.log.Error("metrics server error", err)
+		}
+	}()
+
+	cm.RegisterCallbackWithContext(func(ctx context.Context) error {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	})
+}
@@ -0,0 +1,199 @@
+package resourceprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/solver"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+)
+
+// true if the solver has none of the offers we have posted to it
+// e.g. because it restarted and only kept them in memory
+// our matched offers aren't active so we only ask for all of them when no active ones came back
+func (controller *ResourceProviderController) haveOffersBeenLost(client solver.SolverClientInterface, conn *solverConnection, activeOffers int) (bool, error) {
+	if activeOffers > 0 || controller.state.countOffers(conn.address) == 0 {
+		return false, nil
+	}
+	offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{
+		ResourceProvider: controller.web3SDK.GetAddress().String(),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(offers) == 0, nil
+}
+
+// RemoveOffer takes the resource offers for the spec at index down from every solver
+// and stops it being posted again until RestoreOffer is called
+// offers that have already been matched to a deal are left for the deal to play out
+func (controller *ResourceProviderController) RemoveOffer(index int) error {
+	controller.withdrawnOffersMutex.Lock()
+	controller.withdrawnOffers[index] = true
+	controller.withdrawnOffersMutex.Unlock()
+
+	// a spec split into slots has an offer for each of them
+	offerKeys := []offerKey{getOfferKey("", index)}
+	if index >= 0 && index < len(controller.options.Offers.Specs) {
+		spec := controller.options.Offers.Specs[index]
+		offerKeys = []offerKey{}
+		for _, offerIndex := range getSpecOfferIndexes(spec, index) {
+			offerKeys = append(offerKeys, getOfferKey(spec.ID, offerIndex))
+		}
+	}
+
+	errs := []error{}
+	for _, conn := range controller.solvers {
+		for _, key := range offerKeys {
+			err := controller.removeOfferFromSolver(conn, key)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("solver %s: %w", conn.address, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RestoreOffer lets a spec removed with RemoveOffer be posted again on the next solve
+func (controller *ResourceProviderController) RestoreOffer(index int) {
+	controller.withdrawnOffersMutex.Lock()
+	delete(controller.withdrawnOffers, index)
+	controller.withdrawnOffersMutex.Unlock()
+	if controller.loop != nil {
+		controller.loop.Trigger()
+	}
+}
+
+func (controller *ResourceProviderController) isOfferWithdrawn(index int) bool {
+	controller.withdrawnOffersMutex.Lock()
+	defer controller.withdrawnOffersMutex.Unlock()
+	return controller.withdrawnOffers[index]
+}
+
+func (controller *ResourceProviderController) removeOfferFromSolver(conn *solverConnection, key offerKey) error {
+	// if we posted the offer we know its id and can take it down without asking the solver
+	postedOffer, ok := controller.state.getOffer(conn.address, key)
+	if ok && !controller.options.DryRun {
+		controller.log.Info("remove resource offer", postedOffer.ID)
+		_, err := conn.client.RemoveResourceOffer(postedOffer.ID)
+		// the solver refuses to remove a matched offer so we fall back to looking
+		// for unmatched ones below - anything else means the offer is gone or we can't reach it
+		if err == nil || errors.Is(err, solver.ErrNotFound) {
+			if err == nil {
+				activeResourceOffersGauge.WithLabelValues(conn.address).Dec()
+			}
+			err = controller.state.removeOffer(conn.address, key)
+			if err != nil {
+				controller.log.Error("error saving offer state", err)
+			}
+			return nil
+		}
+		if !errors.Is(err, solver.ErrBadRequest) {
+			return err
+		}
+	}
+
+	activeResourceOffers, err := conn.client.GetResourceOffers(store.GetResourceOffersQuery{
+		ResourceProvider: controller.web3SDK.GetAddress().String(),
+		Active:           true,
+		NotMatched:       true,
+	})
+	if err != nil {
+		return err
+	}
+	for _, resourceOffer := range activeResourceOffers {
+		if controller.getResourceOfferKey(resourceOffer.ResourceOffer) != key {
+			continue
+		}
+		if controller.options.DryRun {
+			controller.log.Info("dry run: would remove resource offer", resourceOffer.ID)
+			continue
+		}
+		controller.log.Info("remove resource offer", resourceOffer.ID)
+		_, err := conn.client.RemoveResourceOffer(resourceOffer.ID)
+		if err != nil {
+			return err
+		}
+		err = controller.state.removeOffer(conn.address, key)
+		if err != nil {
+			controller.log.Error("error saving offer state", err)
+		}
+		activeResourceOffersGauge.WithLabelValues(conn.address).Dec()
+	}
+	return nil
+}
+
+// take the offers for all of our specs down and stop them being posted again
+// so the solver stops matching deals to us
+// this is registered with the cleanup manager when RemoveOffersOnShutdown is set
+func (controller *ResourceProviderController) withdrawAllOffers(ctx context.Context) error {
+	errs := []error{}
+	for index := range controller.options.Offers.Specs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := controller.RemoveOffer(index)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resource offer %d: %w", index, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListActiveOffers asks every solver for our active resource offers
+// this includes ones we have lost track of e.g. after a crash or a config change
+func (controller *ResourceProviderController) ListActiveOffers(ctx context.Context) ([]data.ResourceOfferContainer, error) {
+	offers := []data.ResourceOfferContainer{}
+	errs := []error{}
+	for _, conn := range controller.solvers {
+		solverOffers, err := conn.client.WithContext(ctx).GetResourceOffers(store.GetResourceOffersQuery{
+			ResourceProvider: controller.web3SDK.GetAddress().String(),
+			Active:           true,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("solver %s: %w", conn.address, err))
+			continue
+		}
+		offers = append(offers, solverOffers...)
+	}
+	return offers, errors.Join(errs...)
+}
+
+// RemoveAllOffers takes every unmatched resource offer of ours down from every solver
+// whether or not it is for one of our specs, for cleaning up after a crash
+// offers that have been matched to a deal are left for the deal to play out
+// unlike Drain this doesn't stop the offers for our specs being posted again by a running solve loop
+func (controller *ResourceProviderController) RemoveAllOffers(ctx context.Context) error {
+	errs := []error{}
+	for _, conn := range controller.solvers {
+		client := conn.client.WithContext(ctx)
+		solverOffers, err := client.GetResourceOffers(store.GetResourceOffersQuery{
+			ResourceProvider: controller.web3SDK.GetAddress().String(),
+			Active:           true,
+			NotMatched:       true,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("solver %s: %w", conn.address, err))
+			continue
+		}
+		for _, resourceOffer := range solverOffers {
+			if controller.options.DryRun {
+				controller.log.Info("dry run: would remove resource offer", resourceOffer.ID)
+				continue
+			}
+			controller.log.Info("remove resource offer", resourceOffer.ID)
+			_, err := client.RemoveResourceOffer(resourceOffer.ID)
+			if err != nil && !errors.Is(err, solver.ErrNotFound) {
+				errs = append(errs, fmt.Errorf("solver %s: resource offer %s: %w", conn.address, resourceOffer.ID, err))
+				continue
+			}
+			err = controller.state.removeOffer(conn.address, controller.getResourceOfferKey(resourceOffer.ResourceOffer))
+			if err != nil {
+				controller.log.Error("error saving offer state", err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
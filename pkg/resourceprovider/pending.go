@@ -0,0 +1,60 @@
+package resourceprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// the agree txs we have sent and not yet seen mined, by deal id
+// the solver keeps showing a deal as negotiating until our agree tx is mined
+// so without this a later solve would try to agree to it again
+type pendingAgrees struct {
+	mutex sync.Mutex
+	txs   map[string]string
+}
+
+func newPendingAgrees() *pendingAgrees {
+	return &pendingAgrees{
+		txs: map[string]string{},
+	}
+}
+
+func (pending *pendingAgrees) add(dealID string, txHash string) {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	pending.txs[dealID] = txHash
+}
+
+func (pending *pendingAgrees) remove(dealID string) {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	delete(pending.txs, dealID)
+}
+
+func (pending *pendingAgrees) get(dealID string) (string, bool) {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	txHash, ok := pending.txs[dealID]
+	return txHash, ok
+}
+
+// true if we have an agree tx for the deal that hasn't been mined or dropped yet
+// we only get here when we stopped waiting for the tx part way (e.g. the solve timed out)
+// so once the chain has an answer we forget it and sendAgreeTx picks up the rest
+func (controller *ResourceProviderController) isAgreePending(ctx context.Context, dealID string) bool {
+	txHash, ok := controller.pendingAgrees.get(dealID)
+	if !ok {
+		return false
+	}
+	isPending, err := controller.web3SDK.IsTxPending(ctx, txHash)
+	if err != nil {
+		// we can't tell so leave the deal alone until we can
+		controller.log.Error(fmt.Sprintf("error checking agree tx %s for deal %s", txHash, dealID), err)
+		return true
+	}
+	if !isPending {
+		controller.pendingAgrees.remove(dealID)
+	}
+	return isPending
+}
@@ -0,0 +1,47 @@
+package resourceprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/web3"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// a node that has never mined anything and has the txs in inPool waiting
+type unminedEthClient struct {
+	web3.EthClient
+	inPool map[common.Hash]bool
+}
+
+func (client *unminedEthClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return nil, ethereum.NotFound
+}
+
+func (client *unminedEthClient) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	if !client.inPool[txHash] {
+		return nil, false, ethereum.NotFound
+	}
+	return &types.Transaction{}, true, nil
+}
+
+func TestIsAgreePending(t *testing.T) {
+	controller, _, _ := newTestController(t, ResourceProviderOptions{})
+	ethClient := &unminedEthClient{inPool: map[common.Hash]bool{}}
+	controller.web3SDK.Client = ethClient
+
+	assert.False(t, controller.isAgreePending(context.Background(), "deal1"), "We haven't sent anything for the deal")
+
+	txHash := common.HexToHash("0x1")
+	ethClient.inPool[txHash] = true
+	controller.pendingAgrees.add("deal1", txHash.String())
+	assert.True(t, controller.isAgreePending(context.Background(), "deal1"), "The deal should be skipped while our agree tx waits to be mined")
+
+	delete(ethClient.inPool, txHash)
+	assert.False(t, controller.isAgreePending(context.Background(), "deal1"), "A dropped tx should let us try again")
+	_, ok := controller.pendingAgrees.get("deal1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,50 @@
+package resourceprovider
+
+import "github.com/bacalhau-project/lilypad/pkg/data"
+
+// PricingStrategy decides what we charge when we post a resource offer
+// module is empty when asking for the default price of the offer
+type PricingStrategy interface {
+	PriceFor(spec data.MachineSpec, module string) data.DealPricing
+}
+
+// StaticPricingStrategy charges whatever was configured in the offer options
+type StaticPricingStrategy struct {
+	DefaultPricing data.DealPricing
+	ModulePricing  map[string]data.DealPricing
+}
+
+func NewStaticPricingStrategy(options ResourceProviderOfferOptions) *StaticPricingStrategy {
+	return &StaticPricingStrategy{
+		DefaultPricing: options.DefaultPricing,
+		ModulePricing:  options.ModulePricing,
+	}
+}
+
+func (strategy *StaticPricingStrategy) PriceFor(spec data.MachineSpec, module string) data.DealPricing {
+	if pricing, ok := strategy.ModulePricing[module]; ok && module != "" {
+		return pricing
+	}
+	return strategy.DefaultPricing
+}
+
+// modules can be listed and priced by name or by module id (see isModuleOffered)
+// so this is whichever one our options know the deal's module by
+func getPricedModule(options ResourceProviderOfferOptions, module data.ModuleConfig) string {
+	moduleID, err := data.GetModuleID(module)
+	if err != nil {
+		return module.Name
+	}
+	if _, ok := options.ModulePricing[moduleID]; ok {
+		return moduleID
+	}
+	for _, offeredModule := range options.Modules {
+		if offeredModule == moduleID {
+			return moduleID
+		}
+	}
+	return module.Name
+}
+
+// Compile-time interface check:
+var _ PricingStrategy = (*StaticPricingStrategy)(nil)
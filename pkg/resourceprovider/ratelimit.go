@@ -0,0 +1,74 @@
+package resourceprovider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// a token bucket that lets through rate calls per second on average
+// with bursts of up to burst calls
+// a nil limiter lets everything through
+type rateLimiter struct {
+	mutex    sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+// returns nil if rate is not positive so that callers don't need to check
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:     rate,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+	}
+}
+
+// take a token and return how long the caller must wait before using it
+func (limiter *rateLimiter) reserve(now time.Time) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if now.After(limiter.lastFill) {
+		limiter.tokens += now.Sub(limiter.lastFill).Seconds() * limiter.rate
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+		limiter.lastFill = now
+	}
+
+	// tokens can go negative which is how later callers queue up behind earlier ones
+	limiter.tokens--
+	if limiter.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+}
+
+// block until we are allowed to make another call or the context is done
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	if limiter == nil {
+		return nil
+	}
+	delay := limiter.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package resourceprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := newRateLimiter(2, 2)
+	now := limiter.lastFill
+
+	// the burst is let straight through
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+
+	// then each call waits behind the one before it
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(now))
+	assert.Equal(t, time.Second, limiter.reserve(now))
+
+	// waiting refills the bucket but never beyond the burst
+	now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(now))
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := newRateLimiter(0, 10)
+	assert.Nil(t, limiter)
+	assert.NoError(t, limiter.wait(context.Background()))
+}
@@ -0,0 +1,64 @@
+package resourceprovider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+)
+
+// a deal we keep rejecting stays negotiating and turns up in every solve
+// so we only report each deal once per reason within this window
+const REJECTED_DEALS_WINDOW = time.Hour
+
+// why we didn't agree to a deal the solver matched us with
+// the values are used as the reason label on deals_rejected_total
+type DealRejectReason string
+
+const (
+	// the deal is for another resource provider - we hear about every deal on a solver
+	DealRejectWrongResourceProvider DealRejectReason = "wrong_resource_provider"
+	DealRejectMalformedSpec         DealRejectReason = "malformed_spec"
+	DealRejectJobCreatorNotAllowed  DealRejectReason = "job_creator_not_allowed"
+	DealRejectModuleNotOffered      DealRejectReason = "module_not_offered"
+	DealRejectMediatorNotTrusted    DealRejectReason = "mediator_not_trusted"
+	// the deal's pricing is worse for us than our pricing for its module
+	DealRejectUnderpriced DealRejectReason = "underpriced"
+	// the job offer is older than MaxDealAge
+	DealRejectTooOld DealRejectReason = "too_old"
+	// the agree window has closed on-chain
+	DealRejectExpired DealRejectReason = "expired"
+	// the deal's spec has no free slots
+	DealRejectOverCapacity DealRejectReason = "over_capacity"
+	// we are running MaxConcurrentDeals - the deal is left for a later solve
+	DealRejectMaxConcurrentDeals DealRejectReason = "max_concurrent_deals"
+	// simulating the agree tx showed it would revert
+	DealRejectWouldRevert DealRejectReason = "would_revert"
+)
+
+func (reason DealRejectReason) String() string {
+	return string(reason)
+}
+
+// count the rejection and log one line for it with the reason as a field
+// deals for other resource providers are only logged at debug as there is one
+// for every deal the solver makes
+func (controller *ResourceProviderController) rejectDeal(ctx context.Context, dealContainer data.DealContainer, reason DealRejectReason, detail string) {
+	if controller.rejectedDeals.checkAndAdd(dealContainer.ID+"/"+reason.String(), controller.clock.Now()) {
+		return
+	}
+	dealsRejectedTotal.WithLabelValues(reason.String()).Inc()
+	log := controller.log.Ctx(ctx)
+	title := fmt.Sprintf("rejecting deal %s", dealContainer.ID)
+	fields := []system.Field{
+		system.F("reason", reason.String()),
+		system.F("jobCreator", dealContainer.JobCreator),
+	}
+	if reason == DealRejectWrongResourceProvider {
+		log.Debug(title, detail, fields...)
+		return
+	}
+	log.Info(title, detail, fields...)
+}
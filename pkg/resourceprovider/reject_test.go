@@ -0,0 +1,44 @@
+package resourceprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDealAllowedReasons(t *testing.T) {
+	options := ResourceProviderOptions{JobCreatorDenylist: []string{"0xdenied"}}
+	options.Offers.Modules = []string{"cowsay:v0.0.1"}
+	controller, _, _ := newTestController(t, options)
+	getDeal := func(jobCreator string, module data.ModuleConfig) data.DealContainer {
+		deal := data.DealContainer{ID: "deal1", JobCreator: jobCreator}
+		deal.Deal.JobOffer.Module = module
+		return deal
+	}
+
+	reason, _ := controller.isDealAllowed(getDeal("0xjc", data.ModuleConfig{Name: "cowsay:v0.0.1"}))
+	assert.Empty(t, reason)
+	reason, _ = controller.isDealAllowed(getDeal("0xdenied", data.ModuleConfig{Name: "cowsay:v0.0.1"}))
+	assert.Equal(t, DealRejectJobCreatorNotAllowed, reason)
+	reason, _ = controller.isDealAllowed(getDeal("0xjc", data.ModuleConfig{Name: "sdxl:v0.0.1"}))
+	assert.Equal(t, DealRejectModuleNotOffered, reason)
+	reason, _ = controller.isDealAllowed(getDeal("0xjc", data.ModuleConfig{}))
+	assert.Equal(t, DealRejectMalformedSpec, reason)
+}
+
+func TestRejectDealCountsEachDealOnce(t *testing.T) {
+	controller, _, _ := newTestController(t, ResourceProviderOptions{})
+	rejected := dealsRejectedTotal.WithLabelValues(DealRejectUnderpriced.String())
+	before := testutil.ToFloat64(rejected)
+
+	deal := data.DealContainer{ID: "deal1"}
+	controller.rejectDeal(context.Background(), deal, DealRejectUnderpriced, "too cheap")
+	controller.rejectDeal(context.Background(), deal, DealRejectUnderpriced, "too cheap")
+	assert.Equal(t, before+1, testutil.ToFloat64(rejected), "A deal rejected on every solve should only be counted once")
+
+	controller.rejectDeal(context.Background(), data.DealContainer{ID: "deal2"}, DealRejectUnderpriced, "too cheap")
+	assert.Equal(t, before+2, testutil.ToFloat64(rejected))
+}
@@ -2,19 +2,49 @@ package resourceprovider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/bacalhau-project/lilypad/pkg/data"
 	"github.com/bacalhau-project/lilypad/pkg/executor"
 	"github.com/bacalhau-project/lilypad/pkg/executor/bacalhau"
+	"github.com/bacalhau-project/lilypad/pkg/solver"
 	"github.com/bacalhau-project/lilypad/pkg/system"
 	"github.com/bacalhau-project/lilypad/pkg/web3"
+	"github.com/ethereum/go-ethereum/common"
 )
 
+// a spec from our config - the MachineSpec is what we offer to solvers
+// and the rest is how we offer it, which is never sent to them
+// the MachineSpec is embedded so config files keep its keys at the top level
+type OfferSpec struct {
+	data.MachineSpec
+
+	// name the spec so its offers are tracked by the name
+	// and not by the spec's position in the config (see offerKey)
+	ID string `json:"id,omitempty"`
+
+	// fill in the values of the MachineSpec from the host we are running on
+	AutoDetect bool `json:"auto_detect,omitempty"`
+
+	// offer the machine whole and also split into this many equal slots
+	// so it can take several smaller jobs (see slots.go)
+	Slots int `json:"slots,omitempty"`
+
+	// only offer the spec at these times (see schedule.go)
+	// e.g. ["mon-fri 18:00-08:00", "sat,sun 00:00-24:00"] - empty means always
+	Schedule []string `json:"schedule,omitempty"`
+}
+
 // this configures the resource offers we will keep track of
 type ResourceProviderOfferOptions struct {
 	// if we are configuring a single machine then
 	// these values are populated by the flags
-	OfferSpec data.MachineSpec
+	OfferSpec OfferSpec
 	// we can dupliate the single spec to create a list of specs
 	OfferCount int
 	// this represents how many machines we will keep
@@ -22,7 +52,16 @@ type ResourceProviderOfferOptions struct {
 	// we can configure this with a config file
 	// to start with we will just add --cpu --gpu and --ram flags
 	// to the resource provider CLI which constrains them to a single machine
-	Specs []data.MachineSpec
+	Specs []OfferSpec
+	// if set, a spec is only posted as a new resource offer when this returns true
+	// so operators can hold back specs whose hardware is busy right now
+	// specs keep their ID (or their position in Specs) whether or not they are
+	// filtered, so a spec that is skipped now is posted under the same key later
+	// and offers that are already active on the solver are left alone
+	SpecFilter func(data.MachineSpec) bool
+	// specs marked AutoDetect get the host resources multiplied by this
+	// so we can leave some headroom for the OS and other processes
+	AutoDetectFraction float64
 	// the list of modules we are willing to run
 	// an empty list means anything
 	Modules []string
@@ -38,15 +77,292 @@ type ResourceProviderOfferOptions struct {
 	// allow different pricing for different modules
 	ModulePricing  map[string]data.DealPricing
 	ModuleTimeouts map[string]data.DealTimeouts
+	// timeouts for whole groups of modules, like slow training jobs
+	// the first matching category is used for modules without ModuleTimeouts
+	CategoryTimeouts []ModuleCategoryTimeouts
+
+	// works out the prices we put in each resource offer
+	// if this is nil we use the DefaultPricing and ModulePricing above
+	PricingStrategy PricingStrategy
 
 	// which mediators and directories this RP will trust
 	Services data.ServiceConfig
+
+	// extra solvers we will also post our offers to
+	// an empty list means we only use Services.Solver
+	Solvers []string
+
+	// how long the solver keeps an offer before expiring it, 0 if it never does
+	// offers older than OfferTTL minus OfferRefreshMargin are posted again
+	// with a fresh CreatedAt so they never drop off the solver
+	OfferTTL           time.Duration
+	OfferRefreshMargin time.Duration
+}
+
+// the full list of solvers we should be posting offers to
+// the primary solver always comes first
+func (options ResourceProviderOfferOptions) GetSolverAddresses() []string {
+	addresses := []string{options.Services.Solver}
+	seen := map[string]bool{options.Services.Solver: true}
+	for _, solverAddress := range options.Solvers {
+		if solverAddress == "" || seen[solverAddress] {
+			continue
+		}
+		seen[solverAddress] = true
+		addresses = append(addresses, solverAddress)
+	}
+	return addresses
 }
 
 type ResourceProviderOptions struct {
 	Bacalhau bacalhau.BacalhauExecutorOptions
 	Offers   ResourceProviderOfferOptions
 	Web3     web3.Web3Options
+
+	// how many times we will try to submit an agree tx for a single deal
+	// before we give up on it and leave it to time out
+	// 0 means DEFAULT_AGREE_MAX_ATTEMPTS
+	AgreeMaxAttempts int
+	// PEM files for solvers that want mutual tls, all optional
+	SolverTLSClientCert string
+	SolverTLSClientKey  string
+	SolverTLSCACert     string
+	// how long a single request to a solver can take
+	// and how many connections we keep to each solver, zero means the defaults
+	SolverTimeout         time.Duration
+	SolverMaxIdleConns    int
+	SolverMaxConnsPerHost int
+	// how connections to solvers are kept alive, see http.ClientOptions
+	SolverIdleConnTimeout time.Duration
+	SolverKeepAlive       time.Duration
+	SolverForceHTTP2      bool
+	// how reads from a solver are retried so a blip doesn't fail the solve
+	// zero means the http client's defaults
+	SolverGetRetryMax     int
+	SolverGetRetryWaitMin time.Duration
+	SolverGetRetryWaitMax time.Duration
+
+	// how long to wait before retrying a failed agree tx
+	// this doubles with each failed attempt for the same deal up to MAX_AGREE_RETRY_DELAY
+	AgreeRetryBaseDelay time.Duration
+	// how many deals we will agree to in parallel
+	AgreeConcurrency int
+	// the most deals we will have agreed to and not yet finished at once
+	// across all solvers, 0 for no limit
+	MaxConcurrentDeals int
+	// negotiating deals whose job offer is older than this are skipped when we
+	// poll the solver for them unless a DealAdded event for the deal has just
+	// come in (within EventDedupeWindow), 0 for no limit
+	MaxDealAge time.Duration
+	// run each agree tx as an eth_call first and skip deals it would revert
+	// for (already agreed, expired, wrong state) rather than pay gas to find out
+	SimulateBeforeSend bool
+
+	// the most resource offers we will post per second, 0 for no limit
+	OfferRateLimit float64
+	// how many offers can be posted at once before the rate limit kicks in
+	OfferRateBurst int
+	// agree txs take tokens from the same bucket as posted offers
+	ShareRateLimiter bool
+
+	// how long the background solve loop waits between runs
+	// (events from the solver will trigger a solve straight away)
+	SolveInterval time.Duration
+	// if consecutive solves find nothing to do we double the wait
+	// between them up to this ceiling
+	MaxSolveInterval time.Duration
+	// each wait between background solves is moved randomly by up to this
+	// fraction of the interval so RPs started together don't poll together
+	SolveJitter float64
+	// a single solve is abandoned if it takes longer than this
+	SolveTimeout time.Duration
+	// log one info line per solve saying what it did
+	SolveSummaryLog bool
+	// stop the resource provider once this many solves in a row have failed
+	// 0 means we keep solving whatever happens
+	MaxConsecutiveErrors int
+	// DealAdded events for a deal we already heard about within this window are ignored
+	EventDedupeWindow time.Duration
+
+	// how long the rpc calls we make at startup (like looking up solver urls)
+	// can take before we give up starting, 0 for no limit
+	StartupTimeout time.Duration
+
+	// if set we will serve prometheus metrics on this port
+	MetricsPort int
+	// if set we export our spans to this otlp/http collector e.g. http://otel-collector:4318
+	// and pass our trace context on to the solvers we call
+	OtelExporterEndpoint string
+	// if set we will serve /healthz and /readyz on this port
+	HealthPort int
+
+	// on shutdown, how long we will wait for in-flight agree txs to be mined
+	ShutdownGracePeriod time.Duration
+	// on shutdown, take our resource offers down so the solver
+	// stops matching deals to us while we are away
+	RemoveOffersOnShutdown bool
+	// on SIGTERM we drain (see Drain) before shutting down
+	// and give up waiting for our deals to finish after this long
+	DrainTimeout time.Duration
+
+	// if set we record the agree txs we send and the offers we post in this
+	// file so that we don't send the same agree tx again after a restart
+	StateFile string
+
+	// if set we append a json line to this file for every deal we agree to
+	// with its price and agree tx so there is a record for disputes
+	AuditLogFile string
+	// megabytes the audit log can grow to before it is moved to <file>.1
+	// and a new one started, 0 for no limit
+	AuditLogMaxSize int
+
+	// log the offers we would post and the deals we would agree to
+	// without actually sending anything to the solver or the chain
+	DryRun bool
+
+	// only watch the solvers and the chain e.g. for a network monitor
+	// we never post offers, agree to deals or run jobs so nothing is sent on-chain
+	// and any key will do (even a throwaway one with no funds)
+	// every deal's state changes are published, not just ours
+	ObserverMode bool
+
+	// if not empty we will only take deals from these job creators
+	JobCreatorAllowlist []string
+	// we will never take deals from these job creators
+	JobCreatorDenylist []string
+
+	// deal events are posted here for operators to alert on
+	Webhook WebhookOptions
+
+	// for apps embedding the resource provider, both optional
+	// OnDealAgreed is called once our agree tx for a deal has been mined
+	// and OnDealFailed each time an attempt to agree to a deal fails
+	// they are called from the agree workers so should return quickly
+	OnDealAgreed func(deal data.Deal)            `json:"-"`
+	OnDealFailed func(deal data.Deal, err error) `json:"-"`
+	// OnSolverEvent is called with every event from each solver we are connected to
+	// before we decide if it is for us
+	OnSolverEvent func(solverAddress string, ev solver.SolverEvent) `json:"-"`
+}
+
+// check the options make sense before we try to use them
+// all of the problems are returned together so they can be fixed in one go
+func (options ResourceProviderOptions) Validate() error {
+	errs := []error{}
+
+	// a keystore or a signer given to the controller can be used instead of a private key
+	if options.Web3.PrivateKey != "" {
+		if _, err := web3.ParsePrivateKey(options.Web3.PrivateKey); err != nil {
+			errs = append(errs, fmt.Errorf("private key is invalid: %s", err.Error()))
+		}
+	}
+	for _, solverAddress := range options.Offers.GetSolverAddresses() {
+		if !common.IsHexAddress(solverAddress) {
+			errs = append(errs, fmt.Errorf("solver address is not a valid hex address: %q", solverAddress))
+		}
+	}
+
+	// the url is for the solver at Services.Solver and we would have to
+	// guess which url belongs to any other solvers
+	if options.Web3.SolverURL != "" {
+		solverURL, err := url.Parse(options.Web3.SolverURL)
+		if err != nil || (solverURL.Scheme != "http" && solverURL.Scheme != "https") || solverURL.Host == "" {
+			errs = append(errs, fmt.Errorf("solver url must be an http or https url: %q", options.Web3.SolverURL))
+		}
+		if solverAddresses := options.Offers.GetSolverAddresses(); len(solverAddresses) > 1 {
+			errs = append(errs, fmt.Errorf("a solver url can only be given with a single solver but %d are configured", len(solverAddresses)))
+		}
+	}
+
+	// an observer has nothing to offer
+	if len(options.Offers.Specs) == 0 && !options.ObserverMode {
+		errs = append(errs, fmt.Errorf("at least one spec must be configured"))
+	}
+	if len(options.Offers.Specs) >= SLOT_INDEX_STRIDE {
+		errs = append(errs, fmt.Errorf("at most %d specs can be configured", SLOT_INDEX_STRIDE-1))
+	}
+	specIDs := map[string]bool{}
+	for index, spec := range options.Offers.Specs {
+		// offers are tracked by the spec ID so two specs can't share one
+		if spec.ID != "" {
+			if specIDs[spec.ID] {
+				errs = append(errs, fmt.Errorf("spec %d: duplicate spec id %q", index, spec.ID))
+			}
+			specIDs[spec.ID] = true
+		}
+		for label := range spec.Labels {
+			if strings.TrimSpace(label) == "" {
+				errs = append(errs, fmt.Errorf("spec %d: labels cannot have an empty name", index))
+				break
+			}
+		}
+		for _, window := range spec.Schedule {
+			if _, err := parseScheduleWindow(window); err != nil {
+				errs = append(errs, fmt.Errorf("spec %d: %w", index, err))
+			}
+		}
+		if spec.Slots < 0 || spec.Slots >= SLOT_INDEX_STRIDE {
+			errs = append(errs, fmt.Errorf("spec %d: slots must be between 0 and %d", index, SLOT_INDEX_STRIDE-1))
+			continue
+		}
+		// auto detected specs are only known once we start
+		if spec.Slots > 1 && !spec.AutoDetect && (spec.CPU/spec.Slots == 0 || spec.RAM/spec.Slots == 0) {
+			errs = append(errs, fmt.Errorf("spec %d: too small to split into %d slots", index, spec.Slots))
+		}
+	}
+
+	// an empty module list means we will run anything
+	// otherwise we can only price modules we are willing to run
+	if len(options.Offers.Modules) > 0 {
+		modules := map[string]bool{}
+		for _, module := range options.Offers.Modules {
+			modules[module] = true
+		}
+		for module := range options.Offers.ModulePricing {
+			if !modules[module] {
+				errs = append(errs, fmt.Errorf("module pricing given for unknown module: %s", module))
+			}
+		}
+		for module := range options.Offers.ModuleTimeouts {
+			if !modules[module] {
+				errs = append(errs, fmt.Errorf("module timeouts given for unknown module: %s", module))
+			}
+		}
+	}
+	for index, category := range options.Offers.CategoryTimeouts {
+		if _, err := path.Match(category.Pattern, ""); err != nil || category.Pattern == "" {
+			errs = append(errs, fmt.Errorf("category timeouts %d: invalid module pattern %q", index, category.Pattern))
+		}
+	}
+
+	if options.OtelExporterEndpoint != "" {
+		endpointURL, err := url.Parse(options.OtelExporterEndpoint)
+		if err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Host == "" {
+			errs = append(errs, fmt.Errorf("otel exporter endpoint must be an http or https url: %q", options.OtelExporterEndpoint))
+		}
+	}
+
+	if options.Webhook.URL != "" {
+		webhookURL, err := url.Parse(options.Webhook.URL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+			errs = append(errs, fmt.Errorf("webhook url must be an http or https url: %q", options.Webhook.URL))
+		}
+	}
+	if options.AgreeMaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("agree max attempts cannot be negative"))
+	}
+	if options.AgreeRetryBaseDelay < 0 {
+		errs = append(errs, fmt.Errorf("agree retry base delay cannot be negative"))
+	}
+	if options.AuditLogMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("audit log max size cannot be negative"))
+	}
+
+	if options.Webhook.RetryMax < 0 || options.Webhook.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("webhook retries and timeout cannot be negative"))
+	}
+
+	return errors.Join(errs...)
 }
 
 type ResourceProvider struct {
@@ -60,7 +376,7 @@ func NewResourceProvider(
 	web3SDK *web3.Web3SDK,
 	executor executor.Executor,
 ) (*ResourceProvider, error) {
-	controller, err := NewResourceProviderController(options, web3SDK, executor)
+	controller, err := NewResourceProviderController(options, web3SDK, executor, web3SDK.Signer)
 	if err != nil {
 		return nil, err
 	}
@@ -75,3 +391,31 @@ func NewResourceProvider(
 func (resourceProvider *ResourceProvider) Start(ctx context.Context, cm *system.CleanupManager) chan error {
 	return resourceProvider.controller.Start(ctx, cm)
 }
+
+func (resourceProvider *ResourceProvider) Status() ControllerStatus {
+	return resourceProvider.controller.Status()
+}
+
+func (resourceProvider *ResourceProvider) Drain(ctx context.Context) error {
+	return resourceProvider.controller.Drain(ctx)
+}
+
+func (resourceProvider *ResourceProvider) DealStateChanges() <-chan DealStateChange {
+	return resourceProvider.controller.DealStateChanges()
+}
+
+func (resourceProvider *ResourceProvider) RemoveOffer(index int) error {
+	return resourceProvider.controller.RemoveOffer(index)
+}
+
+func (resourceProvider *ResourceProvider) RestoreOffer(index int) {
+	resourceProvider.controller.RestoreOffer(index)
+}
+
+func (resourceProvider *ResourceProvider) ListActiveOffers(ctx context.Context) ([]data.ResourceOfferContainer, error) {
+	return resourceProvider.controller.ListActiveOffers(ctx)
+}
+
+func (resourceProvider *ResourceProvider) RemoveAllOffers(ctx context.Context) error {
+	return resourceProvider.controller.RemoveAllOffers(ctx)
+}
@@ -0,0 +1,93 @@
+package resourceprovider
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func getValidOptions(t *testing.T) ResourceProviderOptions {
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	options := ResourceProviderOptions{}
+	options.Web3.PrivateKey = hex.EncodeToString(crypto.FromECDSA(privateKey))
+	options.Offers.Services.Solver = "0xd4646ef9f7336b06841db3019b617ceadf435316"
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}}
+	return options
+}
+
+func TestValidate(t *testing.T) {
+	options := getValidOptions(t)
+	assert.NoError(t, options.Validate(), "Valid options should pass")
+
+	options.AgreeMaxAttempts = -1
+	assert.ErrorContains(t, options.Validate(), "agree max attempts cannot be negative")
+	options.AgreeMaxAttempts = 0
+
+	options.OtelExporterEndpoint = "otel-collector:4318"
+	assert.ErrorContains(t, options.Validate(), "otel exporter endpoint must be an http or https url")
+	options.OtelExporterEndpoint = ""
+
+	options.Offers.Modules = []string{"cowsay:v0.0.1"}
+	options.Offers.ModulePricing = map[string]data.DealPricing{"cowsay:v0.0.1": {}}
+	assert.NoError(t, options.Validate(), "Pricing for a listed module should pass")
+
+	options.Offers.Specs = []OfferSpec{{ID: "gpu", MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}}, {ID: "gpu", MachineSpec: data.MachineSpec{CPU: 2000, RAM: 2048}}}
+	assert.ErrorContains(t, options.Validate(), `duplicate spec id "gpu"`)
+
+	options.Offers.Specs = nil
+	assert.Error(t, options.Validate())
+	options.ObserverMode = true
+	assert.NoError(t, options.Validate(), "An observer doesn't need any specs")
+}
+
+func TestSolverURLSkipsLookup(t *testing.T) {
+	options := getValidOptions(t)
+	options.Web3.SolverURL = "http://solver.internal:8080"
+	// there is no web3 sdk to look the solver up with
+	controller, err := NewResourceProviderController(options, nil, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "http://solver.internal:8080", controller.solvers[0].client.GetURL())
+
+	options.Web3.SolverURL = ""
+	_, err = NewResourceProviderController(options, nil, nil, nil)
+	assert.ErrorContains(t, err, "no web3 sdk to look it up", "Without a url or an sdk we should fail rather than panic")
+	options.Web3.SolverURL = "http://solver.internal:8080"
+
+	options.Offers.Solvers = []string{"0x1111111111111111111111111111111111111111"}
+	assert.ErrorContains(t, options.Validate(), "a solver url can only be given with a single solver")
+	options.Offers.Solvers = nil
+	options.Web3.SolverURL = "solver.internal"
+	assert.ErrorContains(t, options.Validate(), "solver url must be an http or https url")
+}
+
+func TestCheckSolverURL(t *testing.T) {
+	solverAddress := "0xd4646ef9f7336b06841db3019b617ceadf435316"
+	assert.NoError(t, checkSolverURL(solverAddress, "https://solver.example.com"))
+	assert.NoError(t, checkSolverURL(solverAddress, "http://localhost:8080"))
+	assert.ErrorContains(t, checkSolverURL(solverAddress, ""), "has no url registered on-chain", "An unregistered url should say so rather than fail later")
+	assert.ErrorContains(t, checkSolverURL(solverAddress, "solver.example.com"), "must be an absolute url")
+	assert.ErrorContains(t, checkSolverURL(solverAddress, "http://[::1"), "invalid url")
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Web3.PrivateKey = "not a key"
+	options.Offers.Services.Solver = "not an address"
+	options.Offers.Modules = []string{"cowsay:v0.0.1"}
+	options.Offers.ModulePricing = map[string]data.DealPricing{"sdxl:v0.9": {}}
+	options.Offers.CategoryTimeouts = []ModuleCategoryTimeouts{{Pattern: "training-["}}
+
+	err := options.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "private key is invalid")
+	assert.Contains(t, err.Error(), "solver address is not a valid hex address")
+	assert.Contains(t, err.Error(), "at least one spec must be configured")
+	assert.Contains(t, err.Error(), "unknown module: sdxl:v0.9")
+	assert.Contains(t, err.Error(), `invalid module pattern "training-["`)
+}
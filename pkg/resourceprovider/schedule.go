@@ -0,0 +1,149 @@
+package resourceprovider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MINUTES_PER_DAY = 24 * 60
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// a window in a spec's Schedule e.g. "mon-fri 18:00-08:00" or "sat,sun 00:00-24:00"
+// the days are when the window opens and "*" is every day - a window whose end
+// is before its start runs past midnight into the next day
+// times are in the resource provider's local time
+type scheduleWindow struct {
+	days [7]bool
+	// minutes since midnight
+	start int
+	end   int
+}
+
+func parseScheduleWindow(window string) (scheduleWindow, error) {
+	ret := scheduleWindow{}
+	fields := strings.Fields(window)
+	if len(fields) != 2 { //nolint:gomnd
+		return ret, fmt.Errorf("schedule window %q must be days and a time range e.g. \"mon-fri 18:00-08:00\"", window)
+	}
+	days, err := parseScheduleDays(fields[0])
+	if err != nil {
+		return ret, fmt.Errorf("schedule window %q: %w", window, err)
+	}
+	ret.days = days
+	startTime, endTime, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return ret, fmt.Errorf("schedule window %q: time range must be start-end", window)
+	}
+	ret.start, err = parseScheduleTime(startTime)
+	if err != nil {
+		return ret, fmt.Errorf("schedule window %q: %w", window, err)
+	}
+	ret.end, err = parseScheduleTime(endTime)
+	if err != nil {
+		return ret, fmt.Errorf("schedule window %q: %w", window, err)
+	}
+	if ret.start == MINUTES_PER_DAY {
+		return ret, fmt.Errorf("schedule window %q: cannot start at 24:00", window)
+	}
+	if ret.start == ret.end {
+		return ret, fmt.Errorf("schedule window %q: start and end are the same", window)
+	}
+	return ret, nil
+}
+
+// "*" or a comma separated list of days and day ranges e.g. "mon-fri,sun"
+func parseScheduleDays(value string) ([7]bool, error) {
+	days := [7]bool{}
+	if value == "*" {
+		for day := range days {
+			days[day] = true
+		}
+		return days, nil
+	}
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		firstDay, ok := scheduleDays[first]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", first)
+		}
+		lastDay, ok := scheduleDays[last]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", last)
+		}
+		// ranges can wrap around the end of the week e.g. fri-mon
+		for day := firstDay; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == lastDay {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// HH:MM with 24:00 allowed as the end of the day
+func parseScheduleTime(value string) (int, error) {
+	hours, minutes, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("time %q must be HH:MM", value)
+	}
+	hour, err := strconv.Atoi(hours)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("time %q has an invalid hour", value)
+	}
+	minute, err := strconv.Atoi(minutes)
+	if err != nil || len(minutes) != 2 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time %q has invalid minutes", value)
+	}
+	ret := hour*60 + minute
+	if ret > MINUTES_PER_DAY {
+		return 0, fmt.Errorf("time %q is after 24:00", value)
+	}
+	return ret, nil
+}
+
+func (window scheduleWindow) contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	today := now.Weekday()
+	if window.start < window.end {
+		return window.days[today] && minute >= window.start && minute < window.end
+	}
+	// the window runs past midnight so we are either in the part that
+	// opened today or the part left over from yesterday
+	if minute >= window.start {
+		return window.days[today]
+	}
+	return minute < window.end && window.days[(today+6)%7]
+}
+
+// a spec with no schedule is always offered - otherwise it is offered while
+// any of its windows are open
+func isSpecScheduled(spec OfferSpec, now time.Time) (bool, error) {
+	if len(spec.Schedule) == 0 {
+		return true, nil
+	}
+	for _, value := range spec.Schedule {
+		window, err := parseScheduleWindow(value)
+		if err != nil {
+			return false, err
+		}
+		if window.contains(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
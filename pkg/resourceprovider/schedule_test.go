@@ -0,0 +1,78 @@
+package resourceprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleWindow(t *testing.T) {
+	// a monday
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(day int, clock string) time.Time {
+		minutes, err := parseScheduleTime(clock)
+		assert.NoError(t, err)
+		return monday.AddDate(0, 0, day).Add(time.Duration(minutes) * time.Minute)
+	}
+
+	nights, err := parseScheduleWindow("mon-fri 18:00-08:00")
+	assert.NoError(t, err)
+	assert.False(t, nights.contains(at(0, "12:00")))
+	assert.True(t, nights.contains(at(0, "18:00")))
+	assert.True(t, nights.contains(at(1, "07:59")), "The window should run past midnight")
+	assert.False(t, nights.contains(at(1, "08:00")))
+	assert.True(t, nights.contains(at(5, "07:00")), "Friday night runs into saturday")
+	assert.False(t, nights.contains(at(5, "20:00")), "The window does not open on saturday")
+	assert.False(t, nights.contains(at(0, "07:00")), "Sunday night is not in the window")
+
+	weekend, err := parseScheduleWindow("sat,sun 00:00-24:00")
+	assert.NoError(t, err)
+	assert.True(t, weekend.contains(at(6, "23:59")))
+	assert.False(t, weekend.contains(at(0, "00:00")))
+
+	everyDay, err := parseScheduleWindow("* 09:30-10:00")
+	assert.NoError(t, err)
+	assert.True(t, everyDay.contains(at(3, "09:45")))
+
+	options := getValidOptions(t)
+	options.Offers.Specs = []OfferSpec{{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}, Schedule: []string{"weekdays 18:00-08:00"}}}
+	assert.ErrorContains(t, options.Validate(), `spec 0: schedule window "weekdays 18:00-08:00": unknown day "weekdays"`)
+
+	for _, window := range []string{"", "mon", "mon 18:00", "funday 18:00-08:00", "mon 25:00-08:00", "mon 18:60-08:00", "mon 18:00-18:00", "mon 24:00-08:00"} {
+		_, err := parseScheduleWindow(window)
+		assert.Error(t, err, window)
+	}
+}
+
+func TestEnsureResourceOffersFollowsSchedule(t *testing.T) {
+	options := ResourceProviderOptions{}
+	options.Offers.Specs = []OfferSpec{
+		{MachineSpec: data.MachineSpec{CPU: 1000, RAM: 1024}},
+		{MachineSpec: data.MachineSpec{CPU: 2000, RAM: 2048}, Schedule: []string{"* 18:00-08:00"}},
+	}
+	controller, conn, client := newTestController(t, options)
+	clock := system.NewFakeClock(time.Date(2024, 1, 1, 19, 0, 0, 0, time.UTC))
+	controller.clock = clock
+	getOffers := func() []data.ResourceOfferContainer {
+		offers, err := client.GetResourceOffers(store.GetResourceOffersQuery{Active: true})
+		assert.NoError(t, err)
+		return offers
+	}
+
+	added, err := controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added, "Both specs should be offered in the evening")
+
+	clock.Advance(17 * time.Hour)
+	added, err = controller.ensureResourceOffers(context.Background(), conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, added)
+	offers := getOffers()
+	assert.Len(t, offers, 1, "The scheduled spec should be taken down once its window closes")
+	assert.Equal(t, 1000, offers[0].ResourceOffer.Spec.CPU)
+}
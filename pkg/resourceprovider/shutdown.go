@@ -0,0 +1,122 @@
+package resourceprovider
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// how often Drain checks whether our deals have finished
+const DRAIN_CHECK_INTERVAL = 5 * time.Second
+
+// mark the start of an agree tx so that shutdown can wait for it
+// returns false if we are shutting down or draining and should not start new work
+func (controller *ResourceProviderController) beginWork() bool {
+	controller.shutdownMutex.Lock()
+	defer controller.shutdownMutex.Unlock()
+	if controller.shuttingDown || controller.draining {
+		return false
+	}
+	controller.inflightWork.Add(1)
+	return true
+}
+
+func (controller *ResourceProviderController) endWork() {
+	controller.inflightWork.Done()
+}
+
+func (controller *ResourceProviderController) isShuttingDown() bool {
+	controller.shutdownMutex.Lock()
+	defer controller.shutdownMutex.Unlock()
+	return controller.shuttingDown
+}
+
+func (controller *ResourceProviderController) isDraining() bool {
+	controller.shutdownMutex.Lock()
+	defer controller.shutdownMutex.Unlock()
+	return controller.draining
+}
+
+// Drain gets us ready to be turned off without letting anyone down
+// our offers are taken down so no new deals are matched to us and we stop
+// agreeing to deals, then we wait for the deals we have agreed to to finish
+// the solve loop keeps running while we wait so agreed deals still get their jobs run
+func (controller *ResourceProviderController) Drain(ctx context.Context) error {
+	controller.shutdownMutex.Lock()
+	controller.draining = true
+	controller.shutdownMutex.Unlock()
+	controller.log.Info("draining", "")
+
+	// we carry on if some offers can't be removed because the solver
+	// will stop matching them to us once it can't reach us anyway
+	err := controller.withdrawAllOffers(ctx)
+	if err != nil {
+		controller.log.Error("error removing offers while draining", err)
+	}
+
+	err = controller.waitForInflightWork(ctx)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(DRAIN_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		running, err := controller.countRunningDeals(ctx)
+		if err != nil {
+			// the solver might come back so keep waiting
+			controller.log.Error("error counting running deals while draining", err)
+		} else if running == 0 {
+			controller.log.Info("drained", "")
+			return nil
+		} else {
+			controller.log.Info("waiting for running deals to finish", running)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("gave up draining: %w", ctx.Err())
+		}
+	}
+}
+
+func (controller *ResourceProviderController) waitForInflightWork(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		controller.inflightWork.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("gave up waiting for in-flight agree txs: %w", ctx.Err())
+	}
+}
+
+// stop accepting new work and wait for any in-flight agree txs to be mined
+// so that we don't exit with txs submitted but not recorded with the solver
+// this is registered with the cleanup manager so it runs on shutdown
+func (controller *ResourceProviderController) drainInflightWork(ctx context.Context) error {
+	controller.shutdownMutex.Lock()
+	controller.shuttingDown = true
+	controller.shutdownMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		controller.inflightWork.Wait()
+		close(done)
+	}()
+
+	controller.log.Info("waiting for in-flight agree txs", controller.options.ShutdownGracePeriod)
+
+	select {
+	case <-done:
+		controller.log.Info("in-flight agree txs finished", "")
+		return nil
+	case <-controller.clock.After(controller.options.ShutdownGracePeriod):
+		return fmt.Errorf("timed out after %s waiting for in-flight agree txs", controller.options.ShutdownGracePeriod)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
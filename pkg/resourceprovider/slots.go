@@ -0,0 +1,102 @@
+package resourceprovider
+
+import (
+	"fmt"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+)
+
+// a spec with Slots > 1 is offered whole and as that many equal sub-offers
+// so the same machine can take one big job or several small ones
+// each offer is tracked by a composite index of the spec index plus the slot
+// times SLOT_INDEX_STRIDE, so slot 0 (the whole spec) keeps the spec's own index
+const SLOT_INDEX_STRIDE = 1 << 16
+
+func getOfferIndex(specIndex int, slot int) int {
+	return specIndex + slot*SLOT_INDEX_STRIDE
+}
+
+func splitOfferIndex(index int) (int, int) {
+	return index % SLOT_INDEX_STRIDE, index / SLOT_INDEX_STRIDE
+}
+
+// the indexes of every offer we make for a spec, the whole spec first
+func getSpecOfferIndexes(spec OfferSpec, specIndex int) []int {
+	indexes := []int{getOfferIndex(specIndex, 0)}
+	for slot := 1; slot <= spec.Slots && spec.Slots > 1; slot++ {
+		indexes = append(indexes, getOfferIndex(specIndex, slot))
+	}
+	return indexes
+}
+
+// which of our offers a posted offer is - a spec with an ID is matched by the ID
+// so reordering the config doesn't make us replace every offer after the change
+// specs without one fall back to their position in the config
+type offerKey struct {
+	specID    string
+	specIndex int
+	slot      int
+}
+
+func getOfferKey(specID string, index int) offerKey {
+	specIndex, slot := splitOfferIndex(index)
+	if specID != "" {
+		specIndex = 0
+	}
+	return offerKey{specID: specID, specIndex: specIndex, slot: slot}
+}
+
+// the spec ID is ours and isn't sent to the solver so we look a posted offer up
+// in the ones we have posted (see stateStore) - an offer we have no record of
+// e.g. after a restart without a state file is taken to be for the spec that is
+// at its position in the config
+func (controller *ResourceProviderController) getResourceOfferKey(offer data.ResourceOffer) offerKey {
+	if postedOffer, ok := controller.state.getOfferByID(offer.ID); ok {
+		return getOfferKey(postedOffer.SpecID, postedOffer.Index)
+	}
+	specID := ""
+	specIndex, _ := splitOfferIndex(offer.Index)
+	if specIndex < len(controller.options.Offers.Specs) {
+		specID = controller.options.Offers.Specs[specIndex].ID
+	}
+	return getOfferKey(specID, offer.Index)
+}
+
+// offers without a spec ID print as the composite index they have always had
+func (key offerKey) String() string {
+	if key.specID == "" {
+		return fmt.Sprintf("%d", getOfferIndex(key.specIndex, key.slot))
+	}
+	return fmt.Sprintf("%s#%d", key.specID, key.slot)
+}
+
+func (key offerKey) sameSpec(other offerKey) bool {
+	return key.specID == other.specID && key.specIndex == other.specIndex
+}
+
+// the share of spec that the offer in slot gets
+// gpu memory is per gpu so it stays the same
+func getSlotSpec(spec data.MachineSpec, slots int, slot int) data.MachineSpec {
+	if slot == 0 || slots <= 1 {
+		return spec
+	}
+	spec.CPU /= slots
+	spec.GPU /= slots
+	spec.RAM /= slots
+	return spec
+}
+
+// true if the hardware behind the offer with key is already promised to a deal
+// through one of the spec's other offers - the whole spec overlaps every sub-offer
+// but the sub-offers don't overlap each other
+func isSlotCommitted(key offerKey, matchedOffers map[offerKey]bool) bool {
+	for matchedKey := range matchedOffers {
+		if matchedKey == key || !matchedKey.sameSpec(key) {
+			continue
+		}
+		if key.slot == 0 || matchedKey.slot == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package resourceprovider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/solver"
+)
+
+// after this many solves in a row fail for a solver we look up its url again
+const SOLVER_URL_REFRESH_THRESHOLD = 3
+
+// how long we wait between url lookups for the same solver
+// this doubles each time the solver is still failing so we don't hammer the chain
+const SOLVER_URL_REFRESH_BASE_DELAY = 30 * time.Second
+const SOLVER_URL_REFRESH_MAX_DELAY = 10 * time.Minute
+
+// the users contract can hand back an empty url for a solver that has not
+// registered one so we check it is somewhere we can actually connect to
+func checkSolverURL(solverAddress string, solverUrl string) error {
+	if solverUrl == "" {
+		return fmt.Errorf("solver %s has no url registered on-chain", solverAddress)
+	}
+	parsed, err := url.Parse(solverUrl)
+	if err != nil {
+		return fmt.Errorf("solver %s has an invalid url registered on-chain %q: %w", solverAddress, solverUrl, err)
+	}
+	if !parsed.IsAbs() || parsed.Host == "" {
+		return fmt.Errorf("solver %s has an invalid url registered on-chain %q: it must be an absolute url with a host", solverAddress, solverUrl)
+	}
+	return nil
+}
+
+// a solver we are posting resource offers to
+type solverConnection struct {
+	address string
+	client  solver.SolverClientInterface
+
+	// these are only touched by the solve loop
+	consecutiveFailures int
+	urlRefreshDelay     time.Duration
+	nextURLRefresh      time.Time
+	// the solver is too old for AddResourceOffers so we post offers one at a time
+	batchUnsupported bool
+}
+
+func (controller *ResourceProviderController) recordSolverSuccess(conn *solverConnection) {
+	conn.consecutiveFailures = 0
+	conn.urlRefreshDelay = 0
+	conn.nextURLRefresh = time.Time{}
+}
+
+// a solver that keeps failing might have re-registered at a new url
+// so once it has failed enough times we ask the chain where it is now
+func (controller *ResourceProviderController) recordSolverFailure(ctx context.Context, conn *solverConnection) {
+	conn.consecutiveFailures++
+	if conn.consecutiveFailures < SOLVER_URL_REFRESH_THRESHOLD {
+		return
+	}
+	// a url we were given isn't on-chain to look up
+	if controller.options.Web3.SolverURL != "" {
+		return
+	}
+	if controller.clock.Now().Before(conn.nextURLRefresh) {
+		return
+	}
+
+	if conn.urlRefreshDelay == 0 {
+		conn.urlRefreshDelay = SOLVER_URL_REFRESH_BASE_DELAY
+	} else {
+		conn.urlRefreshDelay *= 2
+		if conn.urlRefreshDelay > SOLVER_URL_REFRESH_MAX_DELAY {
+			conn.urlRefreshDelay = SOLVER_URL_REFRESH_MAX_DELAY
+		}
+	}
+	conn.nextURLRefresh = controller.clock.Now().Add(conn.urlRefreshDelay)
+
+	solverUrl, err := controller.web3SDK.GetSolverUrl(ctx, conn.address)
+	if err == nil {
+		err = checkSolverURL(conn.address, solverUrl)
+	}
+	if err != nil {
+		controller.log.Error(fmt.Sprintf("error looking up url for solver %s", conn.address), err)
+		return
+	}
+	if solverUrl == conn.client.GetURL() {
+		controller.log.Debug(fmt.Sprintf("solver %s url has not changed", conn.address), solverUrl)
+		return
+	}
+	controller.log.Info(fmt.Sprintf("solver %s has moved", conn.address), solverUrl)
+	conn.client.SetURL(solverUrl)
+}
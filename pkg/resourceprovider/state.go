@@ -0,0 +1,258 @@
+package resourceprovider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// bump this and add an entry to stateMigrations whenever the format changes
+const STATE_VERSION = 1
+
+// how long we remember deals we have finished agreeing to
+const STATE_DEAL_RETENTION = 24 * time.Hour
+
+// what we write to the state file
+type persistedState struct {
+	Version int `json:"version"`
+	// keyed by deal id
+	Deals map[string]persistedDeal `json:"deals"`
+	// keyed by solver address and offer key - see offerStateKey
+	Offers map[string]persistedOffer `json:"offers"`
+}
+
+type persistedDeal struct {
+	Solver string `json:"solver"`
+	// the hash of the agree tx we sent
+	AgreeTx string `json:"agree_tx"`
+	// true once the agree tx has been mined successfully
+	Agreed    bool      `json:"agreed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type persistedOffer struct {
+	Solver   string    `json:"solver"`
+	Index    int       `json:"index"`
+	SpecID   string    `json:"spec_id,omitempty"`
+	ID       string    `json:"id"`
+	PostedAt time.Time `json:"posted_at"`
+}
+
+// each migration takes the state from version N to N+1
+var stateMigrations = map[int]func(state *persistedState) error{
+	// files written before we had a version field
+	0: func(state *persistedState) error {
+		return nil
+	},
+}
+
+func offerStateKey(solverAddress string, key offerKey) string {
+	return fmt.Sprintf("%s/%s", solverAddress, key)
+}
+
+// remembers what we have done across restarts so we don't send the same
+// agree tx twice - if path is empty nothing is written to disk
+type stateStore struct {
+	mutex sync.Mutex
+	path  string
+	state persistedState
+}
+
+func newStateStore(path string) (*stateStore, error) {
+	store := &stateStore{
+		path: path,
+		state: persistedState{
+			Version: STATE_VERSION,
+			Deals:   map[string]persistedDeal{},
+			Offers:  map[string]persistedOffer{},
+		},
+	}
+	if path == "" {
+		return store, nil
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file %s: %w", path, err)
+	}
+	state, err := parseState(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error loading state file %s: %w", path, err)
+	}
+	store.state = state
+	return store, nil
+}
+
+func parseState(bytes []byte) (persistedState, error) {
+	state := persistedState{}
+	err := json.Unmarshal(bytes, &state)
+	if err != nil {
+		return state, err
+	}
+	if state.Version > STATE_VERSION {
+		return state, fmt.Errorf("state version %d is newer than we understand (%d)", state.Version, STATE_VERSION)
+	}
+	for state.Version < STATE_VERSION {
+		migration, ok := stateMigrations[state.Version]
+		if !ok {
+			return state, fmt.Errorf("no migration from state version %d", state.Version)
+		}
+		err = migration(&state)
+		if err != nil {
+			return state, fmt.Errorf("error migrating state from version %d: %w", state.Version, err)
+		}
+		state.Version++
+	}
+	if state.Deals == nil {
+		state.Deals = map[string]persistedDeal{}
+	}
+	if state.Offers == nil {
+		state.Offers = map[string]persistedOffer{}
+	}
+	return state, nil
+}
+
+// write to a temp file and rename it so we never leave a half written file
+// must be called with the mutex held
+func (store *stateStore) save() error {
+	if store.path == "" {
+		return nil
+	}
+	bytes, err := json.MarshalIndent(store.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(bytes)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), store.path)
+}
+
+func (store *stateStore) getDeal(dealID string) (persistedDeal, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	deal, ok := store.state.Deals[dealID]
+	return deal, ok
+}
+
+func (store *stateStore) setDeal(dealID string, deal persistedDeal) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	deal.UpdatedAt = time.Now()
+	store.state.Deals[dealID] = deal
+	return store.save()
+}
+
+func (store *stateStore) removeDeal(dealID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if _, ok := store.state.Deals[dealID]; !ok {
+		return nil
+	}
+	delete(store.state.Deals, dealID)
+	return store.save()
+}
+
+func (store *stateStore) getOffer(solverAddress string, key offerKey) (persistedOffer, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	offer, ok := store.state.Offers[offerStateKey(solverAddress, key)]
+	return offer, ok
+}
+
+// the offer we posted that the solver gave offerID to
+func (store *stateStore) getOfferByID(offerID string) (persistedOffer, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if offerID == "" {
+		return persistedOffer{}, false
+	}
+	for _, offer := range store.state.Offers {
+		if offer.ID == offerID {
+			return offer, true
+		}
+	}
+	return persistedOffer{}, false
+}
+
+func (store *stateStore) setOffer(offer persistedOffer) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.state.Offers[offerStateKey(offer.Solver, getOfferKey(offer.SpecID, offer.Index))] = offer
+	return store.save()
+}
+
+func (store *stateStore) removeOffer(solverAddress string, key offerKey) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stateKey := offerStateKey(solverAddress, key)
+	if _, ok := store.state.Offers[stateKey]; !ok {
+		return nil
+	}
+	delete(store.state.Offers, stateKey)
+	return store.save()
+}
+
+func (store *stateStore) countOffers(solverAddress string) int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	count := 0
+	for _, offer := range store.state.Offers {
+		if offer.Solver == solverAddress {
+			count++
+		}
+	}
+	return count
+}
+
+// forget every offer we posted to the solver e.g. once it has lost them
+func (store *stateStore) removeSolverOffers(solverAddress string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	changed := false
+	for stateKey, offer := range store.state.Offers {
+		if offer.Solver == solverAddress {
+			delete(store.state.Offers, stateKey)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return store.save()
+}
+
+// forget deals we finished agreeing to a while ago so the file doesn't grow forever
+// deals with an agree tx still pending are kept until we find out what happened
+func (store *stateStore) pruneDeals(olderThan time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	changed := false
+	for dealID, deal := range store.state.Deals {
+		if deal.Agreed && deal.UpdatedAt.Before(olderThan) {
+			delete(store.state.Deals, dealID)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return store.save()
+}
@@ -0,0 +1,59 @@
+package resourceprovider
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := newStateStore(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.setDeal("deal1", persistedDeal{Solver: "0xsolver", AgreeTx: "0xtx"}))
+	assert.NoError(t, store.setOffer(persistedOffer{Solver: "0xsolver", Index: 0, ID: "offer1"}))
+
+	reloaded, err := newStateStore(path)
+	assert.NoError(t, err)
+	deal, ok := reloaded.getDeal("deal1")
+	assert.True(t, ok, "Deal should survive a restart")
+	assert.Equal(t, "0xtx", deal.AgreeTx)
+	assert.False(t, deal.Agreed)
+	assert.Equal(t, "offer1", reloaded.state.Offers[offerStateKey("0xsolver", getOfferKey("", 0))].ID)
+}
+
+func TestStateStoreMigratesUnversionedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"deals":{"deal1":{"agree_tx":"0xtx"}}}`), 0644))
+
+	store, err := newStateStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, STATE_VERSION, store.state.Version)
+	assert.NotNil(t, store.state.Offers)
+	_, ok := store.getDeal("deal1")
+	assert.True(t, ok)
+}
+
+func TestStateStoreRejectsNewerVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"version":999}`), 0644))
+	_, err := newStateStore(path)
+	assert.Error(t, err)
+}
+
+func TestStateStorePrunesOnlyAgreedDeals(t *testing.T) {
+	store, err := newStateStore("")
+	assert.NoError(t, err)
+	assert.NoError(t, store.setDeal("agreed", persistedDeal{Agreed: true}))
+	assert.NoError(t, store.setDeal("pending", persistedDeal{AgreeTx: "0xtx"}))
+
+	assert.NoError(t, store.pruneDeals(time.Now().Add(time.Minute)))
+	_, ok := store.getDeal("agreed")
+	assert.False(t, ok, "Old agreed deals should be pruned")
+	_, ok = store.getDeal("pending")
+	assert.True(t, ok, "Pending deals should be kept")
+}
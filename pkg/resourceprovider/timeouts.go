@@ -0,0 +1,39 @@
+package resourceprovider
+
+import (
+	"path"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+)
+
+// the timeouts for every offered module whose name matches Pattern
+// patterns are path.Match globs like "training-*" or "*:v0.0.1"
+type ModuleCategoryTimeouts struct {
+	Pattern  string            `json:"pattern"`
+	Timeouts data.DealTimeouts `json:"timeouts"`
+}
+
+// the timeouts to list in an offer for each module that doesn't use the default
+// ModuleTimeouts wins, then the first category whose pattern matches
+// categories only apply to the modules we list, so with an empty module
+// list everything gets DefaultTimeouts
+func getModuleTimeouts(options ResourceProviderOfferOptions) map[string]data.DealTimeouts {
+	// copy the overrides so the offer does not share maps with our options
+	moduleTimeouts := map[string]data.DealTimeouts{}
+	for module, timeouts := range options.ModuleTimeouts {
+		moduleTimeouts[module] = timeouts
+	}
+	for _, module := range options.Modules {
+		if _, ok := moduleTimeouts[module]; ok {
+			continue
+		}
+		for _, category := range options.CategoryTimeouts {
+			// bad patterns are caught by Validate
+			if matched, _ := path.Match(category.Pattern, module); matched {
+				moduleTimeouts[module] = category.Timeouts
+				break
+			}
+		}
+	}
+	return moduleTimeouts
+}
@@ -0,0 +1,33 @@
+package resourceprovider
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetModuleTimeouts(t *testing.T) {
+	slow := data.DealTimeouts{Agree: data.DealTimeout{Timeout: 3600}}
+	slower := data.DealTimeouts{Agree: data.DealTimeout{Timeout: 7200}}
+	override := data.DealTimeouts{Agree: data.DealTimeout{Timeout: 60}}
+	options := ResourceProviderOfferOptions{
+		Modules: []string{"cowsay:v0.0.1", "training-llama:v1", "training-sdxl:v1"},
+		ModuleTimeouts: map[string]data.DealTimeouts{
+			"training-sdxl:v1": override,
+		},
+		CategoryTimeouts: []ModuleCategoryTimeouts{
+			{Pattern: "training-*", Timeouts: slow},
+			{Pattern: "training-llama:*", Timeouts: slower},
+		},
+	}
+
+	timeouts := getModuleTimeouts(options)
+	assert.Equal(t, map[string]data.DealTimeouts{
+		"training-llama:v1": slow,
+		"training-sdxl:v1":  override,
+	}, timeouts, "The first matching category should apply unless the module has its own timeouts")
+
+	timeouts["cowsay:v0.0.1"] = slow
+	assert.NotContains(t, options.ModuleTimeouts, "cowsay:v0.0.1", "The offer should not share maps with the options")
+}
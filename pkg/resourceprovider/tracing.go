@@ -0,0 +1,69 @@
+package resourceprovider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spans go to whatever provider has been registered with otel.SetTracerProvider
+// there isn't one unless OtelExporterEndpoint is set (see startTracing)
+// so RPs without a collector only pay for a no-op
+var tracer = otel.Tracer("github.com/bacalhau-project/lilypad/pkg/resourceprovider")
+
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// end the span and mark it as failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// send our spans to the otlp collector at endpoint e.g. http://otel-collector:4318
+// and pass the trace on to solvers in the traceparent header so their spans join ours
+// the spans that haven't been exported yet are flushed when we shut down
+func startTracing(ctx context.Context, endpoint string, cm *system.CleanupManager) error {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid otel exporter endpoint %q: %w", endpoint, err)
+	}
+	exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpointURL.Host)}
+	if endpointURL.Scheme == "http" {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+	}
+	// without a path the exporter posts to the collector's default of /v1/traces
+	if endpointURL.Path != "" && endpointURL.Path != "/" {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithURLPath(endpointURL.Path))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+	if err != nil {
+		return fmt.Errorf("error creating otel exporter for %s: %w", endpoint, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(string(system.ResourceProviderService)),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	cm.RegisterCallbackWithContext(provider.Shutdown)
+	return nil
+}
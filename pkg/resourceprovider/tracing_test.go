@@ -0,0 +1,67 @@
+package resourceprovider
+
+import (
+	"context"
+	corehttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/http"
+	"github.com/bacalhau-project/lilypad/pkg/solver"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartTracingPropagatesToSolver(t *testing.T) {
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	exported := make(chan struct{}, 1)
+	collector := httptest.NewServer(corehttp.HandlerFunc(func(res corehttp.ResponseWriter, req *corehttp.Request) {
+		if req.URL.Path == "/v1/traces" {
+			select {
+			case exported <- struct{}{}:
+			default:
+			}
+		}
+		res.WriteHeader(corehttp.StatusOK)
+	}))
+	defer collector.Close()
+	traceparents := make(chan string, 1)
+	solverServer := httptest.NewServer(corehttp.HandlerFunc(func(res corehttp.ResponseWriter, req *corehttp.Request) {
+		traceparents <- req.Header.Get("traceparent")
+		_, _ = res.Write([]byte("[]"))
+	}))
+	defer solverServer.Close()
+
+	cm := system.NewCleanupManager()
+	assert.NoError(t, startTracing(context.Background(), collector.URL, cm))
+	client, err := solver.NewSolverClient(http.ClientOptions{URL: solverServer.URL})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, span := startSpan(context.Background(), "solve")
+	_, err = client.WithContext(ctx).GetResourceOffers(store.GetResourceOffersQuery{})
+	assert.NoError(t, err)
+	endSpan(span, nil)
+
+	select {
+	case traceparent := <-traceparents:
+		assert.Contains(t, traceparent, span.SpanContext().TraceID().String(), "The solver should get our trace")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the solver was never called")
+	}
+
+	// shutting down flushes the span to the collector
+	cm.Cleanup(context.Background())
+	select {
+	case <-exported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the span was never exported")
+	}
+}
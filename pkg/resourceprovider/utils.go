@@ -0,0 +1,109 @@
+package resourceprovider
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+)
+
+// exponential backoff - the first retry waits for baseDelay
+// and each subsequent retry waits twice as long as the last, up to maxDelay
+// we double one step at a time so a large base delay can't overflow
+func getRetryDelay(baseDelay time.Duration, attempt int, maxDelay time.Duration) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// only offer a fraction of the cpu and ram the host has
+// GPUs are left alone because a partial GPU can't be shared with the OS
+// we round down so we never offer more than we were asked to
+func scaleMachineSpec(spec data.MachineSpec, fraction float64) data.MachineSpec {
+	if fraction <= 0 || fraction > 1 {
+		fraction = 1
+	}
+	return data.MachineSpec{
+		CPU:      int(float64(spec.CPU) * fraction),
+		GPU:      spec.GPU,
+		GPUModel: spec.GPUModel,
+		VRAM:     spec.VRAM,
+		RAM:      int(float64(spec.RAM) * fraction),
+	}
+}
+
+// when the agree window for a deal closes on-chain
+// the window starts when the first party agrees (dealCreatedAt, in unix seconds)
+// so there is no deadline yet if nobody has agreed or the deal has no agree timeout
+func getAgreeDeadline(deal data.Deal, dealCreatedAt uint64) (time.Time, bool) {
+	if dealCreatedAt == 0 || deal.Timeouts.Agree.Timeout == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(dealCreatedAt+deal.Timeouts.Agree.Timeout), 0), true
+}
+
+// true if what we would post now differs from the offer the solver has
+// ids and timestamps are ignored because they change every time we post
+func isResourceOfferOutOfDate(existing data.ResourceOffer, desired data.ResourceOffer) bool {
+	normalize := func(offer data.ResourceOffer) data.ResourceOffer {
+		offer.ID = ""
+		offer.CreatedAt = 0
+		// an empty list or map comes back from the solver as null
+		if len(offer.Modules) == 0 {
+			offer.Modules = nil
+		}
+		if len(offer.ModulePricing) == 0 {
+			offer.ModulePricing = nil
+		}
+		if len(offer.ModuleTimeouts) == 0 {
+			offer.ModuleTimeouts = nil
+		}
+		if len(offer.Services.Mediator) == 0 {
+			offer.Services.Mediator = nil
+		}
+		if len(offer.Spec.Labels) == 0 {
+			offer.Spec.Labels = nil
+		}
+		return offer
+	}
+	return !reflect.DeepEqual(normalize(existing), normalize(desired))
+}
+
+// true if the solver will expire the offer within margin of now
+// CreatedAt is in milliseconds (see getResourceOffer)
+func isResourceOfferExpiring(offer data.ResourceOffer, ttl time.Duration, margin time.Duration, now time.Time) bool {
+	if ttl <= 0 {
+		return false
+	}
+	createdAt := time.UnixMilli(int64(offer.CreatedAt))
+	return now.Sub(createdAt) >= ttl-margin
+}
+
+// a context that expires with ctx's deadline but is not cancelled along with it
+// it keeps ctx's values so the log fields and trace span carry over
+func withDeadlineOnly(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := valuesOnlyContext{values: ctx}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(detached)
+	}
+	return context.WithDeadline(detached, deadline)
+}
+
+// the values of another context without its deadline or cancellation
+type valuesOnlyContext struct {
+	values context.Context
+}
+
+func (ctx valuesOnlyContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (ctx valuesOnlyContext) Done() <-chan struct{}       { return nil }
+func (ctx valuesOnlyContext) Err() error                  { return nil }
+func (ctx valuesOnlyContext) Value(key interface{}) interface{} {
+	return ctx.values.Value(key)
+}
@@ -0,0 +1,96 @@
+package resourceprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRetryDelay(t *testing.T) {
+	base := 2 * time.Second
+	assert.Equal(t, 2*time.Second, getRetryDelay(base, 0, time.Hour), "Attempt zero should use the base delay")
+	assert.Equal(t, 2*time.Second, getRetryDelay(base, 1, time.Hour), "First retry should use the base delay")
+	assert.Equal(t, 4*time.Second, getRetryDelay(base, 2, time.Hour), "Second retry should double the delay")
+	assert.Equal(t, 16*time.Second, getRetryDelay(base, 4, time.Hour), "Fourth retry should be 8x the base delay")
+	assert.Equal(t, time.Hour, getRetryDelay(base, 100, time.Hour), "Large attempts should be capped")
+	assert.Equal(t, time.Hour, getRetryDelay(time.Minute, 30, time.Hour), "A large base delay should not overflow")
+	assert.Equal(t, time.Hour, getRetryDelay(2*time.Hour, 1, time.Hour), "A base delay over the max should be capped")
+}
+
+func TestScaleMachineSpec(t *testing.T) {
+	host := data.MachineSpec{CPU: 8000, GPU: 2000, RAM: 16384}
+	assert.Equal(t, host, scaleMachineSpec(host, 1), "A fraction of one should offer everything")
+	assert.Equal(t, data.MachineSpec{CPU: 6000, GPU: 2000, RAM: 12288}, scaleMachineSpec(host, 0.75), "CPU and RAM should be scaled but not GPU")
+	assert.Equal(t, host, scaleMachineSpec(host, 0), "An invalid fraction should offer everything")
+}
+
+func TestGetAgreeDeadline(t *testing.T) {
+	deal := data.Deal{}
+	deal.Timeouts.Agree.Timeout = 60
+
+	_, ok := getAgreeDeadline(deal, 0)
+	assert.False(t, ok, "A deal nobody has agreed to has no deadline yet")
+
+	deadline, ok := getAgreeDeadline(deal, 1000)
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1060, 0), deadline)
+
+	deal.Timeouts.Agree.Timeout = 0
+	_, ok = getAgreeDeadline(deal, 1000)
+	assert.False(t, ok, "A deal without an agree timeout never expires")
+}
+
+func TestIsResourceOfferOutOfDate(t *testing.T) {
+	existing := data.ResourceOffer{
+		ID:             "abc",
+		CreatedAt:      1,
+		Spec:           data.MachineSpec{CPU: 1000},
+		DefaultPricing: data.DealPricing{InstructionPrice: 10},
+	}
+	desired := existing
+	desired.ID = "def"
+	desired.CreatedAt = 2
+	desired.Modules = []string{}
+	desired.ModulePricing = map[string]data.DealPricing{}
+	desired.Spec.Labels = map[string]string{}
+	assert.False(t, isResourceOfferOutOfDate(existing, desired), "Ids, timestamps and empty collections should be ignored")
+
+	desired.DefaultPricing.InstructionPrice = 20
+	assert.True(t, isResourceOfferOutOfDate(existing, desired), "A pricing change should need a new offer")
+
+	desired = existing
+	desired.Spec.RAM = 1024
+	assert.True(t, isResourceOfferOutOfDate(existing, desired), "A spec change should need a new offer")
+
+	desired = existing
+	desired.Spec.Labels = map[string]string{"cuda": "12"}
+	assert.True(t, isResourceOfferOutOfDate(existing, desired), "A label change should need a new offer")
+}
+
+func TestIsResourceOfferExpiring(t *testing.T) {
+	now := time.UnixMilli(1000000)
+	offer := data.ResourceOffer{CreatedAt: int(now.Add(-9 * time.Minute).UnixMilli())}
+	assert.False(t, isResourceOfferExpiring(offer, 0, time.Minute, now), "Offers never expire without a ttl")
+	assert.False(t, isResourceOfferExpiring(offer, 20*time.Minute, time.Minute, now))
+	assert.True(t, isResourceOfferExpiring(offer, 10*time.Minute, time.Minute, now), "Offers inside the margin should be refreshed")
+	assert.True(t, isResourceOfferExpiring(offer, 5*time.Minute, time.Minute, now), "Expired offers should be refreshed")
+}
+
+func TestWithDeadlineOnly(t *testing.T) {
+	parent := system.WithLogFields(context.Background(), system.F("solveID", "123"))
+	parent, cancelParent := context.WithTimeout(parent, time.Minute)
+	ctx, cancel := withDeadlineOnly(parent)
+	defer cancel()
+	cancelParent()
+
+	assert.NoError(t, ctx.Err(), "Cancelling the parent should not cancel the detached context")
+	parentDeadline, _ := parent.Deadline()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, parentDeadline, deadline)
+	assert.Equal(t, system.LogFields(parent), system.LogFields(ctx), "Values should carry over")
+}
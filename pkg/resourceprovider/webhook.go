@@ -0,0 +1,152 @@
+package resourceprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// the events we can post to the webhook
+const (
+	WEBHOOK_EVENT_DEAL_AGREED       = "deal_agreed"
+	WEBHOOK_EVENT_AGREE_FAILED      = "agree_failed"
+	WEBHOOK_EVENT_DEAL_STATE_CHANGE = "deal_state_change"
+)
+
+// how many events we queue for the webhook before we start dropping them
+const WEBHOOK_QUEUE_SIZE = 100
+
+// how long a single post to the webhook can take if no timeout is set
+const WEBHOOK_DEFAULT_TIMEOUT = 10 * time.Second
+
+type WebhookOptions struct {
+	// where we POST deal events to, empty to disable the webhook
+	URL string
+	// which events are posted
+	DealAgreed      bool
+	AgreeFailed     bool
+	DealStateChange bool
+	// how many times a failed post is retried
+	RetryMax int
+	// how long a single post can take, 0 for WEBHOOK_DEFAULT_TIMEOUT
+	Timeout time.Duration
+}
+
+// what we POST to the webhook as json
+type WebhookEvent struct {
+	Type             string    `json:"type"`
+	DealID           string    `json:"deal_id"`
+	Timestamp        time.Time `json:"timestamp"`
+	ResourceProvider string    `json:"resource_provider"`
+	// the agree tx for deal_agreed
+	TxHash string `json:"tx_hash,omitempty"`
+	// why we couldn't agree for agree_failed
+	Error string `json:"error,omitempty"`
+	// the new on-chain state for deal_state_change e.g. "DealAgreed"
+	State string `json:"state,omitempty"`
+}
+
+// posts deal events to the operator's webhook in the background
+// so a slow or broken webhook never holds up agreeing to deals
+type webhookNotifier struct {
+	options WebhookOptions
+	client  *retryablehttp.Client
+	queue   chan WebhookEvent
+	log     *system.ServiceLogger
+}
+
+// returns nil if no webhook is configured
+func newWebhookNotifier(options WebhookOptions, log *system.ServiceLogger) *webhookNotifier {
+	if options.URL == "" {
+		return nil
+	}
+	client := retryablehttp.NewClient()
+	client.RetryMax = options.RetryMax
+	client.HTTPClient.Timeout = options.Timeout
+	if client.HTTPClient.Timeout <= 0 {
+		client.HTTPClient.Timeout = WEBHOOK_DEFAULT_TIMEOUT
+	}
+	client.Logger = nil
+	return &webhookNotifier{
+		options: options,
+		client:  client,
+		queue:   make(chan WebhookEvent, WEBHOOK_QUEUE_SIZE),
+		log:     log,
+	}
+}
+
+func (notifier *webhookNotifier) isEnabled(eventType string) bool {
+	switch eventType {
+	case WEBHOOK_EVENT_DEAL_AGREED:
+		return notifier.options.DealAgreed
+	case WEBHOOK_EVENT_AGREE_FAILED:
+		return notifier.options.AgreeFailed
+	case WEBHOOK_EVENT_DEAL_STATE_CHANGE:
+		return notifier.options.DealStateChange
+	default:
+		return false
+	}
+}
+
+// queue the event to be posted - it is dropped if the queue is full
+func (notifier *webhookNotifier) notify(event WebhookEvent) {
+	if notifier == nil || !notifier.isEnabled(event.Type) {
+		return
+	}
+	select {
+	case notifier.queue <- event:
+	default:
+		notifier.log.Debug("webhook queue full, dropping", event)
+	}
+}
+
+// post queued events until ctx is done
+func (notifier *webhookNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-notifier.queue:
+			err := notifier.post(ctx, event)
+			if err != nil {
+				notifier.log.Error(fmt.Sprintf("error posting %s event for deal %s to webhook", event.Type, event.DealID), err)
+			}
+		}
+	}
+}
+
+func (notifier *webhookNotifier) post(ctx context.Context, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, notifier.options.URL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// server errors are retried by the client, anything else is final
+	resp, err := notifier.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (controller *ResourceProviderController) notifyWebhook(event WebhookEvent) {
+	if controller.webhook == nil {
+		return
+	}
+	event.Timestamp = controller.clock.Now().UTC()
+	event.ResourceProvider = controller.web3SDK.GetAddress().String()
+	controller.webhook.notify(event)
+}
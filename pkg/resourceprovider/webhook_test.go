@@ -0,0 +1,54 @@
+package resourceprovider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	events := make(chan WebhookEvent, 10)
+	attempts := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first post fails so we can see it retried
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		event := WebhookEvent{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer server.Close()
+
+	notifier := newWebhookNotifier(WebhookOptions{
+		URL:        server.URL,
+		DealAgreed: true,
+		RetryMax:   1,
+	}, system.NewServiceLogger(system.ResourceProviderService))
+	notifier.client.RetryWaitMin = time.Millisecond
+	notifier.client.RetryWaitMax = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.run(ctx)
+
+	notifier.notify(WebhookEvent{Type: WEBHOOK_EVENT_AGREE_FAILED, DealID: "deal1"})
+	notifier.notify(WebhookEvent{Type: WEBHOOK_EVENT_DEAL_AGREED, DealID: "deal2", TxHash: "0xtx"})
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "deal2", event.DealID, "Disabled event types should not be posted")
+		assert.Equal(t, "0xtx", event.TxHash)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The webhook was never called")
+	}
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Nil(t, newWebhookNotifier(WebhookOptions{}, nil), "No url means no webhook")
+}
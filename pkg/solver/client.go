@@ -2,8 +2,11 @@ package solver
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"sync"
 
 	"github.com/bacalhau-project/lilypad/pkg/data"
 	"github.com/bacalhau-project/lilypad/pkg/http"
@@ -12,25 +15,89 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// how many deals we ask the solver for at a time in GetAllDeals
+const DEALS_PAGE_SIZE = 100
+
 type SolverClient struct {
+	// the url can change if the solver re-registers somewhere else
+	optionsMutex    sync.RWMutex
 	options         http.ClientOptions
 	solverEventSubs []func(SolverEvent)
+	// told whenever the event websocket connects or drops
+	connectionSubs []func(connected bool)
+	// nil unless the solver wants mutual tls
+	tlsConfig *tls.Config
 }
 
 func NewSolverClient(
 	options http.ClientOptions,
 ) (*SolverClient, error) {
+	// load the certs now so a bad path fails on startup
+	tlsConfig, err := http.GetTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	// share one transport between requests so we reuse connections to the solver
+	if options.Transport == nil {
+		options.Transport, err = http.NewTransport(options)
+		if err != nil {
+			return nil, err
+		}
+	}
 	client := &SolverClient{
 		options:         options,
 		solverEventSubs: []func(SolverEvent){},
+		tlsConfig:       tlsConfig,
 	}
 	return client, nil
 }
 
+// WithContext returns a client whose requests are cancelled with ctx and carry its trace
+// it shares our connections but not our event subscriptions so is only for requests
+func (client *SolverClient) WithContext(ctx context.Context) SolverClientInterface {
+	options := client.getOptions()
+	options.Context = ctx
+	return &SolverClient{
+		options:         options,
+		solverEventSubs: []func(SolverEvent){},
+		tlsConfig:       client.tlsConfig,
+	}
+}
+
+func (client *SolverClient) getOptions() http.ClientOptions {
+	client.optionsMutex.RLock()
+	defer client.optionsMutex.RUnlock()
+	return client.options
+}
+
+func (client *SolverClient) GetURL() string {
+	return client.getOptions().URL
+}
+
+// point the client at a new solver url
+// the event websocket will use it the next time it (re)connects
+func (client *SolverClient) SetURL(url string) {
+	client.optionsMutex.Lock()
+	defer client.optionsMutex.Unlock()
+	client.options.URL = url
+}
+
 // connect the websocket to the solver server
+// we listen for events until ctx is cancelled or cm cleans up
+// whichever comes first - cleaning up waits for the websocket to close
 func (client *SolverClient) Start(ctx context.Context, cm *system.CleanupManager) error {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	cm.RegisterCallback(func() error {
+		cancel()
+		<-stopped
+		return nil
+	})
+
 	websocketEventChannel := make(chan []byte)
+	eventsStopped := make(chan struct{})
 	go func() {
+		defer close(eventsStopped)
 		for {
 			select {
 			case evBytes := <-websocketEventChannel:
@@ -49,11 +116,33 @@ func (client *SolverClient) Start(ctx context.Context, cm *system.CleanupManager
 			}
 		}
 	}()
-	http.ConnectWebSocket(
-		http.WebsocketURL(client.options, http.WEBSOCKET_SUB_PATH),
+	// the websocket reconnects by itself if the solver goes away
+	// and keeps feeding the same channel so our handlers keep firing
+	websocketStopped := http.ConnectWebSocket(
+		func() string {
+			return http.WebsocketURL(client.getOptions(), http.WEBSOCKET_SUB_PATH)
+		},
+		client.tlsConfig,
 		websocketEventChannel,
 		ctx,
+		func() {
+			log.Info().Msgf("reconnected to solver events: %s", client.getOptions().URL)
+			websocketReconnectsTotal.WithLabelValues(client.getOptions().URL).Inc()
+			client.notifyConnection(true)
+		},
+		func() {
+			client.notifyConnection(false)
+		},
 	)
+	// ConnectWebSocket only returns once it has a connection unless we were cancelled
+	if ctx.Err() == nil {
+		client.notifyConnection(true)
+	}
+	go func() {
+		<-eventsStopped
+		<-websocketStopped
+		close(stopped)
+	}()
 	return nil
 }
 
@@ -61,6 +150,18 @@ func (client *SolverClient) SubscribeEvents(handler func(SolverEvent)) {
 	client.solverEventSubs = append(client.solverEventSubs, handler)
 }
 
+// handler is called with true once the event websocket is connected and with
+// false whenever it drops - like SubscribeEvents this must be called before Start
+func (client *SolverClient) SubscribeConnection(handler func(connected bool)) {
+	client.connectionSubs = append(client.connectionSubs, handler)
+}
+
+func (client *SolverClient) notifyConnection(connected bool) {
+	for _, handler := range client.connectionSubs {
+		handler(connected)
+	}
+}
+
 func (client *SolverClient) GetJobOffers(query store.GetJobOffersQuery) ([]data.JobOfferContainer, error) {
 	queryParams := map[string]string{}
 	if query.JobCreator != "" {
@@ -69,7 +170,7 @@ func (client *SolverClient) GetJobOffers(query store.GetJobOffersQuery) ([]data.
 	if query.NotMatched {
 		queryParams["not_matched"] = "true"
 	}
-	return http.GetRequest[[]data.JobOfferContainer](client.options, "/job_offers", queryParams)
+	return wrapClientResult(http.GetRequest[[]data.JobOfferContainer](client.getOptions(), "/job_offers", queryParams))
 }
 
 func (client *SolverClient) GetResourceOffers(query store.GetResourceOffersQuery) ([]data.ResourceOfferContainer, error) {
@@ -83,9 +184,12 @@ func (client *SolverClient) GetResourceOffers(query store.GetResourceOffersQuery
 	if query.NotMatched {
 		queryParams["not_matched"] = "true"
 	}
-	return http.GetRequest[[]data.ResourceOfferContainer](client.options, "/resource_offers", queryParams)
+	addPageParams(queryParams, query.Limit, query.Offset)
+	return wrapClientResult(http.GetRequest[[]data.ResourceOfferContainer](client.getOptions(), "/resource_offers", queryParams))
 }
 
+// the states are sent as a repeated state param
+// a solver from before that was supported only looks at the first one
 func (client *SolverClient) GetDeals(query store.GetDealsQuery) ([]data.DealContainer, error) {
 	queryParams := map[string]string{}
 	if query.JobCreator != "" {
@@ -94,56 +198,107 @@ func (client *SolverClient) GetDeals(query store.GetDealsQuery) ([]data.DealCont
 	if query.ResourceProvider != "" {
 		queryParams["resource_provider"] = query.ResourceProvider
 	}
-	if query.State != "" {
-		queryParams["state"] = query.State
+	addPageParams(queryParams, query.Limit, query.Offset)
+	queryValues := http.ToQueryValues(queryParams)
+	for _, state := range query.GetStates() {
+		queryValues.Add("state", state)
+	}
+	return wrapClientResult(http.GetRequestWithValues[[]data.DealContainer](client.getOptions(), "/deals", queryValues))
+}
+
+// GetAllDeals pages through the deals matching query and calls handler with each page
+// so we never hold every deal on a large solver in memory at once
+// query.Limit is used as the page size, DEALS_PAGE_SIZE if it is not set
+func (client *SolverClient) GetAllDeals(query store.GetDealsQuery, handler func([]data.DealContainer) error) error {
+	if query.Limit <= 0 {
+		query.Limit = DEALS_PAGE_SIZE
+	}
+	for {
+		deals, err := client.GetDeals(query)
+		if err != nil {
+			return err
+		}
+		if len(deals) > 0 {
+			err = handler(deals)
+			if err != nil {
+				return err
+			}
+		}
+		// a short page is the last one - a solver that doesn't know
+		// about paging sends everything in one go which is also the last page
+		if len(deals) != query.Limit {
+			return nil
+		}
+		query.Offset += len(deals)
+	}
+}
+
+func addPageParams(queryParams map[string]string, limit int, offset int) {
+	if limit > 0 {
+		queryParams["limit"] = strconv.Itoa(limit)
+	}
+	if offset > 0 {
+		queryParams["offset"] = strconv.Itoa(offset)
 	}
-	return http.GetRequest[[]data.DealContainer](client.options, "/deals", queryParams)
 }
 
 func (client *SolverClient) GetDeal(id string) (data.DealContainer, error) {
-	return http.GetRequest[data.DealContainer](client.options, fmt.Sprintf("/deals/%s", id), map[string]string{})
+	return wrapClientResult(http.GetRequest[data.DealContainer](client.getOptions(), fmt.Sprintf("/deals/%s", id), map[string]string{}))
 }
 
 func (client *SolverClient) GetResult(id string) (data.Result, error) {
-	return http.GetRequest[data.Result](client.options, fmt.Sprintf("/deals/%s/result", id), map[string]string{})
+	return wrapClientResult(http.GetRequest[data.Result](client.getOptions(), fmt.Sprintf("/deals/%s/result", id), map[string]string{}))
 }
 
 func (client *SolverClient) GetDealsWithFilter(query store.GetDealsQuery, filter func(data.DealContainer) bool) ([]data.DealContainer, error) {
-	deals, err := client.GetDeals(query)
-	if err != nil {
-		return nil, err
-	}
 	ret := []data.DealContainer{}
-	for _, deal := range deals {
-		if filter(deal) {
-			ret = append(ret, deal)
+	err := client.GetAllDeals(query, func(deals []data.DealContainer) error {
+		for _, deal := range deals {
+			if filter(deal) {
+				ret = append(ret, deal)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return ret, nil
 }
 
 func (client *SolverClient) AddJobOffer(jobOffer data.JobOffer) (data.JobOfferContainer, error) {
-	return http.PostRequest[data.JobOffer, data.JobOfferContainer](client.options, "/job_offers", jobOffer)
+	return wrapClientResult(http.PostRequest[data.JobOffer, data.JobOfferContainer](client.getOptions(), "/job_offers", jobOffer))
 }
 
 func (client *SolverClient) AddResourceOffer(resourceOffer data.ResourceOffer) (data.ResourceOfferContainer, error) {
-	return http.PostRequest[data.ResourceOffer, data.ResourceOfferContainer](client.options, "/resource_offers", resourceOffer)
+	return wrapClientResult(http.PostRequest[data.ResourceOffer, data.ResourceOfferContainer](client.getOptions(), "/resource_offers", resourceOffer))
+}
+
+// post all the offers in one signed request
+// the results are in the same order as the offers so one bad offer doesn't fail the rest
+// solvers from before the batch endpoint return ErrNotSupported
+func (client *SolverClient) AddResourceOffers(resourceOffers []data.ResourceOffer) ([]AddResourceOfferResult, error) {
+	return wrapClientResult(http.PostRequest[[]data.ResourceOffer, []AddResourceOfferResult](client.getOptions(), "/resource_offers/batch", resourceOffers))
+}
+
+func (client *SolverClient) RemoveResourceOffer(id string) (data.ResourceOfferContainer, error) {
+	return wrapClientResult(http.DeleteRequest[data.ResourceOfferContainer](client.getOptions(), fmt.Sprintf("/resource_offers/%s", id)))
 }
 
 func (client *SolverClient) AddResult(result data.Result) (data.Result, error) {
-	return http.PostRequest[data.Result, data.Result](client.options, fmt.Sprintf("/deals/%s/result", result.DealID), result)
+	return wrapClientResult(http.PostRequest[data.Result, data.Result](client.getOptions(), fmt.Sprintf("/deals/%s/result", result.DealID), result))
 }
 
 func (client *SolverClient) UpdateTransactionsResourceProvider(id string, payload data.DealTransactionsResourceProvider) (data.DealContainer, error) {
-	return http.PostRequest[data.DealTransactionsResourceProvider, data.DealContainer](client.options, fmt.Sprintf("/deals/%s/txs/resource_provider", id), payload)
+	return wrapClientResult(http.PostRequest[data.DealTransactionsResourceProvider, data.DealContainer](client.getOptions(), fmt.Sprintf("/deals/%s/txs/resource_provider", id), payload))
 }
 
 func (client *SolverClient) UpdateTransactionsJobCreator(id string, payload data.DealTransactionsJobCreator) (data.DealContainer, error) {
-	return http.PostRequest[data.DealTransactionsJobCreator, data.DealContainer](client.options, fmt.Sprintf("/deals/%s/txs/job_creator", id), payload)
+	return wrapClientResult(http.PostRequest[data.DealTransactionsJobCreator, data.DealContainer](client.getOptions(), fmt.Sprintf("/deals/%s/txs/job_creator", id), payload))
 }
 
 func (client *SolverClient) UpdateTransactionsMediator(id string, payload data.DealTransactionsMediator) (data.DealContainer, error) {
-	return http.PostRequest[data.DealTransactionsMediator, data.DealContainer](client.options, fmt.Sprintf("/deals/%s/txs/mediator", id), payload)
+	return wrapClientResult(http.PostRequest[data.DealTransactionsMediator, data.DealContainer](client.getOptions(), fmt.Sprintf("/deals/%s/txs/mediator", id), payload))
 }
 
 func (client *SolverClient) UploadResultFiles(id string, localPath string) (data.Result, error) {
@@ -151,13 +306,13 @@ func (client *SolverClient) UploadResultFiles(id string, localPath string) (data
 	if err != nil {
 		return data.Result{}, err
 	}
-	return http.PostRequestBuffer[data.Result](client.options, fmt.Sprintf("/deals/%s/files", id), buf)
+	return wrapClientResult(http.PostRequestBuffer[data.Result](client.getOptions(), fmt.Sprintf("/deals/%s/files", id), buf))
 }
 
 func (client *SolverClient) DownloadResultFiles(id string, localPath string) error {
-	buf, err := http.GetRequestBuffer(client.options, fmt.Sprintf("/deals/%s/files", id), map[string]string{})
+	buf, err := http.GetRequestBuffer(client.getOptions(), fmt.Sprintf("/deals/%s/files", id), map[string]string{})
 	if err != nil {
-		return err
+		return wrapClientError(err)
 	}
 	return system.ExpandTarBuffer(buf, localPath)
 }
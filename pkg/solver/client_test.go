@@ -0,0 +1,172 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	corehttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/http"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+	memorystore "github.com/bacalhau-project/lilypad/pkg/solver/store/memory"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/bacalhau-project/lilypad/pkg/web3"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestGetAllDeals(t *testing.T) {
+	solverStore, err := memorystore.NewSolverStoreMemory()
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err := solverStore.AddDeal(data.DealContainer{ID: fmt.Sprintf("deal-%d", i)})
+		assert.NoError(t, err)
+	}
+
+	server := &solverServer{store: solverStore}
+	router := mux.NewRouter()
+	router.PathPrefix(http.API_SUB_PATH).Subrouter().HandleFunc("/deals", http.GetHandler(server.getDeals)).Methods("GET")
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	client, err := NewSolverClient(http.ClientOptions{URL: testServer.URL})
+	assert.NoError(t, err)
+
+	pages := [][]string{}
+	err = client.GetAllDeals(store.GetDealsQuery{Limit: 2}, func(deals []data.DealContainer) error {
+		page := []string{}
+		for _, deal := range deals {
+			page = append(page, deal.ID)
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"deal-0", "deal-1"},
+		{"deal-2", "deal-3"},
+		{"deal-4"},
+	}, pages, "Every deal should be handled once in id order")
+}
+
+func TestAddResourceOffers(t *testing.T) {
+	solverStore, err := memorystore.NewSolverStoreMemory()
+	assert.NoError(t, err)
+	controller := &SolverController{
+		store: solverStore,
+		log:   system.NewServiceLogger(system.SolverService),
+	}
+	controller.loop = system.NewControlLoop(system.SolverService, context.Background(), time.Minute, func() error { return nil })
+	server := &solverServer{store: solverStore, controller: controller}
+	router := mux.NewRouter()
+	router.PathPrefix(http.API_SUB_PATH).Subrouter().HandleFunc("/resource_offers/batch", http.PostHandler(server.addResourceOffers)).Methods("POST")
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	signer := web3.NewPrivateKeySigner(privateKey)
+	client, err := NewSolverClient(http.ClientOptions{URL: testServer.URL, Signer: signer})
+	assert.NoError(t, err)
+
+	getOffer := func(index int) data.ResourceOffer {
+		return data.ResourceOffer{
+			ResourceProvider: signer.GetAddress().String(),
+			Index:            index,
+			Services: data.ServiceConfig{
+				Solver:   "0xsolver",
+				Mediator: []string{"0xmediator"},
+			},
+		}
+	}
+	noSolver := getOffer(1)
+	noSolver.Services.Solver = ""
+	results, err := client.AddResourceOffers([]data.ResourceOffer{getOffer(0), noSolver, getOffer(2)})
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.NotNil(t, results[0].Offer)
+	assert.Nil(t, results[1].Offer, "A bad offer should be rejected on its own")
+	assert.NotEmpty(t, results[1].Error)
+	assert.NotNil(t, results[2].Offer, "Offers after a bad one should still be added")
+
+	offers, err := solverStore.GetResourceOffers(store.GetResourceOffersQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, offers, 2)
+}
+
+func TestGetDealsWithStates(t *testing.T) {
+	solverStore, err := memorystore.NewSolverStoreMemory()
+	assert.NoError(t, err)
+	for _, state := range []string{"DealNegotiating", "DealAgreed", "ResultsSubmitted"} {
+		_, err := solverStore.AddDeal(data.DealContainer{ID: state, State: data.GetAgreementStateIndex(state)})
+		assert.NoError(t, err)
+	}
+
+	server := &solverServer{store: solverStore}
+	router := mux.NewRouter()
+	router.PathPrefix(http.API_SUB_PATH).Subrouter().HandleFunc("/deals", http.GetHandler(server.getDeals)).Methods("GET")
+	testServer := httptest.NewServer(router)
+	defer testServer.Close()
+
+	client, err := NewSolverClient(http.ClientOptions{URL: testServer.URL})
+	assert.NoError(t, err)
+
+	getIDs := func(query store.GetDealsQuery) []string {
+		deals, err := client.GetDeals(query)
+		assert.NoError(t, err)
+		ids := []string{}
+		for _, deal := range deals {
+			ids = append(ids, deal.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"DealAgreed"}, getIDs(store.GetDealsQuery{State: "DealAgreed"}), "A single state should still work")
+	assert.Equal(t, []string{"DealAgreed", "DealNegotiating"}, getIDs(store.GetDealsQuery{
+		States: []string{"DealNegotiating", "DealAgreed"},
+	}), "Deals in any of the states should be returned")
+	assert.Equal(t, []string{"DealAgreed", "ResultsSubmitted"}, getIDs(store.GetDealsQuery{
+		State:  "ResultsSubmitted",
+		States: []string{"DealAgreed"},
+	}))
+}
+
+func TestStartStopsOnCleanup(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	upgrader := websocket.Upgrader{}
+	connected := make(chan struct{}, 1)
+	testServer := httptest.NewServer(corehttp.HandlerFunc(func(w corehttp.ResponseWriter, r *corehttp.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connected <- struct{}{}
+		// hold the connection open until the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer testServer.Close()
+
+	client, err := NewSolverClient(http.ClientOptions{URL: testServer.URL})
+	assert.NoError(t, err)
+	cm := system.NewCleanupManager()
+	assert.NoError(t, client.Start(context.Background(), cm))
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The client never connected")
+	}
+
+	// the context is still live so cleaning up has to stop the client by itself
+	cm.Cleanup(context.Background())
+}
@@ -14,29 +14,6 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// add an enum for various types of event
-type SolverEventType string
-
-const (
-	JobOfferAdded                       SolverEventType = "JobOfferAdded"
-	ResourceOfferAdded                  SolverEventType = "ResourceOfferAdded"
-	DealAdded                           SolverEventType = "DealAdded"
-	JobOfferStateUpdated                SolverEventType = "JobOfferStateUpdated"
-	ResourceOfferStateUpdated           SolverEventType = "ResourceOfferStateUpdated"
-	DealStateUpdated                    SolverEventType = "DealStateUpdated"
-	DealMediatorUpdated                 SolverEventType = "DealMediatorUpdated"
-	ResourceProviderTransactionsUpdated SolverEventType = "ResourceProviderTransactionsUpdated"
-	JobCreatorTransactionsUpdated       SolverEventType = "JobCreatorTransactionsUpdated"
-	MediatorTransactionsUpdated         SolverEventType = "MediatorTransactionsUpdated"
-)
-
-type SolverEvent struct {
-	EventType     SolverEventType              `json:"event_type"`
-	JobOffer      *data.JobOfferContainer      `json:"job_offer"`
-	ResourceOffer *data.ResourceOfferContainer `json:"resource_offer"`
-	Deal          *data.DealContainer          `json:"deal"`
-}
-
 type SolverController struct {
 	web3SDK         *web3.Web3SDK
 	web3Events      *web3.EventChannels
@@ -332,6 +309,20 @@ func (controller *SolverController) addResourceOffer(resourceOffer data.Resource
 	return ret, nil
 }
 
+func (controller *SolverController) removeResourceOffer(resourceOffer data.ResourceOfferContainer) (*data.ResourceOfferContainer, error) {
+	controller.log.Info("remove resource offer", resourceOffer.ID)
+
+	err := controller.store.RemoveResourceOffer(resourceOffer.ID)
+	if err != nil {
+		return nil, err
+	}
+	controller.writeEvent(SolverEvent{
+		EventType:     ResourceOfferRemoved,
+		ResourceOffer: &resourceOffer,
+	})
+	return &resourceOffer, nil
+}
+
 func (controller *SolverController) addDeal(deal data.Deal) (*data.DealContainer, error) {
 	id, err := data.GetDealID(deal)
 	if err != nil {
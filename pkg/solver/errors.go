@@ -0,0 +1,57 @@
+package solver
+
+import (
+	"errors"
+	"fmt"
+	corehttp "net/http"
+
+	"github.com/bacalhau-project/lilypad/pkg/http"
+)
+
+// errors returned by the SolverClient wrap one of these
+// so callers can decide whether it's worth trying again
+var (
+	// we could not get a response from the solver or it had an internal error
+	// this is usually worth retrying
+	ErrSolverUnavailable = errors.New("solver unavailable")
+	// the solver didn't like what we sent it - retrying won't help
+	ErrBadRequest = errors.New("solver rejected request")
+	// the thing we asked for doesn't exist on the solver
+	ErrNotFound = errors.New("not found on solver")
+	// the solver told us to slow down (429 or 503)
+	// a 503 also wraps ErrSolverUnavailable
+	ErrSolverOverloaded = errors.New("solver overloaded")
+	// the solver doesn't have the endpoint we called (405)
+	// i.e. it is older than us so fall back to what it does have
+	ErrNotSupported = errors.New("not supported by solver")
+)
+
+// wrap an error from the http helpers in one of the sentinels above
+func wrapClientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var httpError http.HTTPError
+	if !errors.As(err, &httpError) {
+		// no usable response (connection refused, timeouts, retries exhausted)
+		return fmt.Errorf("%w: %w", ErrSolverUnavailable, err)
+	}
+	switch {
+	case httpError.StatusCode == corehttp.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w", ErrSolverOverloaded, err)
+	case httpError.StatusCode == corehttp.StatusServiceUnavailable:
+		return fmt.Errorf("%w: %w: %w", ErrSolverOverloaded, ErrSolverUnavailable, err)
+	case httpError.StatusCode == corehttp.StatusNotFound:
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case httpError.StatusCode == corehttp.StatusMethodNotAllowed:
+		return fmt.Errorf("%w: %w", ErrNotSupported, err)
+	case httpError.StatusCode >= corehttp.StatusInternalServerError:
+		return fmt.Errorf("%w: %w", ErrSolverUnavailable, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrBadRequest, err)
+	}
+}
+
+func wrapClientResult[T any](result T, err error) (T, error) {
+	return result, wrapClientError(err)
+}
@@ -0,0 +1,25 @@
+package solver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapClientError(t *testing.T) {
+	assert.Nil(t, wrapClientError(nil))
+	assert.ErrorIs(t, wrapClientError(errors.New("connection refused")), ErrSolverUnavailable)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 500}), ErrSolverUnavailable)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 404}), ErrNotFound)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 400}), ErrBadRequest)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 405}), ErrNotSupported)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 429}), ErrSolverOverloaded)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 503}), ErrSolverOverloaded)
+	assert.ErrorIs(t, wrapClientError(http.HTTPError{StatusCode: 503}), ErrSolverUnavailable)
+
+	var httpError http.HTTPError
+	assert.True(t, errors.As(wrapClientError(http.HTTPError{StatusCode: 409}), &httpError), "The original HTTPError should still be available")
+	assert.Equal(t, 409, httpError.StatusCode)
+}
@@ -0,0 +1,98 @@
+package solver
+
+import (
+	"fmt"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+)
+
+// what changed in the solver, sent to everyone subscribed to its events
+// each type says which of the SolverEvent fields is set - the others are nil
+type SolverEventType string
+
+const (
+	// JobOffer is set
+	JobOfferAdded        SolverEventType = "JobOfferAdded"
+	JobOfferStateUpdated SolverEventType = "JobOfferStateUpdated"
+
+	// ResourceOffer is set
+	ResourceOfferAdded        SolverEventType = "ResourceOfferAdded"
+	ResourceOfferRemoved      SolverEventType = "ResourceOfferRemoved"
+	ResourceOfferStateUpdated SolverEventType = "ResourceOfferStateUpdated"
+
+	// Deal is set
+	DealAdded                           SolverEventType = "DealAdded"
+	DealStateUpdated                    SolverEventType = "DealStateUpdated"
+	DealMediatorUpdated                 SolverEventType = "DealMediatorUpdated"
+	ResourceProviderTransactionsUpdated SolverEventType = "ResourceProviderTransactionsUpdated"
+	JobCreatorTransactionsUpdated       SolverEventType = "JobCreatorTransactionsUpdated"
+	MediatorTransactionsUpdated         SolverEventType = "MediatorTransactionsUpdated"
+)
+
+// every event type the solver sends
+var SolverEventTypes = []SolverEventType{
+	JobOfferAdded,
+	JobOfferStateUpdated,
+	ResourceOfferAdded,
+	ResourceOfferRemoved,
+	ResourceOfferStateUpdated,
+	DealAdded,
+	DealStateUpdated,
+	DealMediatorUpdated,
+	ResourceProviderTransactionsUpdated,
+	JobCreatorTransactionsUpdated,
+	MediatorTransactionsUpdated,
+}
+
+func (eventType SolverEventType) String() string {
+	if eventType == "" {
+		return "Unknown"
+	}
+	return string(eventType)
+}
+
+// true for the event types that carry a job offer, resource offer or deal
+func (eventType SolverEventType) HasJobOffer() bool {
+	switch eventType {
+	case JobOfferAdded, JobOfferStateUpdated:
+		return true
+	}
+	return false
+}
+
+func (eventType SolverEventType) HasResourceOffer() bool {
+	switch eventType {
+	case ResourceOfferAdded, ResourceOfferRemoved, ResourceOfferStateUpdated:
+		return true
+	}
+	return false
+}
+
+func (eventType SolverEventType) HasDeal() bool {
+	switch eventType {
+	case DealAdded, DealStateUpdated, DealMediatorUpdated,
+		ResourceProviderTransactionsUpdated, JobCreatorTransactionsUpdated, MediatorTransactionsUpdated:
+		return true
+	}
+	return false
+}
+
+type SolverEvent struct {
+	EventType     SolverEventType              `json:"event_type"`
+	JobOffer      *data.JobOfferContainer      `json:"job_offer"`
+	ResourceOffer *data.ResourceOfferContainer `json:"resource_offer"`
+	Deal          *data.DealContainer          `json:"deal"`
+}
+
+// the event type and the id of whatever it is about e.g. "DealAdded deal=<id>"
+func (ev SolverEvent) String() string {
+	switch {
+	case ev.Deal != nil:
+		return fmt.Sprintf("%s deal=%s", ev.EventType, ev.Deal.ID)
+	case ev.ResourceOffer != nil:
+		return fmt.Sprintf("%s resource_offer=%s", ev.EventType, ev.ResourceOffer.ID)
+	case ev.JobOffer != nil:
+		return fmt.Sprintf("%s job_offer=%s", ev.EventType, ev.JobOffer.ID)
+	}
+	return ev.EventType.String()
+}
@@ -0,0 +1,30 @@
+package solver
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSolverEventTypes(t *testing.T) {
+	for _, eventType := range SolverEventTypes {
+		carries := 0
+		for _, has := range []bool{eventType.HasJobOffer(), eventType.HasResourceOffer(), eventType.HasDeal()} {
+			if has {
+				carries++
+			}
+		}
+		assert.Equal(t, 1, carries, "%s should carry exactly one of a job offer, resource offer or deal", eventType)
+	}
+	assert.Equal(t, "DealAdded", DealAdded.String())
+	assert.Equal(t, "Unknown", SolverEventType("").String())
+}
+
+func TestSolverEventString(t *testing.T) {
+	ev := SolverEvent{EventType: DealAdded, Deal: &data.DealContainer{ID: "deal1"}}
+	assert.Equal(t, "DealAdded deal=deal1", ev.String())
+	ev = SolverEvent{EventType: ResourceOfferRemoved, ResourceOffer: &data.ResourceOfferContainer{ID: "offer1"}}
+	assert.Equal(t, "ResourceOfferRemoved resource_offer=offer1", ev.String())
+	assert.Equal(t, "JobOfferAdded", SolverEvent{EventType: JobOfferAdded}.String())
+}
@@ -0,0 +1,39 @@
+package solver
+
+import (
+	"context"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+)
+
+// what a resource provider needs from a solver
+// this lets tests swap in the in-memory client from solver/mock
+type SolverClientInterface interface {
+	Start(ctx context.Context, cm *system.CleanupManager) error
+	SubscribeEvents(handler func(SolverEvent))
+	SubscribeConnection(handler func(connected bool))
+	GetURL() string
+	SetURL(url string)
+	WithContext(ctx context.Context) SolverClientInterface
+	GetResourceOffers(query store.GetResourceOffersQuery) ([]data.ResourceOfferContainer, error)
+	AddResourceOffer(resourceOffer data.ResourceOffer) (data.ResourceOfferContainer, error)
+	AddResourceOffers(resourceOffers []data.ResourceOffer) ([]AddResourceOfferResult, error)
+	RemoveResourceOffer(id string) (data.ResourceOfferContainer, error)
+	GetDeals(query store.GetDealsQuery) ([]data.DealContainer, error)
+	GetDeal(id string) (data.DealContainer, error)
+	GetDealsWithFilter(query store.GetDealsQuery, filter func(data.DealContainer) bool) ([]data.DealContainer, error)
+	UpdateTransactionsResourceProvider(id string, payload data.DealTransactionsResourceProvider) (data.DealContainer, error)
+	AddResult(result data.Result) (data.Result, error)
+	UploadResultFiles(id string, localPath string) (data.Result, error)
+}
+
+var _ SolverClientInterface = (*SolverClient)(nil)
+
+// what happened to one offer in an AddResourceOffers batch
+// Offer is set if it was added and Error if it wasn't
+type AddResourceOfferResult struct {
+	Offer *data.ResourceOfferContainer `json:"offer,omitempty"`
+	Error string                       `json:"error,omitempty"`
+}
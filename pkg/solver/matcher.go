@@ -43,6 +43,36 @@ func doOffersMatch(
 			Msgf("did not match GPU")
 		return false
 	}
+	if resourceOffer.Spec.VRAM < jobOffer.Spec.VRAM {
+		log.Trace().
+			Str("resource offer", resourceOffer.ID).
+			Str("job offer", jobOffer.ID).
+			Uint64("resource VRAM", resourceOffer.Spec.VRAM).
+			Uint64("job VRAM", jobOffer.Spec.VRAM).
+			Msgf("did not match VRAM")
+		return false
+	}
+	if jobOffer.Spec.GPUModel != "" && !strings.EqualFold(resourceOffer.Spec.GPUModel, jobOffer.Spec.GPUModel) {
+		log.Trace().
+			Str("resource offer", resourceOffer.ID).
+			Str("job offer", jobOffer.ID).
+			Str("resource GPU model", resourceOffer.Spec.GPUModel).
+			Str("job GPU model", jobOffer.Spec.GPUModel).
+			Msgf("did not match GPU model")
+		return false
+	}
+	for label, value := range jobOffer.Spec.Labels {
+		if resourceValue, ok := resourceOffer.Spec.Labels[label]; !ok || resourceValue != value {
+			log.Trace().
+				Str("resource offer", resourceOffer.ID).
+				Str("job offer", jobOffer.ID).
+				Str("label", label).
+				Str("resource value", resourceValue).
+				Str("job value", value).
+				Msgf("did not match label")
+			return false
+		}
+	}
 	if resourceOffer.Spec.RAM < jobOffer.Spec.RAM {
 		log.Trace().
 			Str("resource offer", resourceOffer.ID).
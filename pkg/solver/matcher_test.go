@@ -62,6 +62,43 @@ func TestDoOffersMatch(t *testing.T) {
 			},
 			shouldMatch: false,
 		},
+		{
+			name: "VRAM mis-match",
+			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
+				offer.Spec.VRAM = 16384
+				return offer
+			},
+			jobOffer: func(offer data.JobOffer) data.JobOffer {
+				offer.Spec.VRAM = 40960
+				return offer
+			},
+			shouldMatch: false,
+		},
+		{
+			name: "GPU model mis-match",
+			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
+				offer.Spec.GPUModel = "NVIDIA T4"
+				return offer
+			},
+			jobOffer: func(offer data.JobOffer) data.JobOffer {
+				offer.Spec.GPUModel = "NVIDIA A100"
+				return offer
+			},
+			shouldMatch: false,
+		},
+		{
+			name: "Any GPU model",
+			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
+				offer.Spec.GPUModel = "NVIDIA T4"
+				offer.Spec.VRAM = 16384
+				return offer
+			},
+			jobOffer: func(offer data.JobOffer) data.JobOffer {
+				offer.Spec.VRAM = 8192
+				return offer
+			},
+			shouldMatch: true,
+		},
 		{
 			name: "Empty mediators",
 			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
@@ -107,6 +144,30 @@ func TestDoOffersMatch(t *testing.T) {
 			},
 			shouldMatch: false,
 		},
+		{
+			name: "Labels match",
+			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
+				offer.Spec.Labels = map[string]string{"cuda": "12", "region": "eu"}
+				return offer
+			},
+			jobOffer: func(offer data.JobOffer) data.JobOffer {
+				offer.Spec.Labels = map[string]string{"cuda": "12"}
+				return offer
+			},
+			shouldMatch: true,
+		},
+		{
+			name: "Labels mismatch",
+			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
+				offer.Spec.Labels = map[string]string{"cuda": "11"}
+				return offer
+			},
+			jobOffer: func(offer data.JobOffer) data.JobOffer {
+				offer.Spec.Labels = map[string]string{"cuda": "12"}
+				return offer
+			},
+			shouldMatch: false,
+		},
 		{
 			name: "Fixed price - too expensive",
 			resourceOffer: func(offer data.ResourceOffer) data.ResourceOffer {
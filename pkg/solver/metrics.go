@@ -0,0 +1,16 @@
+package solver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const CLIENT_METRICS_NAMESPACE = "lilypad_solver_client"
+
+var (
+	websocketReconnectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: CLIENT_METRICS_NAMESPACE,
+		Name:      "websocket_reconnects_total",
+		Help:      "The number of times we have reconnected the event websocket to a solver.",
+	}, []string{"url"})
+)
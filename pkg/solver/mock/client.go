@@ -0,0 +1,302 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/solver"
+	"github.com/bacalhau-project/lilypad/pkg/solver/store"
+	memorystore "github.com/bacalhau-project/lilypad/pkg/solver/store/memory"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+)
+
+// an in-memory stand in for solver.SolverClient
+// it keeps offers and deals in the same memory store the solver uses
+// so queries filter the same way they would against a real solver
+type SolverClient struct {
+	mutex    sync.Mutex
+	url      string
+	store    *memorystore.SolverStoreMemory
+	handlers []func(solver.SolverEvent)
+	// told when Start succeeds and by SetConnected
+	connectionHandlers []func(connected bool)
+	// if set every request fails with this error
+	err error
+	// if set offers it returns an error for are rejected one at a time
+	offerErr func(data.ResourceOffer) error
+	// pretend to be a solver from before AddResourceOffers
+	batchUnsupported bool
+	batchCalls       int
+}
+
+var _ solver.SolverClientInterface = (*SolverClient)(nil)
+
+func NewSolverClient(url string) (*SolverClient, error) {
+	store, err := memorystore.NewSolverStoreMemory()
+	if err != nil {
+		return nil, err
+	}
+	return &SolverClient{
+		url:      url,
+		store:    store,
+		handlers: []func(solver.SolverEvent){},
+	}, nil
+}
+
+// make every request fail with err until it is called again with nil
+// e.g. solver.ErrSolverUnavailable to pretend the solver is down
+func (client *SolverClient) SetError(err error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.err = err
+}
+
+// reject single offers e.g. to check a batch with some bad offers is handled
+func (client *SolverClient) SetOfferError(offerErr func(data.ResourceOffer) error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.offerErr = offerErr
+}
+
+// make AddResourceOffers return solver.ErrNotSupported like an old solver would
+func (client *SolverClient) SetBatchUnsupported(unsupported bool) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.batchUnsupported = unsupported
+}
+
+// how many times AddResourceOffers has been called
+func (client *SolverClient) GetBatchCalls() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.batchCalls
+}
+
+func (client *SolverClient) getOfferError(resourceOffer data.ResourceOffer) error {
+	client.mutex.Lock()
+	offerErr := client.offerErr
+	client.mutex.Unlock()
+	if offerErr == nil {
+		return nil
+	}
+	return offerErr(resourceOffer)
+}
+
+func (client *SolverClient) getError() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.err
+}
+
+// send an event to everyone subscribed as if the solver had written it
+// handlers are called in order before this returns
+func (client *SolverClient) Emit(ev solver.SolverEvent) {
+	client.mutex.Lock()
+	handlers := append([]func(solver.SolverEvent){}, client.handlers...)
+	client.mutex.Unlock()
+	for _, handler := range handlers {
+		handler(ev)
+	}
+}
+
+// tell everyone subscribed that the event websocket connected or dropped
+func (client *SolverClient) SetConnected(connected bool) {
+	client.mutex.Lock()
+	handlers := append([]func(bool){}, client.connectionHandlers...)
+	client.mutex.Unlock()
+	for _, handler := range handlers {
+		handler(connected)
+	}
+}
+
+// put a deal on the solver as if it had been matched
+func (client *SolverClient) AddDeal(deal data.DealContainer) error {
+	_, err := client.store.AddDeal(deal)
+	return err
+}
+
+// mark an offer as matched to a deal as if the solver had matched it
+func (client *SolverClient) MatchResourceOffer(id string, dealID string) error {
+	_, err := client.store.UpdateResourceOfferState(id, dealID, data.GetDefaultAgreementState())
+	return err
+}
+
+func (client *SolverClient) Start(ctx context.Context, cm *system.CleanupManager) error {
+	err := client.getError()
+	if err != nil {
+		return err
+	}
+	client.SetConnected(true)
+	return nil
+}
+
+func (client *SolverClient) SubscribeEvents(handler func(solver.SolverEvent)) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.handlers = append(client.handlers, handler)
+}
+
+func (client *SolverClient) SubscribeConnection(handler func(connected bool)) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.connectionHandlers = append(client.connectionHandlers, handler)
+}
+
+func (client *SolverClient) GetURL() string {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.url
+}
+
+func (client *SolverClient) SetURL(url string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.url = url
+}
+
+// there are no requests to cancel or trace so this is the same client
+func (client *SolverClient) WithContext(ctx context.Context) solver.SolverClientInterface {
+	return client
+}
+
+func (client *SolverClient) GetResourceOffers(query store.GetResourceOffersQuery) ([]data.ResourceOfferContainer, error) {
+	if err := client.getError(); err != nil {
+		return nil, err
+	}
+	return client.store.GetResourceOffers(query)
+}
+
+func (client *SolverClient) AddResourceOffer(resourceOffer data.ResourceOffer) (data.ResourceOfferContainer, error) {
+	if err := client.getError(); err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	if err := client.getOfferError(resourceOffer); err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	id, err := data.GetResourceOfferID(resourceOffer)
+	if err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	resourceOffer.ID = id
+	ret, err := client.store.AddResourceOffer(data.GetResourceOfferContainer(resourceOffer))
+	if err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	client.Emit(solver.SolverEvent{
+		EventType:     solver.ResourceOfferAdded,
+		ResourceOffer: ret,
+	})
+	return *ret, nil
+}
+
+func (client *SolverClient) AddResourceOffers(resourceOffers []data.ResourceOffer) ([]solver.AddResourceOfferResult, error) {
+	client.mutex.Lock()
+	client.batchCalls++
+	unsupported := client.batchUnsupported
+	client.mutex.Unlock()
+	if unsupported {
+		return nil, solver.ErrNotSupported
+	}
+	if err := client.getError(); err != nil {
+		return nil, err
+	}
+	results := []solver.AddResourceOfferResult{}
+	for _, resourceOffer := range resourceOffers {
+		added, err := client.AddResourceOffer(resourceOffer)
+		if err != nil {
+			results = append(results, solver.AddResourceOfferResult{Error: err.Error()})
+			continue
+		}
+		results = append(results, solver.AddResourceOfferResult{Offer: &added})
+	}
+	return results, nil
+}
+
+func (client *SolverClient) RemoveResourceOffer(id string) (data.ResourceOfferContainer, error) {
+	if err := client.getError(); err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	resourceOffer, err := client.store.GetResourceOffer(id)
+	if err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	if resourceOffer == nil {
+		return data.ResourceOfferContainer{}, fmt.Errorf("%w: resource offer %s", solver.ErrNotFound, id)
+	}
+	err = client.store.RemoveResourceOffer(id)
+	if err != nil {
+		return data.ResourceOfferContainer{}, err
+	}
+	client.Emit(solver.SolverEvent{
+		EventType:     solver.ResourceOfferRemoved,
+		ResourceOffer: resourceOffer,
+	})
+	return *resourceOffer, nil
+}
+
+func (client *SolverClient) GetDeals(query store.GetDealsQuery) ([]data.DealContainer, error) {
+	if err := client.getError(); err != nil {
+		return nil, err
+	}
+	return client.store.GetDeals(query)
+}
+
+func (client *SolverClient) GetDeal(id string) (data.DealContainer, error) {
+	if err := client.getError(); err != nil {
+		return data.DealContainer{}, err
+	}
+	deal, err := client.store.GetDeal(id)
+	if err != nil {
+		return data.DealContainer{}, err
+	}
+	if deal == nil {
+		return data.DealContainer{}, fmt.Errorf("%w: deal %s", solver.ErrNotFound, id)
+	}
+	return *deal, nil
+}
+
+func (client *SolverClient) GetDealsWithFilter(query store.GetDealsQuery, filter func(data.DealContainer) bool) ([]data.DealContainer, error) {
+	deals, err := client.GetDeals(query)
+	if err != nil {
+		return nil, err
+	}
+	ret := []data.DealContainer{}
+	for _, deal := range deals {
+		if filter(deal) {
+			ret = append(ret, deal)
+		}
+	}
+	return ret, nil
+}
+
+func (client *SolverClient) UpdateTransactionsResourceProvider(id string, payload data.DealTransactionsResourceProvider) (data.DealContainer, error) {
+	if err := client.getError(); err != nil {
+		return data.DealContainer{}, err
+	}
+	deal, err := client.store.UpdateDealTransactionsResourceProvider(id, payload)
+	if err != nil {
+		return data.DealContainer{}, err
+	}
+	return *deal, nil
+}
+
+func (client *SolverClient) AddResult(result data.Result) (data.Result, error) {
+	if err := client.getError(); err != nil {
+		return data.Result{}, err
+	}
+	ret, err := client.store.AddResult(result)
+	if err != nil {
+		return data.Result{}, err
+	}
+	return *ret, nil
+}
+
+// the files are not kept - we just hand back a result for the deal
+func (client *SolverClient) UploadResultFiles(id string, localPath string) (data.Result, error) {
+	if err := client.getError(); err != nil {
+		return data.Result{}, err
+	}
+	return data.Result{DealID: id}, nil
+}
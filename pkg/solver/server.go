@@ -9,6 +9,7 @@ import (
 	corehttp "net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/bacalhau-project/lilypad/pkg/data"
@@ -19,6 +20,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// the most resource offers that can be added in one request
+const MAX_RESOURCE_OFFER_BATCH = 100
+
 type solverServer struct {
 	options    http.ServerOptions
 	controller *SolverController
@@ -62,6 +66,8 @@ func (solverServer *solverServer) ListenAndServe(ctx context.Context, cm *system
 
 	subrouter.HandleFunc("/resource_offers", http.GetHandler(solverServer.getResourceOffers)).Methods("GET")
 	subrouter.HandleFunc("/resource_offers", http.PostHandler(solverServer.addResourceOffer)).Methods("POST")
+	subrouter.HandleFunc("/resource_offers/batch", http.PostHandler(solverServer.addResourceOffers)).Methods("POST")
+	subrouter.HandleFunc("/resource_offers/{id}", http.DeleteHandler(solverServer.removeResourceOffer)).Methods("DELETE")
 
 	subrouter.HandleFunc("/deals", http.GetHandler(solverServer.getDeals)).Methods("GET")
 	subrouter.HandleFunc("/deals/{id}", http.GetHandler(solverServer.getDeal)).Methods("GET")
@@ -166,6 +172,12 @@ func (solverServer *solverServer) getResourceOffers(res corehttp.ResponseWriter,
 	if notMatched := req.URL.Query().Get("not_matched"); notMatched == "true" {
 		query.NotMatched = true
 	}
+	limit, offset, err := getPageParams(req)
+	if err != nil {
+		return nil, err
+	}
+	query.Limit = limit
+	query.Offset = offset
 	return solverServer.store.GetResourceOffers(query)
 }
 
@@ -178,12 +190,41 @@ func (solverServer *solverServer) getDeals(res corehttp.ResponseWriter, req *cor
 	if resourceProvider := req.URL.Query().Get("resource_provider"); resourceProvider != "" {
 		query.ResourceProvider = resourceProvider
 	}
-	if state := req.URL.Query().Get("state"); state != "" {
-		query.State = state
+	// the state param can be repeated to match deals in any of the states
+	if states := req.URL.Query()["state"]; len(states) == 1 {
+		query.State = states[0]
+	} else {
+		query.States = states
 	}
+	limit, offset, err := getPageParams(req)
+	if err != nil {
+		return nil, err
+	}
+	query.Limit = limit
+	query.Offset = offset
 	return solverServer.store.GetDeals(query)
 }
 
+// read the optional limit and offset query params used to page through results
+func getPageParams(req *corehttp.Request) (int, int, error) {
+	params := []int{0, 0}
+	for i, name := range []string{"limit", "offset"} {
+		value := req.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, http.HTTPError{
+				Message:    fmt.Sprintf("invalid %s: %s", name, value),
+				StatusCode: corehttp.StatusBadRequest,
+			}
+		}
+		params[i] = parsed
+	}
+	return params[0], params[1], nil
+}
+
 /*
 *
 *
@@ -203,7 +244,10 @@ func (solverServer *solverServer) getDeal(res corehttp.ResponseWriter, req *core
 		return data.DealContainer{}, err
 	}
 	if deal == nil {
-		return data.DealContainer{}, fmt.Errorf("deal not found")
+		return data.DealContainer{}, http.HTTPError{
+			Message:    "deal not found",
+			StatusCode: corehttp.StatusNotFound,
+		}
 	}
 	return *deal, nil
 }
@@ -216,7 +260,10 @@ func (solverServer *solverServer) getResult(res corehttp.ResponseWriter, req *co
 		return data.Result{}, err
 	}
 	if result == nil {
-		return data.Result{}, fmt.Errorf("result not found")
+		return data.Result{}, http.HTTPError{
+			Message:    "result not found",
+			StatusCode: corehttp.StatusNotFound,
+		}
 	}
 	return *result, nil
 }
@@ -268,6 +315,41 @@ func (solverServer *solverServer) addResourceOffer(resourceOffer data.ResourceOf
 	return solverServer.controller.addResourceOffer(resourceOffer)
 }
 
+// add many resource offers from one signed request
+// each offer is checked and added on its own so the rest still go in if one is bad
+func (solverServer *solverServer) addResourceOffers(resourceOffers []data.ResourceOffer, res corehttp.ResponseWriter, req *corehttp.Request) (*[]AddResourceOfferResult, error) {
+	if len(resourceOffers) > MAX_RESOURCE_OFFER_BATCH {
+		return nil, http.HTTPError{
+			Message:    fmt.Sprintf("cannot add more than %d resource offers at once", MAX_RESOURCE_OFFER_BATCH),
+			StatusCode: corehttp.StatusBadRequest,
+		}
+	}
+	signerAddress, err := http.GetAddressFromHeaders(req)
+	if err != nil {
+		log.Error().Err(err).Msgf("have error parsing user address")
+		return nil, err
+	}
+	results := []AddResourceOfferResult{}
+	for _, resourceOffer := range resourceOffers {
+		result := AddResourceOfferResult{}
+		if signerAddress != resourceOffer.ResourceProvider {
+			result.Error = "resource provider address does not match signer address"
+			results = append(results, result)
+			continue
+		}
+		err = data.CheckResourceOffer(resourceOffer)
+		if err == nil {
+			result.Offer, err = solverServer.controller.addResourceOffer(resourceOffer)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("Error adding resource offer")
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return &results, nil
+}
+
 func (solverServer *solverServer) addResult(results data.Result, res corehttp.ResponseWriter, req *corehttp.Request) (*data.Result, error) {
 	vars := mux.Vars(req)
 	id := vars["id"]
@@ -380,6 +462,50 @@ func (solverServer *solverServer) updateTransactionsMediator(payload data.DealTr
 	return solverServer.controller.updateDealTransactionsMediator(id, payload)
 }
 
+/*
+*
+*
+*
+
+	Removers
+
+*
+*
+*
+*/
+func (solverServer *solverServer) removeResourceOffer(res corehttp.ResponseWriter, req *corehttp.Request) (*data.ResourceOfferContainer, error) {
+	vars := mux.Vars(req)
+	id := vars["id"]
+	resourceOffer, err := solverServer.store.GetResourceOffer(id)
+	if err != nil {
+		log.Error().Err(err).Msgf("error loading resource offer")
+		return nil, err
+	}
+	if resourceOffer == nil {
+		return nil, http.HTTPError{
+			Message:    "resource offer not found",
+			StatusCode: corehttp.StatusNotFound,
+		}
+	}
+	signerAddress, err := http.GetAddressFromHeaders(req)
+	if err != nil {
+		log.Error().Err(err).Msgf("have error parsing user address")
+		return nil, err
+	}
+	// only the resource provider can remove their resource offer
+	if signerAddress != resourceOffer.ResourceProvider {
+		return nil, fmt.Errorf("resource provider address does not match signer address")
+	}
+	// once it's part of a deal the deal has to play out
+	if resourceOffer.DealID != "" {
+		return nil, http.HTTPError{
+			Message:    "resource offer has already been matched",
+			StatusCode: corehttp.StatusConflict,
+		}
+	}
+	return solverServer.controller.removeResourceOffer(*resourceOffer)
+}
+
 /*
 *
 *
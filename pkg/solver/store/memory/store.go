@@ -3,6 +3,7 @@ package store
 import (
 	"fmt"
 	"os"
+	"sort"
 	"sync"
 
 	"github.com/bacalhau-project/lilypad/pkg/data"
@@ -141,20 +142,23 @@ func (s *SolverStoreMemory) GetResourceOffers(query store.GetResourceOffersQuery
 			resourceOffers = append(resourceOffers, *resourceOffer)
 		}
 	}
-	return resourceOffers, nil
+	sort.Slice(resourceOffers, func(i, j int) bool {
+		return resourceOffers[i].ID < resourceOffers[j].ID
+	})
+	return store.Paginate(resourceOffers, query.Limit, query.Offset), nil
 }
 
 func (s *SolverStoreMemory) GetDeals(query store.GetDealsQuery) ([]data.DealContainer, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	deals := []data.DealContainer{}
-	queryState := uint8(0)
-	if query.State != "" {
-		parsedState, err := data.GetAgreementState(query.State)
+	queryStates := map[uint8]bool{}
+	for _, state := range query.GetStates() {
+		parsedState, err := data.GetAgreementState(state)
 		if err != nil {
 			return nil, err
 		}
-		queryState = parsedState
+		queryStates[parsedState] = true
 	}
 	for _, deal := range s.dealMap {
 		matching := true
@@ -167,14 +171,17 @@ func (s *SolverStoreMemory) GetDeals(query store.GetDealsQuery) ([]data.DealCont
 		if query.Mediator != "" && deal.Mediator != query.Mediator {
 			matching = false
 		}
-		if query.State != "" && deal.State != queryState {
+		if len(queryStates) > 0 && !queryStates[deal.State] {
 			matching = false
 		}
 		if matching {
 			deals = append(deals, *deal)
 		}
 	}
-	return deals, nil
+	sort.Slice(deals, func(i, j int) bool {
+		return deals[i].ID < deals[j].ID
+	})
+	return store.Paginate(deals, query.Limit, query.Offset), nil
 }
 
 func (s *SolverStoreMemory) GetJobOffer(id string) (*data.JobOfferContainer, error) {
@@ -31,6 +31,11 @@ type GetResourceOffersQuery struct {
 
 	// we use the DealID property of the resourceOfferContainer to tell if it's been matched
 	NotMatched bool `json:"not_matched"`
+
+	// results are sorted by id so these can be used to page through them
+	// a zero limit means return everything
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
 }
 
 type GetDealsQuery struct {
@@ -40,6 +45,41 @@ type GetDealsQuery struct {
 
 	// only deals that are in this state will be returned
 	State string `json:"state"`
+	// or in any of these states - both can be used and a deal in any of them matches
+	States []string `json:"states"`
+
+	// results are sorted by id so these can be used to page through them
+	// a zero limit means return everything
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// every state the query matches, empty if it matches any state
+func (query GetDealsQuery) GetStates() []string {
+	states := []string{}
+	if query.State != "" {
+		states = append(states, query.State)
+	}
+	for _, state := range query.States {
+		if state != "" {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// return the page of items asked for by limit and offset
+func Paginate[T any](items []T, limit int, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	if offset > 0 {
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
 }
 
 type SolverStore interface {
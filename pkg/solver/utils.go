@@ -12,40 +12,10 @@ const FILES_DIR = "job-files"
 const DOWNLOADS_DIR = "downloaded-files"
 
 func LogSolverEvent(badge string, ev SolverEvent) {
-	switch ev.EventType {
-	case JobOfferAdded:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> JobOfferAdded", badge), fmt.Sprintf("%+v", *ev.JobOffer)).
-			Msgf("")
-	case ResourceOfferAdded:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> ResourceOfferAdded", badge), fmt.Sprintf("%+v", *ev.ResourceOffer)).
-			Msgf("")
-	case DealAdded:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> DealAdded", badge), fmt.Sprintf("%+v", ev)).
-			Msgf("")
-	case JobOfferStateUpdated:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> JobOfferStateUpdated", badge), fmt.Sprintf("%+v", ev)).
-			Msgf("")
-	case ResourceOfferStateUpdated:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> ResourceOfferStateUpdated", badge), fmt.Sprintf("%+v", ev)).
-			Msgf("")
-	case DealStateUpdated:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> DealStateUpdated", badge), fmt.Sprintf("%+v", ev)).
-			Msgf("")
-	case ResourceProviderTransactionsUpdated:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> ResourceProviderTransactionsUpdated", badge), fmt.Sprintf("%+v", ev)).
-			Msgf("")
-	case JobCreatorTransactionsUpdated:
-		log.Debug().
-			Str(fmt.Sprintf("%s -> JobCreatorTransactionsUpdated", badge), fmt.Sprintf("%+v", ev)).
-			Msgf("")
-	}
+	log.Debug().
+		Str(fmt.Sprintf("%s -> %s", badge, ev.EventType), ev.String()).
+		Interface("event", ev).
+		Msgf("")
 }
 
 func ServiceLogSolverEvent(service system.Service, ev SolverEvent) {
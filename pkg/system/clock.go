@@ -0,0 +1,84 @@
+package system
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is where time based logic gets the time from
+// so that tests can move time on themselves
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RealClock is the system clock
+var RealClock Clock = realClock{}
+
+// FakeClock only moves when Advance is called
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+
+type fakeClockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- clock.now
+		return ch
+	}
+	clock.waiters = append(clock.waiters, fakeClockWaiter{at: clock.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock on and fires every After that is now due
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+	waiting := []fakeClockWaiter{}
+	for _, waiter := range clock.waiters {
+		if waiter.at.After(clock.now) {
+			waiting = append(waiting, waiter)
+			continue
+		}
+		waiter.ch <- clock.now
+	}
+	clock.waiters = waiting
+}
+
+// Waiters is how many Afters have not fired yet
+// so tests can tell when a goroutine has started waiting
+func (clock *FakeClock) Waiters() int {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return len(clock.waiters)
+}
@@ -19,9 +19,13 @@ type CommandContext struct {
 }
 
 func NewSystemContext(ctx context.Context) *CommandContext {
+	return newSystemContext(ctx, os.Interrupt, syscall.SIGTERM)
+}
+
+func newSystemContext(ctx context.Context, signals ...os.Signal) *CommandContext {
 	SetupLogging()
 	cm := NewCleanupManager()
-	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	ctx, cancel := signal.NotifyContext(ctx, signals...)
 	return &CommandContext{
 		CommandContext: ctx,
 		Ctx:            ctx,
@@ -59,6 +63,12 @@ func NewCommandContext(cmd *cobra.Command) *CommandContext {
 	return NewSystemContext(cmd.Context())
 }
 
+// the same as NewCommandContext but only the given signals cancel the context
+// so the command can handle the others itself
+func NewCommandContextWithSignals(cmd *cobra.Command, signals ...os.Signal) *CommandContext {
+	return newSystemContext(cmd.Context(), signals...)
+}
+
 func (cmdContext *CommandContext) Cleanup() {
 	cmdContext.Cm.Cleanup(cmdContext.CommandContext)
 	cmdContext.Cm.Cleanup(cmdContext.Ctx)
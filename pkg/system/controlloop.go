@@ -2,19 +2,23 @@ package system
 
 import (
 	"context"
+	"math/rand"
 	"sync"
 	"time"
 )
 
 type ControlLoop struct {
-	service      Service
-	ctx          context.Context
-	triggerMutex sync.Mutex
-	runMutex     sync.Mutex
-	interval     time.Duration
-	handler      func() error
-	running      bool
-	counter      int
+	service       Service
+	ctx           context.Context
+	triggerMutex  sync.Mutex
+	runMutex      sync.Mutex
+	intervalMutex sync.RWMutex
+	interval      time.Duration
+	jitter        float64
+	handler       func() error
+	running       bool
+	counter       int
+	clock         Clock
 }
 
 func NewControlLoop(
@@ -30,9 +34,16 @@ func NewControlLoop(
 		handler:  handler,
 		running:  false,
 		counter:  0,
+		clock:    RealClock,
 	}
 }
 
+// where the loop gets the time from between runs
+// this must be called before Start
+func (loop *ControlLoop) SetClock(clock Clock) {
+	loop.clock = clock
+}
+
 func (loop *ControlLoop) incrementCounter() {
 	loop.triggerMutex.Lock()
 	defer loop.triggerMutex.Unlock()
@@ -69,9 +80,41 @@ func (loop *ControlLoop) run() {
 	}
 }
 
-func (loop *ControlLoop) Start(runInitially bool) error {
-	ticker := time.NewTicker(loop.interval)
+// change how long the loop waits between background runs
+// this takes effect once the current wait has finished
+func (loop *ControlLoop) SetInterval(interval time.Duration) {
+	loop.intervalMutex.Lock()
+	defer loop.intervalMutex.Unlock()
+	loop.interval = interval
+}
+
+func (loop *ControlLoop) GetInterval() time.Duration {
+	loop.intervalMutex.RLock()
+	defer loop.intervalMutex.RUnlock()
+	return loop.interval
+}
 
+// randomly move each wait by up to this fraction of the interval either way
+// so that lots of loops started at the same time don't all fire together
+func (loop *ControlLoop) SetJitter(jitter float64) {
+	loop.intervalMutex.Lock()
+	defer loop.intervalMutex.Unlock()
+	loop.jitter = jitter
+}
+
+// the interval with the jitter applied
+func (loop *ControlLoop) getWaitInterval() time.Duration {
+	loop.intervalMutex.RLock()
+	defer loop.intervalMutex.RUnlock()
+	if loop.jitter <= 0 {
+		return loop.interval
+	}
+	//nolint:gosec
+	offset := (rand.Float64()*2 - 1) * loop.jitter * float64(loop.interval)
+	return loop.interval + time.Duration(offset)
+}
+
+func (loop *ControlLoop) Start(runInitially bool) error {
 	if runInitially {
 		err := loop.handler()
 		if err != nil {
@@ -81,10 +124,12 @@ func (loop *ControlLoop) Start(runInitially bool) error {
 
 	go func() {
 		for {
+			// we wait afresh each time so that changes
+			// to the interval are picked up on the next wait
 			select {
 			case <-loop.ctx.Done():
 				return
-			case <-ticker.C:
+			case <-loop.clock.After(loop.getWaitInterval()):
 			}
 			loop.Trigger()
 		}
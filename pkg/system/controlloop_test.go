@@ -0,0 +1,34 @@
+package system
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlLoopRunsEveryInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := NewFakeClock(time.Unix(0, 0))
+	runs := make(chan struct{}, 10)
+	loop := NewControlLoop(ResourceProviderService, ctx, time.Minute, func() error {
+		runs <- struct{}{}
+		return nil
+	})
+	loop.SetClock(clock)
+	assert.NoError(t, loop.Start(false))
+
+	for i := 0; i < 2; i++ {
+		assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+		clock.Advance(time.Minute - time.Second)
+		assert.Empty(t, runs, "Should not run before the interval is up")
+		clock.Advance(time.Second)
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatal("The loop did not run once the interval was up")
+		}
+	}
+}
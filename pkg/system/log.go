@@ -1,17 +1,26 @@
 package system
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type ServiceLogger struct {
 	service Service
+	// written with every line (see Ctx)
+	fields []Field
 }
 
 func NewServiceLogger(service Service) *ServiceLogger {
@@ -20,24 +29,144 @@ func NewServiceLogger(service Service) *ServiceLogger {
 	}
 }
 
-func (s *ServiceLogger) Error(title string, err error) {
-	Error(s.service, title, err)
+// a logger that adds the fields carried by ctx (see WithLogFields) to each line
+// so the lines for one unit of work can be picked out when work is interleaved
+func (s *ServiceLogger) Ctx(ctx context.Context) *ServiceLogger {
+	return &ServiceLogger{
+		service: s.service,
+		fields:  LogFields(ctx),
+	}
+}
+
+func (s *ServiceLogger) withFields(fields []Field) []Field {
+	if len(s.fields) == 0 {
+		return fields
+	}
+	return append(append([]Field{}, fields...), s.fields...)
+}
+
+func (s *ServiceLogger) Error(title string, err error, fields ...Field) {
+	Error(s.service, title, err, s.withFields(fields)...)
+}
+
+func (s *ServiceLogger) Warn(title string, data interface{}, fields ...Field) {
+	Warn(s.service, title, data, s.withFields(fields)...)
+}
+
+func (s *ServiceLogger) Info(title string, data interface{}, fields ...Field) {
+	Info(s.service, title, data, s.withFields(fields)...)
+}
+
+func (s *ServiceLogger) Debug(title string, data interface{}, fields ...Field) {
+	Debug(s.service, title, data, s.withFields(fields)...)
+}
+
+func (s *ServiceLogger) Trace(title string, data interface{}, fields ...Field) {
+	Trace(s.service, title, data, s.withFields(fields)...)
 }
 
-func (s *ServiceLogger) Info(title string, data interface{}) {
-	Info(s.service, title, data)
+// a key/value pair that is written as its own field in the log line
+// so it can be queried rather than being formatted into the title's value
+type Field struct {
+	Key   string
+	Value interface{}
 }
 
-func (s *ServiceLogger) Debug(title string, data interface{}) {
-	Debug(s.service, title, data)
+func F(key string, value interface{}) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+type logFieldsKey struct{}
+
+// WithLogFields returns a context that carries fields as well as any it already had
+// loggers made with ServiceLogger.Ctx write them on every line
+func WithLogFields(ctx context.Context, fields ...Field) context.Context {
+	existing := LogFields(ctx)
+	return context.WithValue(ctx, logFieldsKey{}, append(append([]Field{}, existing...), fields...))
 }
 
-func (s *ServiceLogger) Trace(title string, data interface{}) {
-	Trace(s.service, title, data)
+func LogFields(ctx context.Context) []Field {
+	fields, _ := ctx.Value(logFieldsKey{}).([]Field)
+	return fields
+}
+
+// a short random id to tie together the log lines for one unit of work
+func NewCorrelationID() string {
+	id := make([]byte, 8) //nolint:gomnd
+	_, err := rand.Read(id)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+func getLogOutput() io.Writer {
+	// LOG_FILE writes to a file that is rotated once it gets too big
+	// instead of stdout unless LOG_FILE_STDOUT=true
+	file := getLogFile(os.Getenv("LOG_FILE"))
+	if file == nil {
+		return formatLogOutput(os.Stdout, false)
+	}
+	mirror, _ := strconv.ParseBool(os.Getenv("LOG_FILE_STDOUT"))
+	if !mirror {
+		return formatLogOutput(file, true)
+	}
+	return zerolog.MultiLevelWriter(formatLogOutput(file, true), formatLogOutput(os.Stdout, false))
+}
+
+// LOG_FORMAT=json writes plain zerolog json so logs can be shipped
+// to something like Loki or Elasticsearch
+func formatLogOutput(out io.Writer, noColor bool) io.Writer {
+	switch os.Getenv("LOG_FORMAT") {
+	case "json":
+		return out
+	default:
+		return zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339, NoColor: noColor}
+	}
+}
+
+// how big the log file gets (in megabytes) before it is rotated, how many rotated
+// files we keep and how many days we keep them for if LOG_FILE_MAX_* are not set
+const (
+	DEFAULT_LOG_FILE_MAX_SIZE    = 100
+	DEFAULT_LOG_FILE_MAX_BACKUPS = 5
+	DEFAULT_LOG_FILE_MAX_AGE     = 30
+)
+
+// the log file is opened once so setting up logging again doesn't
+// leave two writers rotating the same file
+var logFile *lumberjack.Logger
+
+func getLogFile(path string) *lumberjack.Logger {
+	if path == "" {
+		return nil
+	}
+	if logFile != nil && logFile.Filename == path {
+		return logFile
+	}
+	logFile = &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    getLogFileLimit("LOG_FILE_MAX_SIZE", DEFAULT_LOG_FILE_MAX_SIZE),
+		MaxBackups: getLogFileLimit("LOG_FILE_MAX_BACKUPS", DEFAULT_LOG_FILE_MAX_BACKUPS),
+		MaxAge:     getLogFileLimit("LOG_FILE_MAX_AGE", DEFAULT_LOG_FILE_MAX_AGE),
+	}
+	return logFile
+}
+
+// 0 means no limit for backups and age and the lumberjack default of 100MB for size
+func getLogFileLimit(envName string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(envName))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
 }
 
 func SetupLogging() {
-	output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	output := getLogOutput()
 	logLevelString := os.Getenv("LOG_LEVEL")
 	if logLevelString == "" {
 		logLevelString = "info"
@@ -50,33 +179,118 @@ func SetupLogging() {
 	if err == nil {
 		logLevel = parsedLogLevel
 	}
-	zerolog.CallerSkipFrameCount = 3 // Skip 3 frames (this function, log.Output, log.Logger)
-	log.Logger = log.Output(output).With().Caller().Logger().Level(logLevel)
+	// LOG_CALLER=false skips working out which file and line each log came from
+	logCaller = true
+	if parsedLogCaller, err := strconv.ParseBool(os.Getenv("LOG_CALLER")); err == nil {
+		logCaller = parsedLogCaller
+	}
+	if logCaller {
+		zerolog.CallerSkipFrameCount = 3 // Skip 3 frames (this function, log.Output, log.Logger)
+		log.Logger = log.Output(output).With().Caller().Logger().Level(logLevel)
+	} else {
+		log.Logger = log.Output(output).Level(logLevel)
+	}
+	// LOG_SAMPLE_RATE=N only writes 1 in N debug and trace lines
+	if sampler := getLogSampler(os.Getenv("LOG_SAMPLE_RATE")); sampler != nil {
+		log.Logger = log.Logger.Sample(sampler)
+	}
+	serviceLogLevels = parseServiceLogLevels(os.Getenv("LOG_LEVELS"))
 }
 
-func logWithCaller(skipFrameCount int, level zerolog.Level, service Service, title string, data interface{}) {
+// sample the noisy levels and let everything from info up through
+// returns nil if there is nothing to sample
+func getLogSampler(value string) zerolog.Sampler {
+	rate, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || rate <= 1 {
+		return nil
+	}
+	return zerolog.LevelSampler{
+		TraceSampler: &zerolog.BasicSampler{N: uint32(rate)},
+		DebugSampler: &zerolog.BasicSampler{N: uint32(rate)},
+	}
+}
+
+// resolving the caller is relatively expensive so it can be turned off
+var logCaller = true
+
+// per service overrides of LOG_LEVEL set with LOG_LEVELS
+var serviceLogLevels = map[Service]zerolog.Level{}
+
+// LOG_LEVELS looks like resource_provider=debug,solver=info
+// service names can use either underscores or dashes
+// anything we can't parse is ignored
+func parseServiceLogLevels(value string) map[Service]zerolog.Level {
+	levels := map[Service]zerolog.Level{}
+	for _, part := range strings.Split(value, ",") {
+		name, levelString, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		level, err := zerolog.ParseLevel(strings.TrimSpace(levelString))
+		if err != nil {
+			continue
+		}
+		service := Service(strings.ReplaceAll(strings.TrimSpace(name), "_", "-"))
+		levels[service] = level
+	}
+	return levels
+}
+
+func logWithCaller(skipFrameCount int, level zerolog.Level, service Service, title string, data interface{}, fields []Field) {
+	logger := log.Logger
+	if serviceLevel, ok := serviceLogLevels[service]; ok {
+		logger = logger.Level(serviceLevel)
+	}
+	e := logger.WithLevel(level)
+	// don't format the data if nothing is going to be written
+	if !e.Enabled() {
+		return
+	}
+	// if the data is a field then everything is structured and the title is the message
+	// otherwise the data is formatted as the value of the title like it always has been
+	message := ""
+	if field, ok := data.(Field); ok {
+		fields = append([]Field{field}, fields...)
+		message = GetServiceString(service, title)
+	} else {
+		e = e.Str(GetServiceString(service, title), fmt.Sprintf("%+v", data))
+	}
+	for _, field := range fields {
+		// errors don't marshal to json so write their message
+		if err, ok := field.Value.(error); ok {
+			e = e.AnErr(field.Key, err)
+			continue
+		}
+		e = e.Interface(field.Key, field.Value)
+	}
+	if !logCaller {
+		e.Msg(message)
+		return
+	}
+
 	zerolog.CallerSkipFrameCount = skipFrameCount
 	defer func() { zerolog.CallerSkipFrameCount = 3 }() // Reset to the default value
+	e.Caller().Msg(message)
+}
 
-	e := log.WithLevel(level).
-		Str(GetServiceString(service, title), fmt.Sprintf("%+v", data))
-	e.Caller().Msg("")
+func Error(service Service, title string, err error, fields ...Field) {
+	logWithCaller(5, zerolog.ErrorLevel, service, title, err, fields)
 }
 
-func Error(service Service, title string, err error) {
-	logWithCaller(5, zerolog.ErrorLevel, service, title, err)
+func Warn(service Service, title string, data interface{}, fields ...Field) {
+	logWithCaller(5, zerolog.WarnLevel, service, title, data, fields)
 }
 
-func Info(service Service, title string, data interface{}) {
-	logWithCaller(5, zerolog.InfoLevel, service, title, data)
+func Info(service Service, title string, data interface{}, fields ...Field) {
+	logWithCaller(5, zerolog.InfoLevel, service, title, data, fields)
 }
 
-func Debug(service Service, title string, data interface{}) {
-	logWithCaller(5, zerolog.DebugLevel, service, title, data)
+func Debug(service Service, title string, data interface{}, fields ...Field) {
+	logWithCaller(5, zerolog.DebugLevel, service, title, data, fields)
 }
 
-func Trace(service Service, title string, data interface{}) {
-	logWithCaller(5, zerolog.TraceLevel, service, title, data)
+func Trace(service Service, title string, data interface{}, fields ...Field) {
+	logWithCaller(5, zerolog.TraceLevel, service, title, data, fields)
 }
 
 func DumpObject(d interface{}) {
@@ -0,0 +1,113 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func captureLog(t *testing.T, logFn func()) map[string]interface{} {
+	originalLogger := log.Logger
+	originalLogCaller := logCaller
+	defer func() {
+		log.Logger = originalLogger
+		logCaller = originalLogCaller
+	}()
+	buf := &bytes.Buffer{}
+	log.Logger = zerolog.New(buf).Level(zerolog.InfoLevel)
+	logCaller = false
+
+	logFn()
+	line := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	return line
+}
+
+func TestLogFields(t *testing.T) {
+	line := captureLog(t, func() {
+		Info(ResourceProviderService, "agree", F("dealID", "abc"), F("attempt", 2))
+	})
+	assert.Equal(t, "abc", line["dealID"], "Fields should be written as their own keys")
+	assert.Equal(t, float64(2), line["attempt"])
+	assert.Equal(t, GetServiceString(ResourceProviderService, "agree"), line["message"])
+
+	line = captureLog(t, func() {
+		Error(ResourceProviderService, "agree failed", errors.New("reverted"), F("dealID", "abc"))
+	})
+	assert.Equal(t, "reverted", line[GetServiceString(ResourceProviderService, "agree failed")], "The single value form should still work")
+	assert.Equal(t, "abc", line["dealID"])
+
+	line = captureLog(t, func() {
+		Info(ResourceProviderService, "agree", F("cause", errors.New("reverted")))
+	})
+	assert.Equal(t, "reverted", line["cause"], "Errors should be written as their message")
+}
+
+func TestContextLogFields(t *testing.T) {
+	ctx := WithLogFields(context.Background(), F("solveID", "123"))
+	dealCtx := WithLogFields(ctx, F("dealID", "abc"))
+	logger := NewServiceLogger(ResourceProviderService)
+
+	line := captureLog(t, func() {
+		logger.Ctx(dealCtx).Info("agree", "tx")
+	})
+	assert.Equal(t, "123", line["solveID"], "Fields from the context should be written")
+	assert.Equal(t, "abc", line["dealID"])
+
+	line = captureLog(t, func() {
+		logger.Ctx(ctx).Info("solving", "")
+	})
+	assert.Equal(t, "123", line["solveID"])
+	assert.NotContains(t, line, "dealID", "Adding fields should not change the parent context")
+
+	assert.NotEqual(t, NewCorrelationID(), NewCorrelationID())
+}
+
+func TestLogSampler(t *testing.T) {
+	assert.Nil(t, getLogSampler(""), "There should be no sampling by default")
+	assert.Nil(t, getLogSampler("1"))
+	assert.Nil(t, getLogSampler("nonsense"))
+
+	buf := &bytes.Buffer{}
+	logger := zerolog.New(buf).Level(zerolog.DebugLevel).Sample(getLogSampler("3"))
+	for i := 0; i < 6; i++ {
+		logger.Debug().Msg("solving")
+	}
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")), "Only 1 in 3 debug lines should be written")
+
+	buf.Reset()
+	for i := 0; i < 6; i++ {
+		logger.Warn().Msg("slow")
+		logger.Error().Msg("failed")
+	}
+	assert.Equal(t, 12, bytes.Count(buf.Bytes(), []byte("\n")), "Warnings and errors should never be sampled")
+}
+
+func TestLogFile(t *testing.T) {
+	assert.Nil(t, getLogFile(""), "There should be no log file by default")
+	defer func() { logFile = nil }()
+
+	path := filepath.Join(t.TempDir(), "lilypad.log")
+	t.Setenv("LOG_FILE", path)
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("LOG_FILE_MAX_SIZE", "10")
+	file := getLogFile(path)
+	defer file.Close()
+	assert.Equal(t, 10, file.MaxSize)
+	assert.Equal(t, DEFAULT_LOG_FILE_MAX_BACKUPS, file.MaxBackups)
+	assert.Same(t, file, getLogFile(path), "The same file should only be opened once")
+
+	logger := zerolog.New(getLogOutput())
+	logger.Info().Msg("started")
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(written), `"message":"started"`, "The file should get the json format too")
+}
@@ -1,7 +1,5 @@
 package system
 
-import "fmt"
-
 type Service string
 
 const (
@@ -27,6 +25,20 @@ func GetServiceBadge(service Service) string {
 	}
 }
 
+// this is called for every log line so we work the badges out once
+// and then the title is the only thing left to join on
+var serviceStringPrefixes = map[Service]string{}
+
+func init() {
+	for _, service := range []Service{SolverService, ResourceProviderService, JobCreatorService, MediatorService, DefaultService} {
+		serviceStringPrefixes[service] = GetServiceBadge(service) + " "
+	}
+}
+
 func GetServiceString(service Service, st string) string {
-	return fmt.Sprintf("%s %s", GetServiceBadge(service), st)
+	prefix, ok := serviceStringPrefixes[service]
+	if !ok {
+		prefix = GetServiceBadge(service) + " "
+	}
+	return prefix + st
 }
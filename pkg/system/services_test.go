@@ -0,0 +1,22 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetServiceString(t *testing.T) {
+	assert.Equal(t, "🔵 RP solving", GetServiceString(ResourceProviderService, "solving"))
+	assert.Equal(t, "⚪ solving", GetServiceString(Service("unknown"), "solving"), "Unknown services should get the default badge")
+}
+
+// keeps the compiler from optimising the call away
+var serviceStringSink string
+
+func BenchmarkGetServiceString(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		serviceStringSink = GetServiceString(ResourceProviderService, "solving")
+	}
+}
@@ -7,8 +7,13 @@ import (
 
 	"github.com/bacalhau-project/lilypad/pkg/data"
 	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/controller"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/users"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/rs/zerolog/log"
 )
 
@@ -41,12 +46,14 @@ func (sdk *Web3SDK) UpdateUser(
 	url string,
 	roles []uint8,
 ) error {
-	tx, err := sdk.Contracts.Users.UpdateUser(
-		sdk.TransactOpts,
-		metadataCID,
-		url,
-		roles,
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Users.UpdateUser(
+			opts,
+			metadataCID,
+			url,
+			roles,
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting Users.UpdateUser", err)
 		return err
@@ -54,7 +61,7 @@ func (sdk *Web3SDK) UpdateUser(
 		system.Info(sdk.Options.Service, "submitted users.UpdateUser", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	_, err = sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return err
 	}
@@ -64,10 +71,12 @@ func (sdk *Web3SDK) UpdateUser(
 func (sdk *Web3SDK) AddUserToList(
 	serviceType uint8,
 ) error {
-	tx, err := sdk.Contracts.Users.AddUserToList(
-		sdk.TransactOpts,
-		serviceType,
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Users.AddUserToList(
+			opts,
+			serviceType,
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting Users.AddUserToList", err)
 		return err
@@ -75,17 +84,20 @@ func (sdk *Web3SDK) AddUserToList(
 		system.Info(sdk.Options.Service, "submitted users.AddUserToList", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	_, err = sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (sdk *Web3SDK) GetSolverUrl(address string) (string, error) {
+// ctx bounds the call to the rpc node so a node that never answers can't hang us
+func (sdk *Web3SDK) GetSolverUrl(ctx context.Context, address string) (string, error) {
 	log.Debug().Msgf("begin GetSolverUrl from contract at address: %s", address)
+	callOpts := *sdk.CallOpts
+	callOpts.Context = ctx
 	solver, err := sdk.Contracts.Users.GetUser(
-		sdk.CallOpts,
+		&callOpts,
 		common.HexToAddress(address),
 	)
 	if err != nil {
@@ -95,37 +107,105 @@ func (sdk *Web3SDK) GetSolverUrl(address string) (string, error) {
 	}
 
 	if solver.UserAddress == common.HexToAddress("0x0") {
+		// nobody registers on a simulated chain so point at the local solver
+		if sdk.Options.Mode == Web3ModeSimulated && sdk.Options.SimulatedSolverURL != "" {
+			return sdk.Options.SimulatedSolverURL, nil
+		}
 		return "", fmt.Errorf("no solver found for address: %s", address)
 	}
 	return solver.Url, nil
 }
 
+// the on-chain agreement for a deal
+// every timestamp is zero if nobody has agreed to the deal yet
+func (sdk *Web3SDK) GetAgreement(dealID string) (storage.SharedStructsAgreement, error) {
+	return sdk.Contracts.Storage.GetAgreement(sdk.CallOpts, dealID)
+}
+
 func (sdk *Web3SDK) Agree(
 	deal data.Deal,
 ) (string, error) {
+	return sdk.AgreeWithContext(context.Background(), deal)
+}
+
+// the context bounds how long we wait for the agree tx to be mined
+func (sdk *Web3SDK) AgreeWithContext(
+	ctx context.Context,
+	deal data.Deal,
+) (string, error) {
+	tx, err := sdk.SubmitAgree(ctx, deal)
+	if err != nil {
+		return "", err
+	}
+	// only treat the deal as agreed once the tx has been mined successfully
+	receipt, err := sdk.WaitTxSuccess(ctx, tx)
+	if err != nil {
+		system.Error(sdk.Options.Service, "controller.Agree() tx failed", err)
+		return "", err
+	}
+	return receipt.TxHash.String(), nil
+}
+
+// send the agree tx without waiting for it to be mined
+// so the caller can keep track of the tx hash in the meantime
+func (sdk *Web3SDK) SubmitAgree(
+	ctx context.Context,
+	deal data.Deal,
+) (*types.Transaction, error) {
 	mediators := []common.Address{}
 	for _, mediator := range deal.Members.Mediators {
 		mediators = append(mediators, common.HexToAddress(mediator))
 	}
-	tx, err := sdk.Contracts.Controller.Agree(
-		sdk.TransactOpts,
-		deal.ID,
-		data.ConvertDealMembers(deal.Members),
-		data.ConvertDealTimeouts(deal.Timeouts),
-		data.ConvertDealPricing(deal.Pricing),
-	)
+	tx, err := sdk.SendTx(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Controller.Agree(
+			opts,
+			deal.ID,
+			data.ConvertDealMembers(deal.Members),
+			data.ConvertDealTimeouts(deal.Timeouts),
+			data.ConvertDealPricing(deal.Pricing),
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting controller.Agree() tx", err)
-		return "", err
+		return nil, err
 	} else {
 		system.Debug(sdk.Options.Service, "submitted controller.Agree() tx", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	return tx, nil
+}
+
+// SimulateAgree runs the agree tx as an eth_call against the latest block
+// so we can skip deals it would revert for without spending any gas
+// returns a *RevertError if the contract would revert
+func (sdk *Web3SDK) SimulateAgree(
+	ctx context.Context,
+	deal data.Deal,
+) error {
+	controllerABI, err := controller.ControllerMetaData.GetAbi()
 	if err != nil {
-		return "", err
+		return err
+	}
+	input, err := controllerABI.Pack(
+		"agree",
+		deal.ID,
+		data.ConvertDealMembers(deal.Members),
+		data.ConvertDealTimeouts(deal.Timeouts),
+		data.ConvertDealPricing(deal.Pricing),
+	)
+	if err != nil {
+		return err
+	}
+	controllerAddress := common.HexToAddress(sdk.Options.ControllerAddress)
+	_, err = sdk.Client.CallContract(ctx, ethereum.CallMsg{
+		From: sdk.GetAddress(),
+		To:   &controllerAddress,
+		Data: input,
+	}, nil)
+	if err != nil && isRevertError(err) {
+		return &RevertError{Reason: getRevertReasonFromError(err)}
 	}
-	return tx.Hash().String(), nil
+	return err
 }
 
 func (sdk *Web3SDK) AddResult(
@@ -134,13 +214,15 @@ func (sdk *Web3SDK) AddResult(
 	dataId string,
 	instructionCount uint64,
 ) (string, error) {
-	tx, err := sdk.Contracts.Controller.AddResult(
-		sdk.TransactOpts,
-		dealId,
-		resultsId,
-		dataId,
-		big.NewInt(int64(instructionCount)),
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Controller.AddResult(
+			opts,
+			dealId,
+			resultsId,
+			dataId,
+			big.NewInt(int64(instructionCount)),
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting controller.AddResult", err)
 		return "", err
@@ -148,20 +230,22 @@ func (sdk *Web3SDK) AddResult(
 		system.Debug(sdk.Options.Service, "submitted controller.AddResult", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	receipt, err := sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return "", err
 	}
-	return tx.Hash().String(), nil
+	return receipt.TxHash.String(), nil
 }
 
 func (sdk *Web3SDK) AcceptResult(
 	dealId string,
 ) (string, error) {
-	tx, err := sdk.Contracts.Controller.AcceptResult(
-		sdk.TransactOpts,
-		dealId,
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Controller.AcceptResult(
+			opts,
+			dealId,
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting controller.AcceptResult", err)
 		return "", err
@@ -169,20 +253,22 @@ func (sdk *Web3SDK) AcceptResult(
 		system.Debug(sdk.Options.Service, "submitted controller.AcceptResult", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	receipt, err := sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return "", err
 	}
-	return tx.Hash().String(), nil
+	return receipt.TxHash.String(), nil
 }
 
 func (sdk *Web3SDK) CheckResult(
 	dealId string,
 ) (string, error) {
-	tx, err := sdk.Contracts.Controller.CheckResult(
-		sdk.TransactOpts,
-		dealId,
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Controller.CheckResult(
+			opts,
+			dealId,
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting controller.CheckResult", err)
 		return "", err
@@ -190,20 +276,22 @@ func (sdk *Web3SDK) CheckResult(
 		system.Debug(sdk.Options.Service, "submitted controller.CheckResult", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	receipt, err := sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return "", err
 	}
-	return tx.Hash().String(), nil
+	return receipt.TxHash.String(), nil
 }
 
 func (sdk *Web3SDK) MediationAcceptResult(
 	dealId string,
 ) (string, error) {
-	tx, err := sdk.Contracts.Controller.MediationAcceptResult(
-		sdk.TransactOpts,
-		dealId,
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Controller.MediationAcceptResult(
+			opts,
+			dealId,
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting controller.MediationAcceptResult", err)
 		return "", err
@@ -211,20 +299,22 @@ func (sdk *Web3SDK) MediationAcceptResult(
 		system.Debug(sdk.Options.Service, "submitted controller.MediationAcceptResult", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	receipt, err := sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return "", err
 	}
-	return tx.Hash().String(), nil
+	return receipt.TxHash.String(), nil
 }
 
 func (sdk *Web3SDK) MediationRejectResult(
 	dealId string,
 ) (string, error) {
-	tx, err := sdk.Contracts.Controller.MediationRejectResult(
-		sdk.TransactOpts,
-		dealId,
-	)
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return sdk.Contracts.Controller.MediationRejectResult(
+			opts,
+			dealId,
+		)
+	})
 	if err != nil {
 		system.Error(sdk.Options.Service, "error submitting controller.MediationRejectResult", err)
 		return "", err
@@ -232,9 +322,9 @@ func (sdk *Web3SDK) MediationRejectResult(
 		system.Debug(sdk.Options.Service, "submitted controller.MediationRejectResult", tx.Hash().String())
 		system.DumpObjectDebug(tx)
 	}
-	_, err = sdk.WaitTx(context.Background(), tx)
+	receipt, err := sdk.WaitTxSuccess(context.Background(), tx)
 	if err != nil {
 		return "", err
 	}
-	return tx.Hash().String(), nil
+	return receipt.TxHash.String(), nil
 }
@@ -0,0 +1,49 @@
+package web3
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSolverUrlTimesOut(t *testing.T) {
+	// an rpc node that never answers
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	address := GetAddress(privateKey).String()
+	sdk, err := NewContractSDK(Web3Options{
+		RpcURL:            server.URL,
+		PrivateKey:        hex.EncodeToString(crypto.FromECDSA(privateKey)),
+		ControllerAddress: address,
+		PaymentsAddress:   address,
+		StorageAddress:    address,
+		UsersAddress:      address,
+		MediationAddress:  address,
+		JobCreatorAddress: address,
+		TokenAddress:      address,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = sdk.GetSolverUrl(ctx, address)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -2,11 +2,19 @@ package web3
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/rs/zerolog/log"
 )
 
+// how long we wait before trying to subscribe to an event again after the
+// subscription drops if Web3Options doesn't say - doubled for each failed attempt
+const DEFAULT_EVENT_RESUBSCRIBE_BASE_DELAY = time.Second
+const DEFAULT_EVENT_RESUBSCRIBE_MAX_DELAY = 30 * time.Second
+
 type EventChannels struct {
 	Token       *TokenEventChannels
 	Payment     *PaymentEventChannels
@@ -39,14 +47,25 @@ func NewEventChannels() *EventChannels {
 	}
 }
 
+// the listeners stop when ctx is cancelled or cm cleans up
+// whichever comes first - cleaning up waits for them to unsubscribe
 func (eventChannels *EventChannels) Start(
 	sdk *Web3SDK,
 	ctx context.Context,
 	cm *system.CleanupManager,
 ) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	cm.RegisterCallback(func() error {
+		cancel()
+		wg.Wait()
+		return nil
+	})
 	for _, collection := range eventChannels.collections {
 		c := collection
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			err := c.Start(sdk, ctx, cm)
 			if err != nil {
 				log.Error().Msgf("error starting listeners: %s", err.Error())
@@ -55,3 +74,82 @@ func (eventChannels *EventChannels) Start(
 	}
 	return nil
 }
+
+// the handlers subscribed to one kind of event
+// handlers can be added while events are coming in
+type eventHandlers[T any] struct {
+	mutex    sync.RWMutex
+	handlers []func(T)
+}
+
+func (h *eventHandlers[T]) add(handler func(T)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.handlers = append(h.handlers, handler)
+}
+
+// call the handlers in the background so a slow one doesn't hold up the listener
+func (h *eventHandlers[T]) dispatch(name string, event T) {
+	go h.call(name, event)
+}
+
+// call each handler in the order they were added
+// a handler that panics is logged and the rest are still called
+func (h *eventHandlers[T]) call(name string, event T) {
+	h.mutex.RLock()
+	handlers := append([]func(T){}, h.handlers...)
+	h.mutex.RUnlock()
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error().Msgf("%s handler panicked: %v", name, r)
+				}
+			}()
+			handler(event)
+		}()
+	}
+}
+
+// subscribe again after a subscription has dropped, backing off between attempts
+// until we manage it or ctx is done - the handlers live on the event channels
+// rather than the subscription so they carry on being called from the new one
+// returns nil once ctx is done
+func resubscribe(
+	ctx context.Context,
+	options Web3Options,
+	name string,
+	dropErr error,
+	connect func() (event.Subscription, error),
+) event.Subscription {
+	delay := options.EventResubscribeBaseDelay
+	if delay <= 0 {
+		delay = DEFAULT_EVENT_RESUBSCRIBE_BASE_DELAY
+	}
+	maxDelay := options.EventResubscribeMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DEFAULT_EVENT_RESUBSCRIBE_MAX_DELAY
+	}
+	log.Warn().Msgf("%s subscription dropped: %v - resubscribing", name, dropErr)
+	for {
+		sub, err := connect()
+		if err == nil {
+			eventResubscribesTotal.WithLabelValues(name).Inc()
+			log.Info().Msgf("resubscribed to %s", name)
+			return sub
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Error().Msgf("error resubscribing to %s: %s - retrying in %s", name, err.Error(), delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
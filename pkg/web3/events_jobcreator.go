@@ -12,13 +12,12 @@ import (
 
 type JobCreatorEventChannels struct {
 	jobAddedChan chan *jobcreator.JobcreatorJobAdded
-	jobAddedSubs []func(jobcreator.JobcreatorJobAdded)
+	jobAddedSubs eventHandlers[jobcreator.JobcreatorJobAdded]
 }
 
 func NewJobCreatorEventChannels() *JobCreatorEventChannels {
 	return &JobCreatorEventChannels{
 		jobAddedChan: make(chan *jobcreator.JobcreatorJobAdded),
-		jobAddedSubs: []func(jobcreator.JobcreatorJobAdded){},
 	}
 }
 
@@ -49,30 +48,31 @@ func (s *JobCreatorEventChannels) Start(
 		return err
 	}
 
-	go func() {
-		<-ctx.Done()
-		jobAddedSub.Unsubscribe()
-	}()
-
+	// the subscription is replaced whenever it drops so we unsubscribe
+	// from whichever one is current when we are cancelled
 	for {
 		select {
+		case <-ctx.Done():
+			jobAddedSub.Unsubscribe()
+			return nil
 		case event := <-s.jobAddedChan:
 			log.Debug().
 				Str("storage->event", "DealStateChange").
 				Msgf("%+v", event)
-			for _, handler := range s.jobAddedSubs {
-				go handler(*event)
-			}
+			s.jobAddedSubs.dispatch("DealStateChange", *event)
 		case err := <-jobAddedSub.Err():
 			jobAddedSub.Unsubscribe()
-			jobAddedSub, err = connectJobAddedSub()
-			if err != nil {
-				return err
+			if ctx.Err() != nil {
+				return nil
+			}
+			jobAddedSub = resubscribe(ctx, sdk.Options, "JobAdded", err, connectJobAddedSub)
+			if jobAddedSub == nil {
+				return nil
 			}
 		}
 	}
 }
 
 func (t *JobCreatorEventChannels) SubscribeJobAdded(handler func(jobcreator.JobcreatorJobAdded)) {
-	t.jobAddedSubs = append(t.jobAddedSubs, handler)
+	t.jobAddedSubs.add(handler)
 }
@@ -12,13 +12,12 @@ import (
 
 type MediationEventChannels struct {
 	mediationRequestedChan chan *mediation.MediationMediationRequested
-	mediationRequestedSubs []func(mediation.MediationMediationRequested)
+	mediationRequestedSubs eventHandlers[mediation.MediationMediationRequested]
 }
 
 func NewMediationEventChannels() *MediationEventChannels {
 	return &MediationEventChannels{
 		mediationRequestedChan: make(chan *mediation.MediationMediationRequested),
-		mediationRequestedSubs: []func(mediation.MediationMediationRequested){},
 	}
 }
 
@@ -49,30 +48,31 @@ func (m *MediationEventChannels) Start(
 		return err
 	}
 
-	go func() {
-		<-ctx.Done()
-		mediationRequestedSub.Unsubscribe()
-	}()
-
+	// the subscription is replaced whenever it drops so we unsubscribe
+	// from whichever one is current when we are cancelled
 	for {
 		select {
+		case <-ctx.Done():
+			mediationRequestedSub.Unsubscribe()
+			return nil
 		case event := <-m.mediationRequestedChan:
 			log.Debug().
 				Str("mediation->event", "MediationRequested").
 				Msgf("%+v", event)
-			for _, handler := range m.mediationRequestedSubs {
-				go handler(*event)
-			}
+			m.mediationRequestedSubs.dispatch("MediationRequested", *event)
 		case err := <-mediationRequestedSub.Err():
 			mediationRequestedSub.Unsubscribe()
-			mediationRequestedSub, err = connectMediationRequestedSub()
-			if err != nil {
-				return err
+			if ctx.Err() != nil {
+				return nil
+			}
+			mediationRequestedSub = resubscribe(ctx, sdk.Options, "MediationRequested", err, connectMediationRequestedSub)
+			if mediationRequestedSub == nil {
+				return nil
 			}
 		}
 	}
 }
 
 func (m *MediationEventChannels) SubscribeMediationRequested(handler func(mediation.MediationMediationRequested)) {
-	m.mediationRequestedSubs = append(m.mediationRequestedSubs, handler)
+	m.mediationRequestedSubs.add(handler)
 }
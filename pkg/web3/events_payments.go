@@ -12,13 +12,12 @@ import (
 
 type PaymentEventChannels struct {
 	paymentChan chan *payments.PaymentsPayment
-	paymentSubs []func(payments.PaymentsPayment)
+	paymentSubs eventHandlers[payments.PaymentsPayment]
 }
 
 func NewPaymentEventChannels() *PaymentEventChannels {
 	return &PaymentEventChannels{
 		paymentChan: make(chan *payments.PaymentsPayment),
-		paymentSubs: []func(payments.PaymentsPayment){},
 	}
 }
 
@@ -49,30 +48,31 @@ func (p *PaymentEventChannels) Start(
 		return err
 	}
 
-	go func() {
-		<-ctx.Done()
-		paymentSub.Unsubscribe()
-	}()
-
+	// the subscription is replaced whenever it drops so we unsubscribe
+	// from whichever one is current when we are cancelled
 	for {
 		select {
+		case <-ctx.Done():
+			paymentSub.Unsubscribe()
+			return nil
 		case event := <-p.paymentChan:
 			log.Debug().
 				Str("payments->event", "Payment").
 				Msgf("%+v", event)
-			for _, handler := range p.paymentSubs {
-				go handler(*event)
-			}
+			p.paymentSubs.dispatch("Payment", *event)
 		case err := <-paymentSub.Err():
 			paymentSub.Unsubscribe()
-			paymentSub, err = connectPaymentSub()
-			if err != nil {
-				return err
+			if ctx.Err() != nil {
+				return nil
+			}
+			paymentSub = resubscribe(ctx, sdk.Options, "Payment", err, connectPaymentSub)
+			if paymentSub == nil {
+				return nil
 			}
 		}
 	}
 }
 
 func (p *PaymentEventChannels) SubscribePayment(handler func(payments.PaymentsPayment)) {
-	p.paymentSubs = append(p.paymentSubs, handler)
+	p.paymentSubs.add(handler)
 }
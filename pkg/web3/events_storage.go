@@ -12,13 +12,14 @@ import (
 
 type StorageEventChannels struct {
 	dealStateChangeChan chan *storage.StorageDealStateChange
-	dealStateChangeSubs []func(storage.StorageDealStateChange)
+	dealStateChangeSubs eventHandlers[storage.StorageDealStateChange]
+	// told whenever the subscription connects or drops
+	connectionSubs eventHandlers[bool]
 }
 
 func NewStorageEventChannels() *StorageEventChannels {
 	return &StorageEventChannels{
 		dealStateChangeChan: make(chan *storage.StorageDealStateChange),
-		dealStateChangeSubs: []func(storage.StorageDealStateChange){},
 	}
 }
 
@@ -48,31 +49,45 @@ func (s *StorageEventChannels) Start(
 	if err != nil {
 		return err
 	}
+	s.connectionSubs.call("DealStateChange connection", true)
 
-	go func() {
-		<-ctx.Done()
-		dealStateChangeSub.Unsubscribe()
-	}()
-
+	// the subscription is replaced whenever it drops so we unsubscribe
+	// from whichever one is current when we are cancelled
 	for {
 		select {
+		case <-ctx.Done():
+			dealStateChangeSub.Unsubscribe()
+			return nil
 		case event := <-s.dealStateChangeChan:
 			log.Debug().
 				Str("storage->event", "DealStateChange").
 				Msgf("%+v", event)
-			for _, handler := range s.dealStateChangeSubs {
-				go handler(*event)
-			}
+			s.dealStateChangeSubs.dispatch("DealStateChange", *event)
 		case err := <-dealStateChangeSub.Err():
 			dealStateChangeSub.Unsubscribe()
-			dealStateChangeSub, err = connectDealStateChangeSub()
-			if err != nil {
-				return err
+			if ctx.Err() != nil {
+				return nil
 			}
+			s.connectionSubs.call("DealStateChange connection", false)
+			// we are disconnected until resubscribe gets us a new subscription
+			dealStateChangeSub = resubscribe(ctx, sdk.Options, "DealStateChange", err, connectDealStateChangeSub)
+			if dealStateChangeSub == nil {
+				return nil
+			}
+			s.connectionSubs.call("DealStateChange connection", true)
 		}
 	}
 }
 
+// any number of handlers can be subscribed and each change is passed to
+// them one at a time in the order they were subscribed (see eventHandlers)
 func (t *StorageEventChannels) SubscribeDealStateChange(handler func(storage.StorageDealStateChange)) {
-	t.dealStateChangeSubs = append(t.dealStateChangeSubs, handler)
+	t.dealStateChangeSubs.add(handler)
+}
+
+// handler is called with true once we are subscribed to DealStateChange and
+// with false whenever the subscription drops - unlike events it is called
+// from the listener so the changes arrive in order
+func (t *StorageEventChannels) SubscribeConnection(handler func(connected bool)) {
+	t.connectionSubs.add(handler)
 }
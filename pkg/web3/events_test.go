@@ -0,0 +1,59 @@
+package web3
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHandlersAreCalledInOrder(t *testing.T) {
+	channels := NewStorageEventChannels()
+	called := []string{}
+	channels.SubscribeDealStateChange(func(ev storage.StorageDealStateChange) {
+		called = append(called, "first "+ev.DealId)
+	})
+	channels.SubscribeDealStateChange(func(ev storage.StorageDealStateChange) {
+		panic("broken handler")
+	})
+	channels.SubscribeDealStateChange(func(ev storage.StorageDealStateChange) {
+		called = append(called, "third "+ev.DealId)
+	})
+
+	channels.dealStateChangeSubs.call("DealStateChange", storage.StorageDealStateChange{DealId: "deal1"})
+	assert.Equal(t, []string{"first deal1", "third deal1"}, called, "A handler that panics should not stop the others")
+}
+
+func TestResubscribe(t *testing.T) {
+	options := Web3Options{EventResubscribeBaseDelay: time.Millisecond, EventResubscribeMaxDelay: 2 * time.Millisecond}
+	attempts := 0
+	connect := func() (event.Subscription, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, fmt.Errorf("websocket closed")
+		}
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			<-quit
+			return nil
+		}), nil
+	}
+	before := testutil.ToFloat64(eventResubscribesTotal.WithLabelValues("TestEvent"))
+
+	sub := resubscribe(context.Background(), options, "TestEvent", fmt.Errorf("dropped"), connect)
+	assert.NotNil(t, sub)
+	sub.Unsubscribe()
+	assert.Equal(t, 3, attempts, "Failed attempts should be retried")
+	assert.Equal(t, before+1, testutil.ToFloat64(eventResubscribesTotal.WithLabelValues("TestEvent")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sub = resubscribe(ctx, options, "TestEvent", fmt.Errorf("dropped"), func() (event.Subscription, error) {
+		return nil, fmt.Errorf("websocket closed")
+	})
+	assert.Nil(t, sub, "Should give up once the context is done")
+}
@@ -13,13 +13,12 @@ import (
 
 type TokenEventChannels struct {
 	transferChan chan *token.TokenTransfer
-	transferSubs []func(token.TokenTransfer)
+	transferSubs eventHandlers[token.TokenTransfer]
 }
 
 func NewTokenEventChannels() *TokenEventChannels {
 	return &TokenEventChannels{
 		transferChan: make(chan *token.TokenTransfer),
-		transferSubs: []func(token.TokenTransfer){},
 	}
 }
 
@@ -52,30 +51,31 @@ func (t *TokenEventChannels) Start(
 		return err
 	}
 
-	go func() {
-		<-ctx.Done()
-		transferSub.Unsubscribe()
-	}()
-
+	// the subscription is replaced whenever it drops so we unsubscribe
+	// from whichever one is current when we are cancelled
 	for {
 		select {
+		case <-ctx.Done():
+			transferSub.Unsubscribe()
+			return nil
 		case event := <-t.transferChan:
 			log.Debug().
 				Str("token->event", "Transfer").
 				Msgf("%+v", event)
-			for _, handler := range t.transferSubs {
-				go handler(*event)
-			}
+			t.transferSubs.dispatch("Transfer", *event)
 		case err := <-transferSub.Err():
 			transferSub.Unsubscribe()
-			transferSub, err = connectTransferSub()
-			if err != nil {
-				return err
+			if ctx.Err() != nil {
+				return nil
+			}
+			transferSub = resubscribe(ctx, sdk.Options, "Transfer", err, connectTransferSub)
+			if transferSub == nil {
+				return nil
 			}
 		}
 	}
 }
 
 func (t *TokenEventChannels) SubscribeTransfer(handler func(token.TokenTransfer)) {
-	t.transferSubs = append(t.transferSubs, handler)
+	t.transferSubs.add(handler)
 }
@@ -0,0 +1,287 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// how often we try to go back to the primary rpc node once we have failed over
+// if Web3Options.RpcPrimaryRetryInterval is not set
+const DEFAULT_RPC_PRIMARY_RETRY_INTERVAL = time.Minute
+
+// how long the health check of the primary can take before we stay where we are
+const RPC_HEALTH_CHECK_TIMEOUT = 5 * time.Second
+
+// an EthClient that spreads over a list of rpc nodes
+// every call goes to the current node and if we can't reach it we move down the
+// list and try the call again - the first url is the primary and once it passes
+// a health check (at most every retryInterval) we go back to it
+type failoverClient struct {
+	mutex   sync.Mutex
+	urls    []string
+	clients []EthClient
+	dial    func(url string) (EthClient, error)
+	// the index into urls that calls go to
+	current int
+	// the last time we checked if the primary is back
+	lastPrimaryCheck time.Time
+	retryInterval    time.Duration
+	service          system.Service
+}
+
+func newFailoverClient(
+	urls []string,
+	retryInterval time.Duration,
+	dial func(url string) (EthClient, error),
+	service system.Service,
+) (*failoverClient, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no rpc urls given")
+	}
+	if retryInterval <= 0 {
+		retryInterval = DEFAULT_RPC_PRIMARY_RETRY_INTERVAL
+	}
+	client := &failoverClient{
+		urls:          urls,
+		clients:       make([]EthClient, len(urls)),
+		dial:          dial,
+		retryInterval: retryInterval,
+		service:       service,
+	}
+	// make sure there is at least one node we can dial before we start
+	var errs []error
+	for index := range urls {
+		_, err := client.getClient(index)
+		if err == nil {
+			client.current = index
+			return client, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func dialEthClient(url string) (EthClient, error) {
+	return ethclient.Dial(url)
+}
+
+// clients are dialed the first time they are needed
+func (client *failoverClient) getClient(index int) (EthClient, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.clients[index] != nil {
+		return client.clients[index], nil
+	}
+	ethClient, err := client.dial(client.urls[index])
+	if err != nil {
+		return nil, fmt.Errorf("error dialing rpc node %d: %w", index, err)
+	}
+	client.clients[index] = ethClient
+	return ethClient, nil
+}
+
+func (client *failoverClient) getCurrent() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.current
+}
+
+// move on from a node we couldn't reach unless another call already has
+func (client *failoverClient) failover(from int, err error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.current != from {
+		return
+	}
+	client.current = (from + 1) % len(client.urls)
+	if from == 0 {
+		client.lastPrimaryCheck = time.Now()
+	}
+	system.Warn(client.service, fmt.Sprintf("rpc node %d failed, moving to rpc node %d", from, client.current), err)
+}
+
+// go back to the primary if it is answering again
+func (client *failoverClient) checkPrimary(ctx context.Context) {
+	client.mutex.Lock()
+	if client.current == 0 || time.Since(client.lastPrimaryCheck) < client.retryInterval {
+		client.mutex.Unlock()
+		return
+	}
+	client.lastPrimaryCheck = time.Now()
+	client.mutex.Unlock()
+
+	primary, err := client.getClient(0)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, RPC_HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+	_, err = primary.BlockNumber(ctx)
+	if err != nil {
+		return
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.current = 0
+	system.Info(client.service, "primary rpc node is back", client.urls[0])
+}
+
+// errors where the node answered (e.g. a revert or a nonce that's too low)
+// would be the same on any node so only the rest are worth failing over for
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == 429
+	}
+	return true
+}
+
+// run call against the current node and then each of the others in turn
+// until one of them can be reached
+func callWithFailover[T any](client *failoverClient, ctx context.Context, call func(EthClient) (T, error)) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client.checkPrimary(ctx)
+	var ret T
+	var err error
+	for range client.urls {
+		index := client.getCurrent()
+		var ethClient EthClient
+		ethClient, err = client.getClient(index)
+		if err == nil {
+			ret, err = call(ethClient)
+			if !isConnectionError(ctx, err) {
+				return ret, err
+			}
+		}
+		client.failover(index, err)
+	}
+	return ret, err
+}
+
+func (client *failoverClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) ([]byte, error) {
+		return ethClient.CodeAt(ctx, contract, blockNumber)
+	})
+}
+
+func (client *failoverClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) ([]byte, error) {
+		return ethClient.CallContract(ctx, call, blockNumber)
+	})
+}
+
+func (client *failoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (*types.Header, error) {
+		return ethClient.HeaderByNumber(ctx, number)
+	})
+}
+
+func (client *failoverClient) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) ([]byte, error) {
+		return ethClient.PendingCodeAt(ctx, account)
+	})
+}
+
+func (client *failoverClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (uint64, error) {
+		return ethClient.PendingNonceAt(ctx, account)
+	})
+}
+
+func (client *failoverClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (*big.Int, error) {
+		return ethClient.SuggestGasPrice(ctx)
+	})
+}
+
+func (client *failoverClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (*big.Int, error) {
+		return ethClient.SuggestGasTipCap(ctx)
+	})
+}
+
+func (client *failoverClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (uint64, error) {
+		return ethClient.EstimateGas(ctx, call)
+	})
+}
+
+// the tx is signed so sending it again to another node can't send it twice
+func (client *failoverClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	_, err := callWithFailover(client, ctx, func(ethClient EthClient) (struct{}, error) {
+		return struct{}{}, ethClient.SendTransaction(ctx, tx)
+	})
+	return err
+}
+
+func (client *failoverClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) ([]types.Log, error) {
+		return ethClient.FilterLogs(ctx, query)
+	})
+}
+
+// a subscription stays on the node it was made with
+// when that node goes the subscription errors and the event listeners
+// subscribe again, which is when they move to the next node
+func (client *failoverClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (ethereum.Subscription, error) {
+		return ethClient.SubscribeFilterLogs(ctx, query, ch)
+	})
+}
+
+func (client *failoverClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (*types.Receipt, error) {
+		return ethClient.TransactionReceipt(ctx, txHash)
+	})
+}
+
+func (client *failoverClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (*big.Int, error) {
+		return ethClient.ChainID(ctx)
+	})
+}
+
+func (client *failoverClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return callWithFailover(client, ctx, func(ethClient EthClient) (uint64, error) {
+		return ethClient.BlockNumber(ctx)
+	})
+}
+
+type transactionByHashResult struct {
+	tx        *types.Transaction
+	isPending bool
+}
+
+func (client *failoverClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	result, err := callWithFailover(client, ctx, func(ethClient EthClient) (transactionByHashResult, error) {
+		tx, isPending, err := ethClient.TransactionByHash(ctx, hash)
+		return transactionByHashResult{tx: tx, isPending: isPending}, err
+	})
+	return result.tx, result.isPending, err
+}
+
+// Compile-time interface check:
+var _ EthClient = (*failoverClient)(nil)
@@ -0,0 +1,70 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/stretchr/testify/assert"
+)
+
+// only BlockNumber is used by these tests
+type fakeEthClient struct {
+	EthClient
+	blockNumber uint64
+	err         error
+}
+
+func (client *fakeEthClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return client.blockNumber, client.err
+}
+
+// what the node sends back for a call it ran e.g. a revert
+type fakeRPCError struct{}
+
+func (err fakeRPCError) Error() string  { return "execution reverted" }
+func (err fakeRPCError) ErrorCode() int { return 3 }
+
+func TestFailoverClient(t *testing.T) {
+	primary := &fakeEthClient{blockNumber: 1, err: fmt.Errorf("connection refused")}
+	fallback := &fakeEthClient{blockNumber: 2}
+	nodes := map[string]*fakeEthClient{"primary": primary, "fallback": fallback}
+	client, err := newFailoverClient([]string{"primary", "fallback"}, time.Millisecond, func(url string) (EthClient, error) {
+		return nodes[url], nil
+	}, system.DefaultService)
+	assert.NoError(t, err)
+
+	blockNumber, err := client.BlockNumber(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), blockNumber, "We should fail over when the primary can't be reached")
+
+	fallback.err = fakeRPCError{}
+	_, err = client.BlockNumber(context.Background())
+	assert.ErrorIs(t, err, fallback.err, "An error from a node that answered should not fail over")
+	assert.Equal(t, 1, client.getCurrent())
+
+	primary.err = nil
+	time.Sleep(2 * time.Millisecond)
+	blockNumber, err = client.BlockNumber(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), blockNumber, "We should go back to the primary once it is healthy")
+}
+
+func TestFailoverClientDialsFallback(t *testing.T) {
+	client, err := newFailoverClient([]string{"primary", "fallback"}, 0, func(url string) (EthClient, error) {
+		if url == "primary" {
+			return nil, fmt.Errorf("dial failed")
+		}
+		return &fakeEthClient{blockNumber: 2}, nil
+	}, system.DefaultService)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, client.getCurrent(), "We should start on a fallback if the primary can't be dialed")
+
+	_, err = newFailoverClient([]string{"primary"}, 0, func(url string) (EthClient, error) {
+		return nil, errors.New("dial failed")
+	}, system.DefaultService)
+	assert.Error(t, err)
+}
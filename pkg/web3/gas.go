@@ -0,0 +1,244 @@
+package web3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// how we price the gas for the txs we send
+const (
+	// let go-ethereum pick (the suggested tip plus twice the base fee)
+	GasStrategyDefault = ""
+	// always pay WEB3_GAS_PRICE
+	GasStrategyFixed = "fixed"
+	// pay the node's suggested gas price times WEB3_GAS_MULTIPLIER
+	GasStrategyMultiplier = "multiplier"
+	// EIP-1559 fees capped by WEB3_MAX_FEE_PER_GAS and WEB3_MAX_PRIORITY_FEE_PER_GAS
+	GasStrategyEIP1559 = "eip1559"
+)
+
+// the node has to see at least this much of a bump to replace a pending tx
+const MIN_GAS_BUMP_PERCENT = 10
+
+// the bits of the eth client we need to price gas
+type gasPriceSuggester interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// either GasPrice is set for a legacy tx
+// or GasFeeCap and GasTipCap are set for a dynamic fee tx
+// if none are set we leave it to go-ethereum
+type gasPrices struct {
+	GasPrice  *big.Int
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+}
+
+func CheckGasOptions(options Web3Options) error {
+	switch options.GasStrategy {
+	case GasStrategyDefault, GasStrategyEIP1559:
+	case GasStrategyFixed:
+		if options.GasPrice == 0 {
+			return fmt.Errorf("WEB3_GAS_PRICE is required for the %s gas strategy", GasStrategyFixed)
+		}
+	case GasStrategyMultiplier:
+		if options.GasMultiplier <= 0 {
+			return fmt.Errorf("WEB3_GAS_MULTIPLIER must be greater than zero")
+		}
+	default:
+		return fmt.Errorf("unknown gas strategy %q (expected %s, %s or %s)",
+			options.GasStrategy, GasStrategyFixed, GasStrategyMultiplier, GasStrategyEIP1559)
+	}
+	if options.StuckTxTimeout < 0 {
+		return fmt.Errorf("WEB3_STUCK_TX_TIMEOUT cannot be negative")
+	}
+	if options.StuckTxTimeout > 0 && options.GasBumpPercent < MIN_GAS_BUMP_PERCENT {
+		return fmt.Errorf("WEB3_GAS_BUMP_PERCENT must be at least %d", MIN_GAS_BUMP_PERCENT)
+	}
+	return nil
+}
+
+// work out what to pay for gas for a new tx
+func getGasPrices(ctx context.Context, options Web3Options, client gasPriceSuggester) (gasPrices, error) {
+	switch options.GasStrategy {
+	case GasStrategyFixed:
+		return gasPrices{
+			GasPrice: new(big.Int).SetUint64(options.GasPrice),
+		}, nil
+	case GasStrategyMultiplier:
+		suggested, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return gasPrices{}, fmt.Errorf("error getting suggested gas price: %w", err)
+		}
+		gasPrice, _ := new(big.Float).Mul(
+			new(big.Float).SetInt(suggested),
+			big.NewFloat(options.GasMultiplier),
+		).Int(nil)
+		return gasPrices{
+			GasPrice: capGas(gasPrice, options.MaxFeePerGas),
+		}, nil
+	case GasStrategyEIP1559:
+		tipCap, err := client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return gasPrices{}, fmt.Errorf("error getting suggested gas tip: %w", err)
+		}
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return gasPrices{}, fmt.Errorf("error getting latest block: %w", err)
+		}
+		if header.BaseFee == nil {
+			return gasPrices{}, fmt.Errorf("the chain does not support the %s gas strategy", GasStrategyEIP1559)
+		}
+		tipCap = capGas(tipCap, options.MaxPriorityFeePerGas)
+		// the same headroom go-ethereum gives so the tx survives the base fee rising
+		feeCap := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tipCap)
+		feeCap = capGas(feeCap, options.MaxFeePerGas)
+		if tipCap.Cmp(feeCap) > 0 {
+			tipCap = new(big.Int).Set(feeCap)
+		}
+		return gasPrices{
+			GasFeeCap: feeCap,
+			GasTipCap: tipCap,
+		}, nil
+	}
+	return gasPrices{}, nil
+}
+
+// the prices to resend a stuck tx with
+// ok is false if the caps mean we can't bump it enough for the node to accept it
+func bumpGasPrices(tx *types.Transaction, options Web3Options) (gasPrices, bool) {
+	if tx.Type() == types.DynamicFeeTxType {
+		feeCap := capGas(bumpGas(tx.GasFeeCap(), options.GasBumpPercent), options.MaxFeePerGas)
+		tipCap := capGas(bumpGas(tx.GasTipCap(), options.GasBumpPercent), options.MaxPriorityFeePerGas)
+		if tipCap.Cmp(feeCap) > 0 {
+			tipCap = new(big.Int).Set(feeCap)
+		}
+		if !isEnoughBump(tx.GasFeeCap(), feeCap) || !isEnoughBump(tx.GasTipCap(), tipCap) {
+			return gasPrices{}, false
+		}
+		return gasPrices{
+			GasFeeCap: feeCap,
+			GasTipCap: tipCap,
+		}, true
+	}
+	gasPrice := capGas(bumpGas(tx.GasPrice(), options.GasBumpPercent), options.MaxFeePerGas)
+	if !isEnoughBump(tx.GasPrice(), gasPrice) {
+		return gasPrices{}, false
+	}
+	return gasPrices{
+		GasPrice: gasPrice,
+	}, true
+}
+
+func bumpGas(value *big.Int, percent int) *big.Int {
+	bumped := new(big.Int).Mul(value, big.NewInt(int64(100+percent)))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// a cap of zero means there is no cap
+func capGas(value *big.Int, max uint64) *big.Int {
+	if max > 0 && value.Cmp(new(big.Int).SetUint64(max)) > 0 {
+		return new(big.Int).SetUint64(max)
+	}
+	return value
+}
+
+func isEnoughBump(previous *big.Int, next *big.Int) bool {
+	return next.Cmp(bumpGas(previous, MIN_GAS_BUMP_PERCENT)) >= 0
+}
+
+func (sdk *Web3SDK) applyGasStrategy(ctx context.Context, opts *bind.TransactOpts) error {
+	prices, err := getGasPrices(ctx, sdk.Options, sdk.Client)
+	if err != nil {
+		return err
+	}
+	opts.GasPrice = prices.GasPrice
+	opts.GasFeeCap = prices.GasFeeCap
+	opts.GasTipCap = prices.GasTipCap
+	return nil
+}
+
+// wait for a tx to be mined and if it takes longer than WEB3_STUCK_TX_TIMEOUT
+// resend it with the same nonce and more gas
+// we return the receipt of whichever version gets mined
+func (sdk *Web3SDK) waitTxWithResubmit(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	sent := []*types.Transaction{tx}
+	for {
+		latest := sent[len(sent)-1]
+		waitCtx, cancel := context.WithTimeout(ctx, sdk.Options.StuckTxTimeout)
+		receipt, err := bind.WaitMined(waitCtx, sdk.Client, latest)
+		cancel()
+		if err == nil {
+			return receipt, nil
+		}
+		if ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		// one of the earlier versions might have been mined in the meantime
+		for _, previous := range sent[:len(sent)-1] {
+			receipt, err := sdk.Client.TransactionReceipt(ctx, previous.Hash())
+			if err == nil {
+				return receipt, nil
+			}
+		}
+		replacement, err := sdk.resubmitTx(ctx, latest)
+		if err != nil {
+			system.Error(sdk.Options.Service, "error resubmitting stuck tx", err)
+			continue
+		}
+		if replacement == nil {
+			system.Warn(sdk.Options.Service, "tx is stuck but the gas caps stop us bumping it", latest.Hash().String())
+			continue
+		}
+		system.Info(sdk.Options.Service, "resubmitted stuck tx", latest.Hash().String(), system.F("replacement", replacement.Hash().String()))
+		sent = append(sent, replacement)
+	}
+}
+
+// send a copy of tx with bumped gas
+// returns nil if the gas caps mean we can't
+func (sdk *Web3SDK) resubmitTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	prices, ok := bumpGasPrices(tx, sdk.Options)
+	if !ok {
+		return nil, nil
+	}
+	var unsigned *types.Transaction
+	if prices.GasPrice != nil {
+		unsigned = types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: prices.GasPrice,
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		})
+	} else {
+		unsigned = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   tx.ChainId(),
+			Nonce:     tx.Nonce(),
+			GasTipCap: prices.GasTipCap,
+			GasFeeCap: prices.GasFeeCap,
+			Gas:       tx.Gas(),
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Data:      tx.Data(),
+		})
+	}
+	signed, err := sdk.TransactOpts.Signer(sdk.TransactOpts.From, unsigned)
+	if err != nil {
+		return nil, err
+	}
+	err = sdk.Client.SendTransaction(ctx, signed)
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
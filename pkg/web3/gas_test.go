@@ -0,0 +1,81 @@
+package web3
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGasPriceSuggester struct {
+	gasPrice *big.Int
+	tipCap   *big.Int
+	baseFee  *big.Int
+}
+
+func (fake *fakeGasPriceSuggester) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return fake.gasPrice, nil
+}
+
+func (fake *fakeGasPriceSuggester) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return fake.tipCap, nil
+}
+
+func (fake *fakeGasPriceSuggester) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: fake.baseFee}, nil
+}
+
+func TestGetGasPrices(t *testing.T) {
+	client := &fakeGasPriceSuggester{
+		gasPrice: big.NewInt(100),
+		tipCap:   big.NewInt(10),
+		baseFee:  big.NewInt(50),
+	}
+
+	prices, err := getGasPrices(context.Background(), Web3Options{}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, gasPrices{}, prices, "The default strategy should leave it to go-ethereum")
+
+	prices, err = getGasPrices(context.Background(), Web3Options{GasStrategy: GasStrategyFixed, GasPrice: 42}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(42), prices.GasPrice)
+
+	prices, err = getGasPrices(context.Background(), Web3Options{GasStrategy: GasStrategyMultiplier, GasMultiplier: 1.5}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(150), prices.GasPrice)
+
+	prices, err = getGasPrices(context.Background(), Web3Options{GasStrategy: GasStrategyEIP1559}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(110), prices.GasFeeCap)
+	assert.Equal(t, big.NewInt(10), prices.GasTipCap)
+
+	prices, err = getGasPrices(context.Background(), Web3Options{
+		GasStrategy:          GasStrategyEIP1559,
+		MaxFeePerGas:         80,
+		MaxPriorityFeePerGas: 5,
+	}, client)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(80), prices.GasFeeCap, "The fee cap should be capped")
+	assert.Equal(t, big.NewInt(5), prices.GasTipCap, "The tip should be capped")
+}
+
+func TestBumpGasPrices(t *testing.T) {
+	legacy := types.NewTx(&types.LegacyTx{GasPrice: big.NewInt(100)})
+	prices, ok := bumpGasPrices(legacy, Web3Options{GasBumpPercent: 20})
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(120), prices.GasPrice)
+
+	_, ok = bumpGasPrices(legacy, Web3Options{GasBumpPercent: 20, MaxFeePerGas: 105})
+	assert.False(t, ok, "A bump the node would reject should not be sent")
+
+	dynamic := types.NewTx(&types.DynamicFeeTx{GasFeeCap: big.NewInt(100), GasTipCap: big.NewInt(10)})
+	prices, ok = bumpGasPrices(dynamic, Web3Options{GasBumpPercent: 20})
+	assert.True(t, ok)
+	assert.Equal(t, big.NewInt(120), prices.GasFeeCap)
+	assert.Equal(t, big.NewInt(12), prices.GasTipCap)
+
+	_, ok = bumpGasPrices(dynamic, Web3Options{GasBumpPercent: 20, MaxPriorityFeePerGas: 10})
+	assert.False(t, ok, "Both fees need bumping for the node to replace the tx")
+}
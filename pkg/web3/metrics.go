@@ -0,0 +1,119 @@
+package web3
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/controller"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/jobcreator"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/mediation"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/payments"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/token"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/users"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const METRICS_NAMESPACE = "lilypad_web3"
+
+// the type label for txs that don't call a method on one of our contracts
+const UNKNOWN_TX_TYPE = "unknown"
+
+var (
+	txGasUsedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "tx_gas_used_total",
+		Help:      "The gas used by the txs we have had mined, by contract method.",
+	}, []string{"type"})
+	txCostWeiTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "tx_cost_wei_total",
+		Help:      "What the txs we have had mined cost in wei (gas used times effective gas price), by contract method.",
+	}, []string{"type"})
+	txEffectiveGasPrice = promauto.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace:  METRICS_NAMESPACE,
+		Name:       "tx_effective_gas_price_wei",
+		Help:       "The effective gas price in wei of the txs we have had mined, by contract method.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}, //nolint:gomnd
+	}, []string{"type"})
+	txRevertedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "tx_reverted_total",
+		Help:      "The number of our txs that were mined but reverted, by contract method.",
+	}, []string{"type"})
+	eventResubscribesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "event_resubscribes_total",
+		Help:      "The number of times we have subscribed to a contract event again after the subscription dropped.",
+	}, []string{"event"})
+)
+
+var (
+	txTypesOnce sync.Once
+	// method selector onto method name for every contract we send txs to
+	txTypes map[string]string
+)
+
+func loadTxTypes() {
+	txTypes = map[string]string{}
+	for _, metaData := range []*bind.MetaData{
+		controller.ControllerMetaData,
+		jobcreator.JobcreatorMetaData,
+		mediation.MediationMetaData,
+		payments.PaymentsMetaData,
+		storage.StorageMetaData,
+		token.TokenMetaData,
+		users.UsersMetaData,
+	} {
+		parsed, err := metaData.GetAbi()
+		if err != nil {
+			continue
+		}
+		for _, method := range parsed.Methods {
+			// the controller comes first so it names the methods it forwards
+			if _, ok := txTypes[string(method.ID)]; !ok {
+				txTypes[string(method.ID)] = method.Name
+			}
+		}
+	}
+}
+
+// the contract method a tx calls e.g. agree
+func getTxType(tx *types.Transaction) string {
+	txTypesOnce.Do(loadTxTypes)
+	if len(tx.Data()) < 4 { //nolint:gomnd
+		return UNKNOWN_TX_TYPE
+	}
+	if name, ok := txTypes[string(tx.Data()[:4])]; ok {
+		return name
+	}
+	return UNKNOWN_TX_TYPE
+}
+
+// a reverted tx still pays for its gas so we record every mined tx
+func (sdk *Web3SDK) recordTxCost(tx *types.Transaction, receipt *types.Receipt) {
+	txType := getTxType(tx)
+	gasPrice := receipt.EffectiveGasPrice
+	// older nodes don't tell us what was actually paid
+	if gasPrice == nil {
+		gasPrice = tx.GasPrice()
+	}
+	cost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	costFloat, _ := new(big.Float).SetInt(cost).Float64()
+	gasPriceFloat, _ := new(big.Float).SetInt(gasPrice).Float64()
+
+	txGasUsedTotal.WithLabelValues(txType).Add(float64(receipt.GasUsed))
+	txCostWeiTotal.WithLabelValues(txType).Add(costFloat)
+	txEffectiveGasPrice.WithLabelValues(txType).Observe(gasPriceFloat)
+
+	system.Info(sdk.Options.Service, "tx mined", receipt.TxHash.String(),
+		system.F("type", txType),
+		system.F("gasUsed", receipt.GasUsed),
+		system.F("effectiveGasPrice", gasPrice.String()),
+		system.F("costWei", cost.String()),
+	)
+}
@@ -0,0 +1,19 @@
+package web3
+
+import (
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/controller"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTxType(t *testing.T) {
+	parsed, err := controller.ControllerMetaData.GetAbi()
+	assert.NoError(t, err)
+	tx := types.NewTx(&types.LegacyTx{Data: parsed.Methods["agree"].ID})
+	assert.Equal(t, "agree", getTxType(tx))
+
+	tx = types.NewTx(&types.LegacyTx{Data: []byte{1, 2, 3, 4}})
+	assert.Equal(t, UNKNOWN_TX_TYPE, getTxType(tx), "Txs that don't call our contracts should still be counted")
+}
@@ -0,0 +1,80 @@
+package web3
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// hands out sequential nonces for the txs we send
+// asking the node for the pending nonce each time races with txs it hasn't
+// seen yet (e.g. behind a load balancer) and gives "nonce too low" errors
+// so we only ask it when we start and after a tx fails to send
+type nonceManager struct {
+	mutex           sync.Mutex
+	getPendingNonce func(ctx context.Context) (uint64, error)
+	next            uint64
+	synced          bool
+}
+
+func newNonceManager(getPendingNonce func(ctx context.Context) (uint64, error)) *nonceManager {
+	return &nonceManager{
+		getPendingNonce: getPendingNonce,
+	}
+}
+
+// the nonce to use for the next tx
+func (manager *nonceManager) reserve(ctx context.Context) (uint64, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if !manager.synced {
+		nonce, err := manager.getPendingNonce(ctx)
+		if err != nil {
+			return 0, err
+		}
+		manager.next = nonce
+		manager.synced = true
+	}
+	nonce := manager.next
+	manager.next++
+	return nonce, nil
+}
+
+// we don't know if the last nonce was used so ask the chain next time
+func (manager *nonceManager) resync() {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	manager.synced = false
+}
+
+// SendTx calls send with our transact opts and the next nonce
+// every tx should go through this so the nonces stay in step
+// txs are sent one at a time so the nonces reach the node in order
+func (sdk *Web3SDK) SendTx(
+	ctx context.Context,
+	send func(opts *bind.TransactOpts) (*types.Transaction, error),
+) (*types.Transaction, error) {
+	sdk.txMutex.Lock()
+	defer sdk.txMutex.Unlock()
+	nonce, err := sdk.nonces.reserve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := *sdk.TransactOpts
+	opts.Context = ctx
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	err = sdk.applyGasStrategy(ctx, &opts)
+	if err != nil {
+		sdk.nonces.resync()
+		return nil, err
+	}
+	tx, err := send(&opts)
+	if err != nil {
+		sdk.nonces.resync()
+		return nil, err
+	}
+	return tx, nil
+}
@@ -0,0 +1,32 @@
+package web3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonceManager(t *testing.T) {
+	chainNonce := uint64(5)
+	calls := 0
+	manager := newNonceManager(func(ctx context.Context) (uint64, error) {
+		calls++
+		return chainNonce, nil
+	})
+
+	for _, expected := range []uint64{5, 6, 7} {
+		nonce, err := manager.reserve(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, nonce, "Nonces should be handed out in order")
+	}
+	assert.Equal(t, 1, calls, "The chain should only be asked once while we are in sync")
+
+	// pretend the tx with nonce 7 never made it
+	chainNonce = 7
+	manager.resync()
+	nonce, err := manager.reserve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), nonce, "A failed send should resync from the chain")
+	assert.Equal(t, 2, calls)
+}
@@ -2,11 +2,13 @@ package web3
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
+	"fmt"
 	"math/big"
-	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/bacalhau-project/lilypad/pkg/system"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/controller"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/jobcreator"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/mediation"
@@ -14,11 +16,13 @@ import (
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/token"
 	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/users"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
 )
 
@@ -33,18 +37,32 @@ type Contracts struct {
 	Controller *controller.Controller
 }
 
+// the parts of an eth node we use
+// this is an *ethclient.Client unless we are on a simulated chain
+// or have fallback rpc nodes (see failover.go)
+type EthClient interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+}
+
 type Web3SDK struct {
 	Options      Web3Options
-	PrivateKey   *ecdsa.PrivateKey
-	Client       *ethclient.Client
+	Signer       Signer
+	Client       EthClient
 	CallOpts     *bind.CallOpts
 	TransactOpts *bind.TransactOpts
 	Contracts    *Contracts
+	// we only let one tx be submitted at a time (see sendTx)
+	txMutex sync.Mutex
+	nonces  *nonceManager
 }
 
 func NewContracts(
 	options Web3Options,
-	client *ethclient.Client,
+	client EthClient,
 	callOpts *bind.CallOpts,
 ) (*Contracts, error) {
 	controller, err := controller.NewController(common.HexToAddress(options.ControllerAddress), client)
@@ -173,15 +191,32 @@ func NewContracts(
 func NewContractSDK(options Web3Options) (*Web3SDK, error) {
 	// write to console
 	log.Debug().Msgf("NewContractSDK: %+v", options)
-	client, err := ethclient.Dial(options.RpcURL)
+	if options.Mode == Web3ModeSimulated {
+		return NewSimulatedContractSDK(options)
+	}
+	var client EthClient
+	var err error
+	if len(options.RpcFallbackURLs) > 0 {
+		client, err = newFailoverClient(
+			append([]string{options.RpcURL}, options.RpcFallbackURLs...),
+			options.RpcPrimaryRetryInterval,
+			dialEthClient,
+			options.Service,
+		)
+	} else {
+		client, err = ethclient.Dial(options.RpcURL)
+	}
 	if err != nil {
 		return nil, err
 	}
-	privateKey, err := ParsePrivateKey(options.PrivateKey)
+	signer, err := NewSigner(options)
 	if err != nil {
 		return nil, err
 	}
+	return newContractSDK(options, client, signer)
+}
 
+func newContractSDK(options Web3Options, client EthClient, signer Signer) (*Web3SDK, error) {
 	callOpts := &bind.CallOpts{
 		Pending:     false,
 		From:        common.Address{},
@@ -189,39 +224,178 @@ func NewContractSDK(options Web3Options) (*Web3SDK, error) {
 		Context:     nil,
 	}
 
-	transactOpts, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(int64(options.ChainID)))
-	if err != nil {
-		return nil, err
-	}
+	transactOpts := NewSignerTransactOpts(signer, big.NewInt(int64(options.ChainID)))
 	contracts, err := NewContracts(options, client, callOpts)
 	if err != nil {
 		return nil, err
 	}
 	return &Web3SDK{
-		PrivateKey:   privateKey,
+		Signer:       signer,
 		Options:      options,
 		Client:       client,
 		CallOpts:     callOpts,
 		TransactOpts: transactOpts,
 		Contracts:    contracts,
+		nonces: newNonceManager(func(ctx context.Context) (uint64, error) {
+			return client.PendingNonceAt(ctx, signer.GetAddress())
+		}),
 	}, nil
 }
 
+// make sure the rpc node is on the chain we expect so we don't post offers
+// and agree to deals on a network where we earn nothing
+func (sdk *Web3SDK) CheckChainID(ctx context.Context) error {
+	if sdk.Options.ExpectedChainID == 0 {
+		return nil
+	}
+	chainID, err := sdk.Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting chain id: %w", err)
+	}
+	return checkChainID(sdk.Options.ExpectedChainID, chainID)
+}
+
+func checkChainID(expected int, actual *big.Int) error {
+	if actual.Cmp(big.NewInt(int64(expected))) != 0 {
+		return fmt.Errorf("rpc node is on the wrong chain: expected chain id %d but got %s", expected, actual.String())
+	}
+	return nil
+}
+
 func (sdk *Web3SDK) getBlockNumber() (uint64, error) {
-	var blockNumberHex string
-	err := sdk.Client.Client().Call(&blockNumberHex, "eth_blockNumber")
+	blockNumber, err := sdk.Client.BlockNumber(context.Background())
 	if err != nil {
 		log.Error().Msgf("error for getBlockNumber: %s", err.Error())
 		return 0, err
 	}
-	blockNumberHex = strings.TrimPrefix(blockNumberHex, "0x")
-	return strconv.ParseUint(blockNumberHex, 16, 64)
+	return blockNumber, nil
 }
 
+// the receipt might be for a resubmitted copy of tx (see waitTxWithResubmit)
+// so use receipt.TxHash rather than tx.Hash() for the hash that was mined
 func (sdk *Web3SDK) WaitTx(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
-	return bind.WaitMined(ctx, sdk.Client, tx)
+	var receipt *types.Receipt
+	var err error
+	if sdk.Options.StuckTxTimeout > 0 {
+		receipt, err = sdk.waitTxWithResubmit(ctx, tx)
+	} else {
+		receipt, err = bind.WaitMined(ctx, sdk.Client, tx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sdk.recordTxCost(tx, receipt)
+	return receipt, nil
+}
+
+// wait for the tx to be mined and return an error if it was reverted
+// a reverted tx has still been mined so WaitTx alone would look like success
+// the error wraps a RevertError with the reason if we can get it from the node
+func (sdk *Web3SDK) WaitTxSuccess(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	receipt, err := sdk.WaitTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		revertErr := &RevertError{Reason: sdk.getRevertReason(ctx, tx, receipt)}
+		txType := getTxType(tx)
+		txRevertedTotal.WithLabelValues(txType).Inc()
+		system.Error(sdk.Options.Service, "tx reverted", revertErr,
+			system.F("txHash", receipt.TxHash.String()),
+			system.F("type", txType),
+			system.F("reason", revertErr.Reason),
+			system.F("blockNumber", receipt.BlockNumber.String()),
+		)
+		return receipt, fmt.Errorf("tx %s reverted: %w", receipt.TxHash.String(), revertErr)
+	}
+	return receipt, nil
+}
+
+// the same as WaitTxSuccess but for a tx we only know the hash of
+// e.g. one we sent before we restarted
+func (sdk *Web3SDK) WaitTxHashSuccess(ctx context.Context, txHash string) (*types.Receipt, error) {
+	tx, _, err := sdk.Client.TransactionByHash(ctx, common.HexToHash(txHash))
+	if err != nil {
+		return nil, err
+	}
+	return sdk.WaitTxSuccess(ctx, tx)
+}
+
+// IsTxPending is true while the tx is waiting to be mined
+// a tx that has been mined or has dropped out of the node's pool is not pending
+func (sdk *Web3SDK) IsTxPending(ctx context.Context, txHash string) (bool, error) {
+	hash := common.HexToHash(txHash)
+	_, err := sdk.Client.TransactionReceipt(ctx, hash)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return false, err
+	}
+	_, _, err = sdk.Client.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replay a reverted tx as a call against the state before it was mined
+// so the node will tell us why it reverted
+func (sdk *Web3SDK) getRevertReason(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) string {
+	var blockNumber *big.Int
+	if receipt.BlockNumber != nil && receipt.BlockNumber.Sign() > 0 {
+		blockNumber = new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	}
+	_, err := sdk.Client.CallContract(ctx, ethereum.CallMsg{
+		From:     sdk.GetAddress(),
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}, blockNumber)
+	if err == nil {
+		return "unknown reason"
+	}
+	return getRevertReasonFromError(err)
+}
+
+// the reason string the contract reverted with if the node sent us the revert data
+func getRevertReasonFromError(err error) string {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if revertData, ok := dataErr.ErrorData().(string); ok {
+			reason, unpackErr := abi.UnpackRevert(common.FromHex(revertData))
+			if unpackErr == nil {
+				return reason
+			}
+		}
+	}
+	return err.Error()
+}
+
+// a call that the contract reverted, as opposed to one we couldn't make
+type RevertError struct {
+	Reason string
+}
+
+func (err *RevertError) Error() string {
+	return fmt.Sprintf("execution reverted: %s", err.Reason)
+}
+
+// nodes report reverts with error code 3 and the revert data
+// but not every node sends the data so we also go by the message
+func isRevertError(err error) bool {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+		return true
+	}
+	return strings.Contains(err.Error(), "execution reverted")
 }
 
 func (sdk *Web3SDK) GetAddress() common.Address {
-	return crypto.PubkeyToAddress(GetPublicKey(sdk.PrivateKey))
+	return sdk.Signer.GetAddress()
 }
@@ -0,0 +1,108 @@
+package web3
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Signer signs transactions and messages on behalf of an address
+// so the things sending them don't need to hold a raw private key
+type Signer interface {
+	GetAddress() common.Address
+	// sign a tx that will be sent to the chain
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// sign the keccak256 hash of message - this is what the solver checks
+	SignMessage(message []byte) ([]byte, error)
+}
+
+// the default signer that uses a private key we already have in memory
+type PrivateKeySigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+func NewPrivateKeySigner(privateKey *ecdsa.PrivateKey) *PrivateKeySigner {
+	return &PrivateKeySigner{
+		privateKey: privateKey,
+	}
+}
+
+func (signer *PrivateKeySigner) GetAddress() common.Address {
+	return GetAddress(signer.privateKey)
+}
+
+func (signer *PrivateKeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), signer.privateKey)
+}
+
+func (signer *PrivateKeySigner) SignMessage(message []byte) ([]byte, error) {
+	return SignMessage(signer.privateKey, message)
+}
+
+// a signer backed by an encrypted keystore file as written by geth or clef
+// the key is only ever decrypted in memory so there is no raw key on disk
+type KeystoreSigner struct {
+	key *keystore.Key
+}
+
+func NewKeystoreSigner(path string, password string) (*KeystoreSigner, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keystore file: %w", err)
+	}
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting keystore file %s: %w", path, err)
+	}
+	return &KeystoreSigner{
+		key: key,
+	}, nil
+}
+
+func (signer *KeystoreSigner) GetAddress() common.Address {
+	return signer.key.Address
+}
+
+func (signer *KeystoreSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), signer.key.PrivateKey)
+}
+
+func (signer *KeystoreSigner) SignMessage(message []byte) ([]byte, error) {
+	return SignMessage(signer.key.PrivateKey, message)
+}
+
+// pick the signer the options ask for
+// the keystore wins if it is set because ResolvePrivateKey won't allow both
+func NewSigner(options Web3Options) (Signer, error) {
+	if options.KeystorePath != "" {
+		return NewKeystoreSigner(options.KeystorePath, options.KeystorePassword)
+	}
+	if options.PrivateKey == "" {
+		return nil, fmt.Errorf("private key or keystore is required")
+	}
+	privateKey, err := ParsePrivateKey(options.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKeySigner(privateKey), nil
+}
+
+// the transact opts the contract bindings use to send txs from the signer
+func NewSignerTransactOpts(signer Signer, chainID *big.Int) *bind.TransactOpts {
+	address := signer.GetAddress()
+	return &bind.TransactOpts{
+		From: address,
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(tx, chainID)
+		},
+	}
+}
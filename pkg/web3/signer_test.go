@@ -0,0 +1,32 @@
+package web3
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystoreSigner(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(privateKey, "password")
+	assert.NoError(t, err)
+	keyFile := account.URL.Path
+
+	_, err = NewSigner(Web3Options{KeystorePath: keyFile, KeystorePassword: "wrong"})
+	assert.Error(t, err, "The wrong password should be an error")
+
+	signer, err := NewSigner(Web3Options{KeystorePath: keyFile, KeystorePassword: "password"})
+	assert.NoError(t, err)
+	assert.Equal(t, GetAddress(privateKey), signer.GetAddress())
+
+	message := []byte("hello")
+	signature, err := signer.SignMessage(message)
+	assert.NoError(t, err)
+	address, err := GetAddressFromSignedMessage(message, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, GetAddress(privateKey), address, "The signature should recover to the keystore address")
+}
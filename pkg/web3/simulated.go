@@ -0,0 +1,157 @@
+package web3
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/controller"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/jobcreator"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/mediation"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/payments"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/storage"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/token"
+	"github.com/bacalhau-project/lilypad/pkg/web3/bindings/users"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// where the sdk sends its txs
+const (
+	// a real node at RpcURL
+	Web3ModeRPC = ""
+	// an in-memory chain with our contracts deployed on it for local development
+	// nothing on it is shared with anyone else so a local solver won't see our txs
+	Web3ModeSimulated = "simulated"
+)
+
+// enough for deploying the controller with plenty to spare
+const SIMULATED_GAS_LIMIT = 30_000_000
+
+// the simulated chain only has the one account so it gets all the ether and tokens
+var (
+	simulatedEtherBalance = new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1e18))
+	simulatedTokenSupply  = new(big.Int).Mul(big.NewInt(1_000_000_000), big.NewInt(1e18))
+)
+
+// NewSimulatedContractSDK starts an in-memory chain, deploys and wires up
+// the contracts the same way the hardhat deploy scripts do and returns an sdk
+// for it - the signer owns every contract and holds all of the tokens
+// so it can agree to deals without a funded wallet
+func NewSimulatedContractSDK(options Web3Options) (*Web3SDK, error) {
+	options, err := ResolvePrivateKey(options)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := NewSigner(options)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newSimulatedChain(map[common.Address]*big.Int{
+		signer.GetAddress(): simulatedEtherBalance,
+	}, SIMULATED_GAS_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	options.ChainID = int(chainID.Int64())
+
+	options, err = deploySimulatedContracts(options, client, NewSignerTransactOpts(signer, chainID))
+	if err != nil {
+		return nil, fmt.Errorf("error deploying contracts to the simulated chain: %w", err)
+	}
+	system.Info(options.Service, "deployed contracts to the simulated chain", options.ControllerAddress)
+	return newContractSDK(options, client, signer)
+}
+
+// returns options with the address of each deployed contract filled in
+func deploySimulatedContracts(options Web3Options, client *simulatedChain, opts *bind.TransactOpts) (Web3Options, error) {
+	ctx := context.Background()
+	// every tx is mined as it is sent so we only need to check it didn't revert
+	check := func(tx *types.Transaction, err error) error {
+		if err != nil {
+			return err
+		}
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return err
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return fmt.Errorf("tx %s reverted", tx.Hash().String())
+		}
+		return nil
+	}
+
+	tokenAddress, tx, tokenContract, err := token.DeployToken(opts, client, "Lilypad Token", "LP", simulatedTokenSupply)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("token: %w", err)
+	}
+	paymentsAddress, tx, paymentsContract, err := payments.DeployPayments(opts, client)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("payments: %w", err)
+	}
+	if err := check(paymentsContract.Initialize(opts, tokenAddress)); err != nil {
+		return options, fmt.Errorf("payments: %w", err)
+	}
+	if err := check(tokenContract.SetControllerAddress(opts, paymentsAddress)); err != nil {
+		return options, fmt.Errorf("token: %w", err)
+	}
+	storageAddress, tx, storageContract, err := storage.DeployStorage(opts, client)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("storage: %w", err)
+	}
+	if err := check(storageContract.Initialize(opts)); err != nil {
+		return options, fmt.Errorf("storage: %w", err)
+	}
+	usersAddress, tx, usersContract, err := users.DeployUsers(opts, client)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("users: %w", err)
+	}
+	if err := check(usersContract.Initialize(opts)); err != nil {
+		return options, fmt.Errorf("users: %w", err)
+	}
+	mediationAddress, tx, mediationContract, err := mediation.DeployMediation(opts, client)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("mediation: %w", err)
+	}
+	if err := check(mediationContract.Initialize(opts)); err != nil {
+		return options, fmt.Errorf("mediation: %w", err)
+	}
+	jobCreatorAddress, tx, jobCreatorContract, err := jobcreator.DeployJobcreator(opts, client)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("jobcreator: %w", err)
+	}
+	if err := check(jobCreatorContract.Initialize(opts, tokenAddress)); err != nil {
+		return options, fmt.Errorf("jobcreator: %w", err)
+	}
+	controllerAddress, tx, controllerContract, err := controller.DeployController(opts, client)
+	if err := check(tx, err); err != nil {
+		return options, fmt.Errorf("controller: %w", err)
+	}
+	if err := check(controllerContract.Initialize(opts, storageAddress, usersAddress, paymentsAddress, mediationAddress, jobCreatorAddress)); err != nil {
+		return options, fmt.Errorf("controller: %w", err)
+	}
+	for name, setController := range map[string]func(*bind.TransactOpts, common.Address) (*types.Transaction, error){
+		"storage":   storageContract.SetControllerAddress,
+		"payments":  paymentsContract.SetControllerAddress,
+		"mediation": mediationContract.SetControllerAddress,
+	} {
+		if err := check(setController(opts, controllerAddress)); err != nil {
+			return options, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	options.TokenAddress = tokenAddress.String()
+	options.PaymentsAddress = paymentsAddress.String()
+	options.StorageAddress = storageAddress.String()
+	options.UsersAddress = usersAddress.String()
+	options.MediationAddress = mediationAddress.String()
+	options.JobCreatorAddress = jobCreatorAddress.String()
+	options.ControllerAddress = controllerAddress.String()
+	return options, nil
+}
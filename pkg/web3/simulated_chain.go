@@ -0,0 +1,403 @@
+package web3
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// the base fee never moves on the simulated chain
+var (
+	simulatedBaseFee = big.NewInt(params.GWei)
+	simulatedTipCap  = big.NewInt(params.GWei)
+)
+
+// an in-memory chain that runs txs through the go-ethereum EVM
+// every tx is mined straight away in a block of its own
+//
+// we don't use the go-ethereum simulated backend because the one in the
+// version we are on predates shanghai and our contracts are compiled with PUSH0
+type simulatedChain struct {
+	mutex    sync.Mutex
+	config   *params.ChainConfig
+	signer   types.Signer
+	gasLimit uint64
+	state    *state.StateDB
+	// indexed by block number
+	headers  []*types.Header
+	txs      map[common.Hash]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	logs     []*types.Log
+	logFeed  event.Feed
+}
+
+func newSimulatedChain(alloc map[common.Address]*big.Int, gasLimit uint64) (*simulatedChain, error) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		return nil, err
+	}
+	for address, balance := range alloc {
+		statedb.AddBalance(address, balance)
+	}
+	statedb.Finalise(true)
+	config := params.AllDevChainProtocolChanges
+	genesis := &types.Header{
+		Number:     big.NewInt(0),
+		GasLimit:   gasLimit,
+		Time:       uint64(time.Now().Unix()),
+		BaseFee:    simulatedBaseFee,
+		Difficulty: big.NewInt(0),
+		Root:       statedb.IntermediateRoot(true),
+	}
+	return &simulatedChain{
+		config:   config,
+		signer:   types.LatestSignerForChainID(config.ChainID),
+		gasLimit: gasLimit,
+		state:    statedb,
+		headers:  []*types.Header{genesis},
+		txs:      map[common.Hash]*types.Transaction{},
+		receipts: map[common.Hash]*types.Receipt{},
+	}, nil
+}
+
+func (chain *simulatedChain) latestHeader() *types.Header {
+	return chain.headers[len(chain.headers)-1]
+}
+
+func (chain *simulatedChain) newEVM(header *types.Header, msg *core.Message, statedb *state.StateDB, config vm.Config) *vm.EVM {
+	blockContext := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash: func(number uint64) common.Hash {
+			if number < uint64(len(chain.headers)) {
+				return chain.headers[number].Hash()
+			}
+			return common.Hash{}
+		},
+		GasLimit:    header.GasLimit,
+		BlockNumber: header.Number,
+		Time:        header.Time,
+		Difficulty:  header.Difficulty,
+		BaseFee:     header.BaseFee,
+		// a post merge block so the shanghai rules apply
+		Random: &header.MixDigest,
+	}
+	return vm.NewEVM(blockContext, core.NewEVMTxContext(msg), statedb, chain.config, config)
+}
+
+func (chain *simulatedChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	logs, err := chain.mineTransaction(tx)
+	if err != nil {
+		return err
+	}
+	if len(logs) > 0 {
+		chain.logFeed.Send(logs)
+	}
+	return nil
+}
+
+func (chain *simulatedChain) mineTransaction(tx *types.Transaction) ([]*types.Log, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+
+	parent := chain.latestHeader()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		GasLimit:   chain.gasLimit,
+		Time:       parent.Time + 1,
+		BaseFee:    simulatedBaseFee,
+		Difficulty: big.NewInt(0),
+	}
+	if now := uint64(time.Now().Unix()); now > header.Time {
+		header.Time = now
+	}
+	msg, err := core.TransactionToMessage(tx, chain.signer, header.BaseFee)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := chain.state.Snapshot()
+	chain.state.SetTxContext(tx.Hash(), 0)
+	result, err := core.ApplyMessage(chain.newEVM(header, msg, chain.state, vm.Config{}), msg, new(core.GasPool).AddGas(header.GasLimit))
+	if err != nil {
+		// the tx was invalid (bad nonce, not enough ether) so a node would have rejected it
+		chain.state.RevertToSnapshot(snapshot)
+		return nil, err
+	}
+	chain.state.Finalise(true)
+	header.Root = chain.state.IntermediateRoot(true)
+	header.GasUsed = result.UsedGas
+	blockHash := header.Hash()
+
+	receipt := &types.Receipt{
+		Type:              tx.Type(),
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: result.UsedGas,
+		TxHash:            tx.Hash(),
+		GasUsed:           result.UsedGas,
+		EffectiveGasPrice: msg.GasPrice,
+		BlockHash:         blockHash,
+		BlockNumber:       header.Number,
+		Logs:              chain.state.GetLogs(tx.Hash(), header.Number.Uint64(), blockHash),
+	}
+	if result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	if tx.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	chain.headers = append(chain.headers, header)
+	chain.txs[tx.Hash()] = tx
+	chain.receipts[tx.Hash()] = receipt
+	chain.logs = append(chain.logs, receipt.Logs...)
+	return receipt.Logs, nil
+}
+
+// a revert with the data the node returned so getRevertReason can decode it
+type simulatedRevertError struct {
+	result *core.ExecutionResult
+}
+
+func (err *simulatedRevertError) Error() string {
+	return fmt.Sprintf("execution reverted: %s", err.result.Err.Error())
+}
+
+func (err *simulatedRevertError) ErrorCode() int {
+	return 3 //nolint:gomnd
+}
+
+func (err *simulatedRevertError) ErrorData() interface{} {
+	return hexutil.Encode(err.result.Revert())
+}
+
+// run a call against the latest state without keeping any changes
+func (chain *simulatedChain) call(call ethereum.CallMsg) (*core.ExecutionResult, error) {
+	chain.mutex.Lock()
+	header := chain.latestHeader()
+	statedb := chain.state.Copy()
+	chain.mutex.Unlock()
+
+	msg := &core.Message{
+		From:              call.From,
+		To:                call.To,
+		Value:             new(big.Int),
+		GasLimit:          call.Gas,
+		GasPrice:          new(big.Int),
+		GasFeeCap:         new(big.Int),
+		GasTipCap:         new(big.Int),
+		Data:              call.Data,
+		AccessList:        call.AccessList,
+		SkipAccountChecks: true,
+	}
+	if call.Value != nil {
+		msg.Value = call.Value
+	}
+	if msg.GasLimit == 0 {
+		msg.GasLimit = header.GasLimit
+	}
+	// calls are free so don't make them pay the base fee
+	evm := chain.newEVM(header, msg, statedb, vm.Config{NoBaseFee: true})
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(msg.GasLimit))
+	if err != nil {
+		return nil, err
+	}
+	if result.Failed() {
+		return nil, &simulatedRevertError{result: result}
+	}
+	return result, nil
+}
+
+// we only have the latest state so blockNumber is ignored
+func (chain *simulatedChain) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	result, err := chain.call(call)
+	if err != nil {
+		return nil, err
+	}
+	return result.Return(), nil
+}
+
+// the gas used after refunds can be less than a tx needs while it runs
+// so leave plenty of headroom rather than searching for the exact limit
+func (chain *simulatedChain) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	call.Gas = 0
+	result, err := chain.call(call)
+	if err != nil {
+		return 0, err
+	}
+	estimate := result.UsedGas*3/2 + params.TxGas //nolint:gomnd
+	if estimate > chain.gasLimit {
+		estimate = chain.gasLimit
+	}
+	return estimate, nil
+}
+
+func (chain *simulatedChain) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	return chain.state.GetCode(contract), nil
+}
+
+func (chain *simulatedChain) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
+	return chain.CodeAt(ctx, contract, nil)
+}
+
+func (chain *simulatedChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	return chain.state.GetNonce(account), nil
+}
+
+func (chain *simulatedChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	if number == nil {
+		return types.CopyHeader(chain.latestHeader()), nil
+	}
+	if !number.IsUint64() || number.Uint64() >= uint64(len(chain.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return types.CopyHeader(chain.headers[number.Uint64()]), nil
+}
+
+func (chain *simulatedChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Add(simulatedBaseFee, simulatedTipCap), nil
+}
+
+func (chain *simulatedChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(simulatedTipCap), nil
+}
+
+func (chain *simulatedChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	receipt, ok := chain.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func (chain *simulatedChain) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	tx, ok := chain.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, false, nil
+}
+
+func (chain *simulatedChain) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(chain.config.ChainID), nil
+}
+
+func (chain *simulatedChain) BlockNumber(ctx context.Context) (uint64, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	return chain.latestHeader().Number.Uint64(), nil
+}
+
+func (chain *simulatedChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	logs := []types.Log{}
+	for _, log := range chain.logs {
+		if matchesFilterQuery(log, query) {
+			logs = append(logs, *log)
+		}
+	}
+	return logs, nil
+}
+
+func (chain *simulatedChain) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	// only new logs are sent to subscribers
+	query.FromBlock = nil
+	query.ToBlock = nil
+	minedLogs := make(chan []*types.Log)
+	feedSubscription := chain.logFeed.Subscribe(minedLogs)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer feedSubscription.Unsubscribe()
+		for {
+			select {
+			case logs := <-minedLogs:
+				for _, log := range logs {
+					if !matchesFilterQuery(log, query) {
+						continue
+					}
+					select {
+					case ch <- *log:
+					case <-quit:
+						return nil
+					}
+				}
+			case err := <-feedSubscription.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+func matchesFilterQuery(log *types.Log, query ethereum.FilterQuery) bool {
+	if query.BlockHash != nil && log.BlockHash != *query.BlockHash {
+		return false
+	}
+	if query.FromBlock != nil && query.FromBlock.Sign() >= 0 && log.BlockNumber < query.FromBlock.Uint64() {
+		return false
+	}
+	if query.ToBlock != nil && query.ToBlock.Sign() >= 0 && log.BlockNumber > query.ToBlock.Uint64() {
+		return false
+	}
+	if len(query.Addresses) > 0 {
+		found := false
+		for _, address := range query.Addresses {
+			if log.Address == address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for i, topics := range query.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+		if i >= len(log.Topics) {
+			return false
+		}
+		found := false
+		for _, topic := range topics {
+			if log.Topics[i] == topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Compile-time interface check:
+var _ EthClient = (*simulatedChain)(nil)
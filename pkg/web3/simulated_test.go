@@ -0,0 +1,96 @@
+package web3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bacalhau-project/lilypad/pkg/data"
+	"github.com/bacalhau-project/lilypad/pkg/system"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestSimulatedAgree(t *testing.T) {
+	sdk, err := NewSimulatedContractSDK(Web3Options{
+		Mode:               Web3ModeSimulated,
+		SimulatedSolverURL: "http://localhost:8080",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	otherAddress := func() string {
+		key, err := crypto.GenerateKey()
+		assert.NoError(t, err)
+		return GetAddress(key).String()
+	}
+	solverAddress := otherAddress()
+	deal := data.Deal{
+		ID: "simulated-deal",
+		Members: data.DealMembers{
+			Solver:           solverAddress,
+			JobCreator:       otherAddress(),
+			ResourceProvider: sdk.GetAddress().String(),
+			Mediators:        []string{otherAddress()},
+		},
+		Timeouts: data.DealTimeouts{
+			SubmitResults: data.DealTimeout{Timeout: 60, Collateral: 10},
+		},
+	}
+	assert.NoError(t, sdk.SimulateAgree(context.Background(), deal))
+	txHash, err := sdk.AgreeWithContext(context.Background(), deal)
+	assert.NoError(t, err, "The signer should hold enough tokens to agree")
+	assert.NotEmpty(t, txHash)
+
+	err = sdk.SimulateAgree(context.Background(), deal)
+	revertErr := &RevertError{}
+	if assert.ErrorAs(t, err, &revertErr, "Agreeing twice should revert") {
+		assert.Equal(t, "RP has already agreed", revertErr.Reason)
+	}
+
+	// a fixed gas limit skips estimation so the tx gets mined and reverts
+	reverted := testutil.ToFloat64(txRevertedTotal.WithLabelValues("agree"))
+	tx, err := sdk.SendTx(context.Background(), func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		opts.GasLimit = 1000000
+		return sdk.Contracts.Controller.Agree(
+			opts,
+			deal.ID,
+			data.ConvertDealMembers(deal.Members),
+			data.ConvertDealTimeouts(deal.Timeouts),
+			data.ConvertDealPricing(deal.Pricing),
+		)
+	})
+	if assert.NoError(t, err) {
+		receipt, err := sdk.WaitTxSuccess(context.Background(), tx)
+		assert.Equal(t, types.ReceiptStatusFailed, receipt.Status)
+		if assert.ErrorAs(t, err, &revertErr, "A mined tx that reverted should not be a success") {
+			assert.Equal(t, "RP has already agreed", revertErr.Reason)
+		}
+		assert.Equal(t, reverted+1, testutil.ToFloat64(txRevertedTotal.WithLabelValues("agree")))
+	}
+
+	agreement, err := sdk.GetAgreement(deal.ID)
+	assert.NoError(t, err)
+	assert.NotZero(t, agreement.ResourceProviderAgreedAt.Uint64())
+
+	solverURL, err := sdk.GetSolverUrl(context.Background(), solverAddress)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", solverURL, "Unregistered solvers should be the local solver")
+}
+
+func TestEventChannelsStopOnCleanup(t *testing.T) {
+	sdk, err := NewSimulatedContractSDK(Web3Options{Mode: Web3ModeSimulated})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cm := system.NewCleanupManager()
+	assert.NoError(t, NewEventChannels().Start(sdk, context.Background(), cm))
+	// the context is still live so cleaning up has to stop the listeners by itself
+	cm.Cleanup(context.Background())
+}
@@ -2,16 +2,61 @@ package web3
 
 import (
 	"context"
+	"time"
 
 	"github.com/bacalhau-project/lilypad/pkg/system"
 )
 
 type Web3Options struct {
 
+	// Web3ModeRPC or Web3ModeSimulated (see simulated.go)
+	Mode string `json:"mode"`
+	// in simulated mode there are no solvers registered on-chain
+	// so this is the url we use for any solver we look up
+	SimulatedSolverURL string `json:"simulated_solver_url"`
+	// use this url for the solver instead of looking it up on-chain
+	// for private deployments where the solver isn't registered
+	SolverURL string `json:"solver_url"`
+
 	// core settings
 	RpcURL     string `json:"rpc_url"`
 	PrivateKey string `json:"private_key"`
 	ChainID    int    `json:"chain_id"`
+	// refuse to start if the rpc node is on a different chain, 0 to not check
+	ExpectedChainID int `json:"expected_chain_id"`
+
+	// nodes to fail over to in order if we can't reach RpcURL
+	// we go back to RpcURL once it answers again, checking every RpcPrimaryRetryInterval
+	RpcFallbackURLs         []string      `json:"rpc_fallback_urls"`
+	RpcPrimaryRetryInterval time.Duration `json:"rpc_primary_retry_interval"`
+
+	// how long we wait before subscribing to an event again when its subscription
+	// drops - doubled for each failed attempt up to the max
+	EventResubscribeBaseDelay time.Duration `json:"event_resubscribe_base_delay"`
+	EventResubscribeMaxDelay  time.Duration `json:"event_resubscribe_max_delay"`
+
+	// alternatives to putting the private key inline
+	// a file containing the key or the name of an env var holding it
+	// only one way of giving the key can be used (see ResolvePrivateKey)
+	PrivateKeyPath string `json:"private_key_path"`
+	PrivateKeyEnv  string `json:"private_key_env"`
+
+	// an encrypted keystore file and its password to sign with instead of a private key
+	KeystorePath     string `json:"keystore_path"`
+	KeystorePassword string `json:"keystore_password"`
+
+	// how we price gas for the txs we send (see gas.go)
+	// prices are in wei and a max of zero means no cap
+	GasStrategy          string  `json:"gas_strategy"`
+	GasPrice             uint64  `json:"gas_price"`
+	GasMultiplier        float64 `json:"gas_multiplier"`
+	MaxFeePerGas         uint64  `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas uint64  `json:"max_priority_fee_per_gas"`
+
+	// resend txs that haven't been mined after this long with the gas bumped
+	// by GasBumpPercent - zero means we just keep waiting
+	StuckTxTimeout time.Duration `json:"stuck_tx_timeout"`
+	GasBumpPercent int           `json:"gas_bump_percent"`
 
 	// contract addresses
 	ControllerAddress string `json:"controller_address"`
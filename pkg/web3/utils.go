@@ -2,9 +2,13 @@ package web3
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
 	"math/big"
+	"os"
 	"strings"
 
+	"github.com/bacalhau-project/lilypad/pkg/system"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
@@ -39,6 +43,59 @@ func ParsePrivateKey(privateKey string) (*ecdsa.PrivateKey, error) {
 	return crypto.HexToECDSA(strings.Replace(privateKey, "0x", "", 1))
 }
 
+// ResolvePrivateKey loads the private key from PrivateKeyPath or PrivateKeyEnv into PrivateKey
+// it is an error to give the key more than one way because it's not clear which should win
+func ResolvePrivateKey(options Web3Options) (Web3Options, error) {
+	sources := []string{}
+	if options.PrivateKey != "" {
+		sources = append(sources, "private key")
+	}
+	if options.PrivateKeyPath != "" {
+		sources = append(sources, "private key path")
+	}
+	if options.PrivateKeyEnv != "" {
+		sources = append(sources, "private key env")
+	}
+	if options.KeystorePath != "" {
+		sources = append(sources, "keystore path")
+	}
+	if len(sources) > 1 {
+		return options, fmt.Errorf("only one of private key, private key path, private key env or keystore path can be set but got %s", strings.Join(sources, ", "))
+	}
+
+	if options.PrivateKeyPath != "" {
+		privateKey, err := os.ReadFile(options.PrivateKeyPath)
+		if err != nil {
+			return options, fmt.Errorf("error reading private key file: %w", err)
+		}
+		options.PrivateKey = strings.TrimSpace(string(privateKey))
+		if options.PrivateKey == "" {
+			return options, fmt.Errorf("private key file %s is empty", options.PrivateKeyPath)
+		}
+	}
+	if options.PrivateKeyEnv != "" {
+		options.PrivateKey = strings.TrimSpace(os.Getenv(options.PrivateKeyEnv))
+		if options.PrivateKey == "" {
+			return options, fmt.Errorf("private key env var %s is not set", options.PrivateKeyEnv)
+		}
+	}
+
+	// nothing on a simulated chain is worth anything so a throwaway key will do
+	if options.Mode == Web3ModeSimulated && options.PrivateKey == "" && options.KeystorePath == "" {
+		privateKey, err := crypto.GenerateKey()
+		if err != nil {
+			return options, fmt.Errorf("error generating private key: %w", err)
+		}
+		options.PrivateKey = hex.EncodeToString(crypto.FromECDSA(privateKey))
+		system.Info(options.Service, "generated a private key for the simulated chain", GetAddress(privateKey).String())
+	}
+
+	// the key is now inline so resolving again is a no-op
+	options.PrivateKeyPath = ""
+	options.PrivateKeyEnv = ""
+	return options, nil
+}
+
 func EtherToWei(etherAmount float64) *big.Int {
 	ether := new(big.Float).SetFloat64(etherAmount)
 	weiMultiplier := new(big.Float).SetFloat64(1e18)
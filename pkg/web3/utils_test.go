@@ -1,6 +1,9 @@
 package web3
 
 import (
+	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -52,3 +55,34 @@ func TestGetAddressFromSignedMessage(t *testing.T) {
 	expectedAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
 	assert.Equal(t, expectedAddress, address, "Addresses should be equal")
 }
+
+func TestResolvePrivateKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("abc123\n"), 0600))
+
+	options, err := ResolvePrivateKey(Web3Options{PrivateKeyPath: keyFile})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", options.PrivateKey, "The key should be read from the file without the newline")
+	assert.Empty(t, options.PrivateKeyPath)
+
+	t.Setenv("TEST_RESOLVE_PRIVATE_KEY", "def456")
+	options, err = ResolvePrivateKey(Web3Options{PrivateKeyEnv: "TEST_RESOLVE_PRIVATE_KEY"})
+	assert.NoError(t, err)
+	assert.Equal(t, "def456", options.PrivateKey)
+
+	_, err = ResolvePrivateKey(Web3Options{PrivateKeyEnv: "TEST_RESOLVE_PRIVATE_KEY_MISSING"})
+	assert.Error(t, err, "A missing env var should be an error")
+
+	_, err = ResolvePrivateKey(Web3Options{PrivateKey: "abc123", PrivateKeyPath: keyFile})
+	assert.Error(t, err, "Giving the key more than one way should be an error")
+
+	options, err = ResolvePrivateKey(Web3Options{PrivateKey: "abc123"})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", options.PrivateKey, "An inline key should be left alone")
+}
+
+func TestCheckChainID(t *testing.T) {
+	assert.NoError(t, checkChainID(1337, big.NewInt(1337)))
+	err := checkChainID(1337, big.NewInt(1))
+	assert.ErrorContains(t, err, "expected chain id 1337 but got 1")
+}